@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"novel_reader/library"
+)
+
+// runCheckSource implements `novel_reader check-source [flags] [query]`.
+func runCheckSource(args []string) int {
+	fs := flag.NewFlagSet("check-source", flag.ExitOnError)
+	record := fs.String("record", "", "save every HTTP exchange to this fixture directory")
+	replay := fs.String("replay", "", "check against fixtures in this directory instead of the live site")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: novel_reader check-source [--record DIR | --replay DIR] [query]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	query := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if query == "" {
+		query = "斗破苍穹"
+	}
+
+	stop, err := setupFixtures(*record, *replay)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer stop()
+
+	report := library.CheckSource(query)
+	report.Print(os.Stdout)
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"novel_reader/library"
+	"novel_reader/utils"
+)
+
+// runDoctor implements `novel_reader doctor [--fix]`.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "repair what can be repaired (re-fetching needs the network)")
+	offline := fs.Bool("offline", false, "skip repairs that need the network")
+	dataDir := fs.String("data-dir", "", "portable data directory to check")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: novel_reader doctor [--fix] [--offline]")
+		fmt.Fprintln(fs.Output(), "checks the chapter cache and progress file for damage")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	utils.DataDirOverride = *dataDir
+
+	release, err := utils.LockDataDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot lock data directory:", err)
+		return 2
+	}
+	defer release()
+	if *fix && utils.ReadOnly() {
+		fmt.Fprintln(os.Stderr, "another novel_reader is running; close it before --fix")
+		return 2
+	}
+	utils.Main()
+	if *offline {
+		utils.ForceOffline = true
+	}
+
+	report, err := library.Diagnose()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	report.Print(os.Stdout)
+	if len(report.Issues) == 0 {
+		return 0
+	}
+	if !*fix {
+		if len(report.Fixable()) > 0 {
+			fmt.Println("run with --fix to repair")
+		}
+		return 1
+	}
+
+	fixed, errs := library.Repair(report.Fixable())
+	fmt.Printf("fixed: %d\n", fixed)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	// fixing one issue can uncover another, e.g. a rebuilt index shows the
+	// chapters it holds, so check again before deciding the exit code
+	report, err = library.Diagnose()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Printf("remaining: %d\n", len(report.Issues))
+	if len(report.Issues) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"novel_reader/library"
+	"novel_reader/utils"
+)
+
+// runExportCache implements `novel_reader export-cache [flags] [book-id...]`.
+func runExportCache(args []string) int {
+	fs := flag.NewFlagSet("export-cache", flag.ExitOnError)
+	out := fs.String("out", "novel_reader-export", "directory to write one folder per book into")
+	dataDir := fs.String("data-dir", "", "portable data directory to export from")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: novel_reader export-cache [--out DIR] [book-id...]")
+		fmt.Fprintln(fs.Output(), "writes cached online novels back out as plain N.txt files")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	utils.DataDirOverride = *dataDir
+
+	usage, _, err := library.CacheUsage()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	want := make(map[string]bool)
+	for _, id := range fs.Args() {
+		want[id] = true
+	}
+
+	failed := false
+	for _, u := range usage {
+		if len(want) > 0 && !want[u.ID] {
+			continue
+		}
+		delete(want, u.ID)
+		dir := filepath.Join(*out, u.ID)
+		n, err := library.ExportNovel(u.ID, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s (%s): %v\n", u.Title, u.ID, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s  %s  %d chapters -> %s\n", u.ID, u.Title, n, dir)
+	}
+	for id := range want {
+		fmt.Fprintf(os.Stderr, "%s: not in the cache\n", id)
+		failed = true
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
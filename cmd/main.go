@@ -1,15 +1,84 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+
+	"novel_reader/library"
 	"novel_reader/ui"
 	"novel_reader/utils"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check-source":
+			os.Exit(runCheckSource(os.Args[2:]))
+		case "export-cache":
+			os.Exit(runExportCache(os.Args[2:]))
+		case "doctor":
+			os.Exit(runDoctor(os.Args[2:]))
+		}
+	}
+
+	offline := flag.Bool("offline", false, "never touch the network; read cached chapters only")
+	record := flag.String("record", "", "save every HTTP exchange to this fixture directory")
+	replay := flag.String("replay", "", "answer HTTP requests from this fixture directory")
+	dataDir := flag.String("data-dir", "", "keep config, cache and progress under this directory (portable mode; also $"+utils.HomeEnv+")")
+	flag.Parse()
+	utils.DataDirOverride = *dataDir
+
+	release, err := utils.LockDataDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot lock data directory:", err)
+	}
+	defer release()
+	if err := utils.MigrateLegacyLayout(); err != nil {
+		fmt.Fprintln(os.Stderr, "moving data to the new directories failed:", err)
+	}
+
 	utils.Main()
+	if *offline {
+		utils.ForceOffline = true
+	}
+	if err := library.MigrateBookIDs(); err != nil {
+		fmt.Fprintln(os.Stderr, "book ID migration failed:", err)
+	}
+	if _, err := library.EnforceQuota(); err != nil {
+		fmt.Fprintln(os.Stderr, "cache eviction failed:", err)
+	}
+
+	stop, err := setupFixtures(*record, *replay)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer stop()
+
 	ui.RunApp()
 }
 
+// setupFixtures enables fixture recording or replay for the scraper.
+func setupFixtures(record, replay string) (func(), error) {
+	if record != "" && replay != "" {
+		return nil, fmt.Errorf("--record and --replay cannot be used together")
+	}
+	if record != "" {
+		if err := library.EnableRecording(record); err != nil {
+			return nil, fmt.Errorf("record: %w", err)
+		}
+	}
+	if replay != "" {
+		stop, err := library.EnableReplay(replay)
+		if err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
+		}
+		return stop, nil
+	}
+	return func() {}, nil
+}
+
 /*
 	1. Reader
 		- Search word in content
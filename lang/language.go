@@ -30,6 +30,27 @@ type SettingsStrings struct {
 	RemoveFolderDetail      string
 	RemoveOnlineLabel       string
 	RemoveOnlineDetail      string
+	OfflineLabel            string
+	OfflineDetail           string
+	OfflineOn               string
+	OfflineOff              string
+	CacheLabel              string
+	CacheDetail             string
+	CacheUnlimited          string
+	CacheUsageTemplate      string
+	CacheItemTemplate       string
+	CachePinned             string
+	CacheHints              string
+	CacheReclaimedTemplate  string
+	CacheFailedTemplate     string
+	DoctorLabel             string
+	DoctorDetail            string
+	DoctorRunning           string
+	DoctorHealthy           string
+	DoctorSummaryTemplate   string
+	DoctorIssueTemplate     string
+	DoctorHints             string
+	DoctorFixedTemplate     string
 	CountSuffixNone         string
 	CountSuffixSingle       string
 	CountSuffixMultiple     string
@@ -37,6 +58,7 @@ type SettingsStrings struct {
 	LineSpacingUpdateFailed string
 	FolderDialogUnavailable string
 	SaveConfigFailed        string
+	ReadOnlyNotice          string
 }
 
 type SearchStrings struct {
@@ -57,17 +79,33 @@ type ConfirmStrings struct {
 	RemoveFolderConfirm        string
 	RemoveOnlinePromptTemplate string
 	RemoveOnlineConfirm        string
+	ClearCachePromptTemplate   string
+	ClearCacheConfirm          string
 }
 
 type BookshelfStrings struct {
 	DiscoveryName string
 	UnknownType   string
+	SortTemplate  string // order name
+	SortAdded     string
+	SortTitle     string
+	SortAuthor    string
 }
 
 type ReaderStrings struct {
 	LoadingDefault       string
 	LoadingTitleTemplate string
 	ChapterTemplate      string
+	RulesTitle           string
+	RulesPrompt          string
+	RulesPlaceholder     string
+	RulesCountTemplate   string
+	RulesDefault         string
+	RulesHint            string
+	VolumeTemplate       string
+	EncodingTemplate     string // name, confidence in percent
+	EncodingAutoTemplate string
+	EncodingNone         string
 }
 
 type TOCStrings struct {
@@ -75,6 +113,18 @@ type TOCStrings struct {
 	StatusSingular string
 	StatusPlural   string
 	FilterPrompt   string
+	Unavailable    string
+	VolumeTemplate string
+	VolumeHint     string
+
+	WarnDuplicate       string
+	WarnMisordered      string
+	WarnMissingTemplate string
+	NumberingTemplate   string
+	RefillHint          string
+	Refilling           string
+	RefilledTemplate    string
+	RefillNone          string
 }
 
 type DialogStrings struct {
@@ -82,7 +132,22 @@ type DialogStrings struct {
 }
 
 type NovelStrings struct {
-	LocalPrefix string
+	LocalPrefix       string
+	FullyCached       string
+	StatusOngoing     string
+	StatusCompleted   string
+	DetailAuthor      string
+	DetailCategory    string
+	DetailStatus      string
+	DetailTags        string
+	DetailWords       string
+	DetailFirstUpdate string
+	DetailLastUpdate  string
+	DetailSource      string
+	DetailNoSynopsis  string
+	DetailHint        string
+	DetailEdit        string // hint, local books only
+	DetailSidecar     string // %s: the sidecar's path
 }
 
 type CommonStrings struct {
@@ -93,6 +158,26 @@ type LayoutStrings struct {
 	UnderlineLength int
 }
 
+type ErrorStrings struct {
+	Network              string
+	HTTPStatusTemplate   string
+	RateLimited          string
+	Parse                string
+	NotFound             string
+	CacheCorrupt         string
+	Offline              string
+	Unknown              string
+	ActionRetry          string
+	ActionSwitchSource   string
+	ActionOpenCached     string
+	ActionReport         string
+	ActionBack           string
+	ReportSavedTemplate  string
+	ReportFailedTemplate string
+	ProgressUnreadable   string
+	SkippedTemplate      string
+}
+
 type Strings struct {
 	Tabs      TabsStrings
 	Settings  SettingsStrings
@@ -105,6 +190,7 @@ type Strings struct {
 	Novel     NovelStrings
 	Common    CommonStrings
 	Layout    LayoutStrings
+	Errors    ErrorStrings
 }
 
 var (
@@ -119,19 +205,40 @@ var (
 				Settings:  "设置",
 			},
 			Settings: SettingsStrings{
-				LanguageLabel:       "语言",
-				LanguageDetail:      "使用左右键切换语言",
-				LineSpacingLabel:    "行间距",
-				LineSpacingDetail:   "使用左右键调整行间距",
-				AddFolderLabel:      "导入本地文件夹",
-				AddFolderDetail:     "导入一个本地小说文件夹",
-				RemoveFolderLabel:   "删除本地文件夹",
-				RemoveFolderDetail:  "删除一个本地小说文件夹",
-				RemoveOnlineLabel:   "删除网络小说缓存",
-				RemoveOnlineDetail:  "删除一个已缓存的网络小说",
-				CountSuffixNone:     "(无)",
-				CountSuffixSingle:   "(1)",
-				CountSuffixMultiple: "(%d)",
+				LanguageLabel:          "语言",
+				LanguageDetail:         "使用左右键切换语言",
+				LineSpacingLabel:       "行间距",
+				LineSpacingDetail:      "使用左右键调整行间距",
+				AddFolderLabel:         "导入本地文件夹",
+				AddFolderDetail:        "导入一个本地小说文件夹",
+				RemoveFolderLabel:      "删除本地文件夹",
+				RemoveFolderDetail:     "删除一个本地小说文件夹",
+				RemoveOnlineLabel:      "删除网络小说缓存",
+				RemoveOnlineDetail:     "删除一个已缓存的网络小说",
+				OfflineLabel:           "离线模式",
+				OfflineDetail:          "开启后不访问网络，只读取已缓存章节",
+				OfflineOn:              "开",
+				OfflineOff:             "关",
+				CacheLabel:             "缓存占用",
+				CacheDetail:            "查看每本网络小说的缓存大小，可精简或清空",
+				CacheUnlimited:         "不限",
+				CacheUsageTemplate:     "%s / %s",
+				CacheItemTemplate:      "已缓存 %d 章，%s",
+				CachePinned:            "已固定",
+				CacheHints:             "t 精简  c 清空  p 固定/取消固定  esc 返回",
+				CacheReclaimedTemplate: "已释放 %s",
+				CacheFailedTemplate:    "缓存操作失败: %v",
+				DoctorLabel:            "检查缓存",
+				DoctorDetail:           "检查缓存和阅读进度是否损坏，并尝试修复",
+				DoctorRunning:          "正在检查…",
+				DoctorHealthy:          "没有发现问题。",
+				DoctorSummaryTemplate:  "发现 %d 个问题，%d 个可修复",
+				DoctorIssueTemplate:    "%s · 修复: %s",
+				DoctorHints:            "f 修复所选  a 全部修复  esc 返回",
+				DoctorFixedTemplate:    "已修复 %d 个，%d 个失败",
+				CountSuffixNone:        "(无)",
+				CountSuffixSingle:      "(1)",
+				CountSuffixMultiple:    "(%d)",
 				LanguageNames: map[Locale]string{
 					LocaleChinese: "中文",
 					LocaleEnglish: "英文",
@@ -139,6 +246,7 @@ var (
 				LineSpacingUpdateFailed: "无法更新行间距: %v",
 				FolderDialogUnavailable: "系统不支持文件夹选择对话框。",
 				SaveConfigFailed:        "无法保存设置: %v",
+				ReadOnlyNotice:          "另一个实例正在运行，本实例为只读：进度和设置不会保存。",
 			},
 			Search: SearchStrings{
 				Placeholder:          "输入小说名称..",
@@ -157,27 +265,70 @@ var (
 				RemoveFolderConfirm:        "确认",
 				RemoveOnlinePromptTemplate: "确认要删除缓存 《%s》?",
 				RemoveOnlineConfirm:        "确认",
+				ClearCachePromptTemplate:   "确认要清空 《%s》 的全部已缓存章节?",
+				ClearCacheConfirm:          "清空",
 			},
 			Bookshelf: BookshelfStrings{
 				DiscoveryName: "发现",
 				UnknownType:   "未知类型",
+				SortTemplate:  "排序：%s · s 切换",
+				SortAdded:     "最近添加",
+				SortTitle:     "书名",
+				SortAuthor:    "作者",
 			},
 			Reader: ReaderStrings{
 				LoadingDefault:       "章节加载中…",
 				LoadingTitleTemplate: "正在加载「%s」…",
 				ChapterTemplate:      "第%d章",
+				RulesTitle:           "本书章节规则",
+				RulesPrompt:          "正则：",
+				RulesPlaceholder:     "每行一条，留空使用全局规则，例如 ^\\d+\\.\\s",
+				RulesCountTemplate:   "%d 章",
+				RulesDefault:         "（全局规则）",
+				RulesHint:            "[ctrl+s] 保存  [enter] 新规则  [esc] 取消",
+				VolumeTemplate:       "卷 %d/%d · %s",
+				EncodingTemplate:     "编码：%s（%d%%）· e 换下一个",
+				EncodingAutoTemplate: "编码：自动识别为 %s（%d%%）· e 换下一个",
+				EncodingNone:         "此格式不按编码读取",
 			},
 			TOC: TOCStrings{
 				Title:          "目录",
 				StatusSingular: "章",
 				StatusPlural:   "章",
 				FilterPrompt:   "搜索：",
+				Unavailable:    "（未缓存）",
+				VolumeTemplate: "%s（%d 章）",
+				VolumeHint:     "[space] 折叠/展开  [c] 全部折叠/展开  [ 和 ] 上/下一卷  数字+v 跳到第几卷",
+
+				WarnDuplicate:       " ⚠ 重复",
+				WarnMisordered:      " ⚠ 顺序错乱",
+				WarnMissingTemplate: " ⚠ 前缺第 %s 章",
+				NumberingTemplate:   "章节编号：缺 %d 章，重复 %d 章，顺序错乱 %d 章",
+				RefillHint:          "[f] 从书源补全",
+				Refilling:           "正在从书源重新获取目录…",
+				RefilledTemplate:    "补全了 %d 章",
+				RefillNone:          "书源上也没有更多章节",
 			},
 			Dialog: DialogStrings{
 				SelectFolderPrompt: "选择小说文件夹",
 			},
 			Novel: NovelStrings{
-				LocalPrefix: "本地",
+				LocalPrefix:       "本地",
+				FullyCached:       "已全部缓存",
+				StatusOngoing:     "连载中",
+				StatusCompleted:   "已完结",
+				DetailAuthor:      "作者",
+				DetailCategory:    "分类",
+				DetailStatus:      "状态",
+				DetailTags:        "标签",
+				DetailWords:       "字数",
+				DetailFirstUpdate: "首次更新",
+				DetailLastUpdate:  "最后更新",
+				DetailSource:      "来源",
+				DetailNoSynopsis:  "暂无简介",
+				DetailHint:        "esc / i 关闭",
+				DetailEdit:        "e 写出元数据文件以便修改",
+				DetailSidecar:     "修改 %s 即可更正书名、作者和简介",
 			},
 			Common: CommonStrings{
 				UnknownState: "未知状态",
@@ -185,6 +336,25 @@ var (
 			Layout: LayoutStrings{
 				UnderlineLength: 48,
 			},
+			Errors: ErrorStrings{
+				Network:              "无法连接网络，请检查网络后重试。",
+				HTTPStatusTemplate:   "服务器返回错误 %d。",
+				RateLimited:          "请求过于频繁，请稍后重试。",
+				Parse:                "无法解析页面，网站结构可能已变化。",
+				NotFound:             "未找到该内容。",
+				CacheCorrupt:         "本地缓存已损坏。",
+				Offline:              "离线模式：该内容尚未缓存。",
+				Unknown:              "发生错误: %v",
+				ActionRetry:          "重试",
+				ActionSwitchSource:   "换源",
+				ActionOpenCached:     "打开缓存",
+				ActionReport:         "报告",
+				ActionBack:           "返回",
+				ReportSavedTemplate:  "错误报告已保存到 %s",
+				ReportFailedTemplate: "无法保存错误报告: %v",
+				ProgressUnreadable:   "阅读进度无法读取，书籍暂不显示进度。可在设置中运行「检查缓存」修复。",
+				SkippedTemplate:      "%d 本书无法读取，已跳过：%s",
+			},
 		},
 		LocaleEnglish: {
 			Tabs: TabsStrings{
@@ -194,19 +364,40 @@ var (
 				Settings:  "Settings",
 			},
 			Settings: SettingsStrings{
-				LanguageLabel:       "Language",
-				LanguageDetail:      "Use left/right to switch language",
-				LineSpacingLabel:    "Line Spacing",
-				LineSpacingDetail:   "Use left/right to adjust line spacing",
-				AddFolderLabel:      "Add Local Folder",
-				AddFolderDetail:     "Import a local novel folder",
-				RemoveFolderLabel:   "Remove Local Folder",
-				RemoveFolderDetail:  "Remove a local novel folder",
-				RemoveOnlineLabel:   "Remove Online Cache",
-				RemoveOnlineDetail:  "Delete a cached online novel",
-				CountSuffixNone:     "(none)",
-				CountSuffixSingle:   "(1)",
-				CountSuffixMultiple: "(%d)",
+				LanguageLabel:          "Language",
+				LanguageDetail:         "Use left/right to switch language",
+				LineSpacingLabel:       "Line Spacing",
+				LineSpacingDetail:      "Use left/right to adjust line spacing",
+				AddFolderLabel:         "Add Local Folder",
+				AddFolderDetail:        "Import a local novel folder",
+				RemoveFolderLabel:      "Remove Local Folder",
+				RemoveFolderDetail:     "Remove a local novel folder",
+				RemoveOnlineLabel:      "Remove Online Cache",
+				RemoveOnlineDetail:     "Delete a cached online novel",
+				OfflineLabel:           "Offline Mode",
+				OfflineDetail:          "Never touch the network; read cached chapters only",
+				OfflineOn:              "On",
+				OfflineOff:             "Off",
+				CacheLabel:             "Cache Usage",
+				CacheDetail:            "Per-novel cache size; trim or clear downloaded chapters",
+				CacheUnlimited:         "no limit",
+				CacheUsageTemplate:     "%s / %s",
+				CacheItemTemplate:      "%d chapters cached, %s",
+				CachePinned:            "pinned",
+				CacheHints:             "t trim  c clear  p pin/unpin  esc back",
+				CacheReclaimedTemplate: "Freed %s",
+				CacheFailedTemplate:    "Cache operation failed: %v",
+				DoctorLabel:            "Check Cache",
+				DoctorDetail:           "Look for damaged cache and progress files and repair them",
+				DoctorRunning:          "Checking…",
+				DoctorHealthy:          "No problems found.",
+				DoctorSummaryTemplate:  "%d problems, %d fixable",
+				DoctorIssueTemplate:    "%s · fix: %s",
+				DoctorHints:            "f fix selected  a fix all  esc back",
+				DoctorFixedTemplate:    "Fixed %d, %d failed",
+				CountSuffixNone:        "(none)",
+				CountSuffixSingle:      "(1)",
+				CountSuffixMultiple:    "(%d)",
 				LanguageNames: map[Locale]string{
 					LocaleChinese: "Chinese",
 					LocaleEnglish: "English",
@@ -214,6 +405,7 @@ var (
 				LineSpacingUpdateFailed: "Failed to update line spacing: %v",
 				FolderDialogUnavailable: "Folder selection dialog is not available on this system.",
 				SaveConfigFailed:        "Failed to save settings: %v",
+				ReadOnlyNotice:          "Another instance is running; this one is read-only and will not save progress or settings.",
 			},
 			Search: SearchStrings{
 				Placeholder:          "Enter a novel name…",
@@ -232,27 +424,70 @@ var (
 				RemoveFolderConfirm:        "Confirm",
 				RemoveOnlinePromptTemplate: "Remove cached novel 《%s》?",
 				RemoveOnlineConfirm:        "Remove",
+				ClearCachePromptTemplate:   "Clear all cached chapters of 《%s》?",
+				ClearCacheConfirm:          "Clear",
 			},
 			Bookshelf: BookshelfStrings{
 				DiscoveryName: "Discover",
 				UnknownType:   "Unknown item",
+				SortTemplate:  "Sorted by %s · s to change",
+				SortAdded:     "date added",
+				SortTitle:     "title",
+				SortAuthor:    "author",
 			},
 			Reader: ReaderStrings{
 				LoadingDefault:       "Loading chapter…",
 				LoadingTitleTemplate: "Loading %s…",
 				ChapterTemplate:      "Chapter %d",
+				RulesTitle:           "Chapter rules for this book",
+				RulesPrompt:          "Regexp: ",
+				RulesPlaceholder:     "one per line, empty for the global rules, e.g. ^\\d+\\.\\s",
+				RulesCountTemplate:   "%d chapters",
+				RulesDefault:         "(global rules)",
+				RulesHint:            "[ctrl+s] save  [enter] new rule  [esc] cancel",
+				VolumeTemplate:       "Volume %d/%d · %s",
+				EncodingTemplate:     "Encoding: %s (%d%%) · e for the next",
+				EncodingAutoTemplate: "Encoding: detected as %s (%d%%) · e for the next",
+				EncodingNone:         "This format does not use a text encoding",
 			},
 			TOC: TOCStrings{
 				Title:          "Table of Contents",
 				StatusSingular: "chapter",
 				StatusPlural:   "chapters",
 				FilterPrompt:   "Search:",
+				Unavailable:    " (unavailable)",
+				VolumeTemplate: "%s (%d chapters)",
+				VolumeHint:     "[space] fold  [c] fold all  [ and ] previous/next volume  number+v go to volume",
+
+				WarnDuplicate:       " ⚠ duplicate",
+				WarnMisordered:      " ⚠ out of order",
+				WarnMissingTemplate: " ⚠ %s missing before",
+				NumberingTemplate:   "Numbering: %d missing, %d duplicated, %d out of order",
+				RefillHint:          "[f] fill gaps from the source",
+				Refilling:           "Fetching the chapter list again…",
+				RefilledTemplate:    "%d chapters added",
+				RefillNone:          "The source has no more chapters either",
 			},
 			Dialog: DialogStrings{
 				SelectFolderPrompt: "Select a novel folder",
 			},
 			Novel: NovelStrings{
-				LocalPrefix: "Local",
+				LocalPrefix:       "Local",
+				FullyCached:       "fully cached",
+				StatusOngoing:     "ongoing",
+				StatusCompleted:   "completed",
+				DetailAuthor:      "Author",
+				DetailCategory:    "Category",
+				DetailStatus:      "Status",
+				DetailTags:        "Tags",
+				DetailWords:       "Words",
+				DetailFirstUpdate: "First update",
+				DetailLastUpdate:  "Last update",
+				DetailSource:      "Source",
+				DetailNoSynopsis:  "No synopsis.",
+				DetailHint:        "esc / i to close",
+				DetailEdit:        "e to write a metadata file to edit",
+				DetailSidecar:     "Edit %s to correct the title, author and synopsis",
 			},
 			Common: CommonStrings{
 				UnknownState: "Unknown state",
@@ -260,6 +495,25 @@ var (
 			Layout: LayoutStrings{
 				UnderlineLength: 60,
 			},
+			Errors: ErrorStrings{
+				Network:              "Network unreachable. Check your connection and retry.",
+				HTTPStatusTemplate:   "The server responded with status %d.",
+				RateLimited:          "Too many requests. Please wait a moment and retry.",
+				Parse:                "Could not read the page. The site layout may have changed.",
+				NotFound:             "The requested content was not found.",
+				CacheCorrupt:         "The local cache is corrupt.",
+				Offline:              "Offline mode: this content is not cached.",
+				Unknown:              "Error: %v",
+				ActionRetry:          "Retry",
+				ActionSwitchSource:   "Switch source",
+				ActionOpenCached:     "Open cached",
+				ActionReport:         "Report",
+				ActionBack:           "Back",
+				ReportSavedTemplate:  "Error report saved to %s",
+				ReportFailedTemplate: "Failed to save error report: %v",
+				ProgressUnreadable:   "Reading progress could not be read; books are listed without it. Run Check Cache in Settings to repair it.",
+				SkippedTemplate:      "%d books could not be read and were skipped: %s",
+			},
 		},
 	}
 
@@ -346,3 +600,23 @@ func SearchGeneralFailure(err error) string {
 	s := Active()
 	return fmt.Sprintf(s.Search.SearchFailedTemplate, err)
 }
+
+func ErrorHTTPStatus(status int) string {
+	s := Active()
+	return fmt.Sprintf(s.Errors.HTTPStatusTemplate, status)
+}
+
+func ErrorUnknown(err error) string {
+	s := Active()
+	return fmt.Sprintf(s.Errors.Unknown, err)
+}
+
+func ErrorReportSaved(path string) string {
+	s := Active()
+	return fmt.Sprintf(s.Errors.ReportSavedTemplate, path)
+}
+
+func ErrorReportFailed(err error) string {
+	s := Active()
+	return fmt.Sprintf(s.Errors.ReportFailedTemplate, err)
+}
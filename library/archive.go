@@ -0,0 +1,193 @@
+package library
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"novel_reader/utils"
+)
+
+// Books inside .zip archives are listed under a virtual path: the archive's
+// path followed by the member's, as if the archive were a folder:
+//
+//	/books/2019.zip/fantasy/book.txt
+//
+// They are read straight from the archive and never extracted. Their IDs
+// fingerprint the member's content like any local file, so progress stays
+// put when the archive is renamed or repacked.
+
+// isArchive reports whether name is a zip archive to look into, as opposed
+// to a format that happens to be zipped, like .fb2.zip.
+func isArchive(name string) bool {
+	return bookExt(name) == ".zip"
+}
+
+// splitArchivePath splits a virtual path into the archive file and the
+// member name inside it. ok is false for plain files.
+func splitArchivePath(p string) (archive, member string, ok bool) {
+	sep := string(filepath.Separator)
+	lower := strings.ToLower(p)
+	for i := 0; ; {
+		j := strings.Index(lower[i:], ".zip"+sep)
+		if j < 0 {
+			return "", "", false
+		}
+		end := i + j + len(".zip")
+		if info, err := os.Stat(p[:end]); err == nil && info.Mode().IsRegular() {
+			return p[:end], filepath.ToSlash(p[end+1:]), true
+		}
+		i = end
+	}
+}
+
+// memberName returns the name of a zip member as UTF-8. Archives made on
+// Chinese Windows often store GBK names without saying so.
+func memberName(f *zip.File) string {
+	if f.NonUTF8 && !utf8.ValidString(f.Name) {
+		return utils.DecodeText([]byte(f.Name))
+	}
+	return f.Name
+}
+
+// archiveMember finds member in an opened archive.
+func archiveMember(zr *zip.ReadCloser, member string) (*zip.File, error) {
+	for _, f := range zr.File {
+		if path.Clean(memberName(f)) == member {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", member, fs.ErrNotExist)
+}
+
+// openLocalFile opens a local book file, real or inside an archive.
+func openLocalFile(p string) (io.ReadCloser, error) {
+	archive, member, ok := splitArchivePath(p)
+	if !ok {
+		return os.Open(p)
+	}
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	f, err := archiveMember(zr, member)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{rc, closeBoth{rc, zr}}, nil
+}
+
+// readLocalFile is os.ReadFile for local book files, real or archived.
+func readLocalFile(p string) ([]byte, error) {
+	rc, err := openLocalFile(p)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// localStat returns the size of a local book file and when it was last
+// changed; for archived books, those stored for the member, for folder
+// books the size of all chapters and the time of the newest.
+func localStat(p string) (int64, time.Time, error) {
+	if isDir(p) {
+		return folderStat(p)
+	}
+	archive, member, ok := splitArchivePath(p)
+	if !ok {
+		info, err := os.Stat(p)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		return info.Size(), info.ModTime(), nil
+	}
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer zr.Close()
+	f, err := archiveMember(zr, member)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return int64(f.UncompressedSize64), f.Modified, nil
+}
+
+// archiveBooks returns the virtual paths of the books inside an archive.
+// Only formats that can be read from memory are listed; nested archives
+// are not opened.
+func archiveBooks(archive string) ([]string, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var paths []string
+	for _, f := range zr.File {
+		name := path.Clean(memberName(f))
+		if f.FileInfo().IsDir() || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			continue
+		}
+		if format, ok := formatOf(name); ok && format.archived {
+			paths = append(paths, filepath.Join(archive, filepath.FromSlash(name)))
+		}
+	}
+	return paths, nil
+}
+
+// walkLocalBooks calls fn with the path of every book under dir, looking
+// into archives; a folder book is passed as its directory, though dir and
+// the other library folders are never taken for one. Only an unreadable dir itself is an error; folders
+// and archives inside it that cannot be read are skipped.
+func walkLocalBooks(dir string, fn func(path string) error) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != dir && !isLibraryRoot(p) && isFolderBook(p) {
+				if err := fn(p); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isArchive(d.Name()) {
+			members, err := archiveBooks(p)
+			if err != nil {
+				return nil
+			}
+			for _, m := range members {
+				if err := fn(m); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if IsLocalBookFile(d.Name()) {
+			return fn(p)
+		}
+		return nil
+	})
+}
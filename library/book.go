@@ -0,0 +1,158 @@
+package library
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"novel_reader/utils"
+)
+
+var errUnsupportedFormat = errors.New("unsupported file format")
+
+// LocalBook is a local file turned into what the reader shows: paragraph
+// lines and, for formats that carry one, a table of contents into them.
+type LocalBook struct {
+	Title  string
+	Author string
+	Lines  []string
+	TOC    []BookChapter // nil: the reader detects chapters in Lines
+	BookMeta
+}
+
+// BookChapter is one table of contents entry of a LocalBook.
+type BookChapter struct {
+	Title string
+	Line  int // index into Lines where the chapter starts
+	Depth int // 0 for top level entries, 1 for their children …
+}
+
+// localFormat reads one kind of local file. info returns the metadata and
+// TOC without converting the text, for library scans; Lines may be empty
+// and TOC lines meaningless. A nil info means the format has no metadata of
+// its own. archived formats read through readLocalFile and so also work
+// inside zip archives.
+type localFormat struct {
+	load     func(path string) (LocalBook, error)
+	info     func(path string) (LocalBook, error)
+	archived bool
+}
+
+// localFormats maps a lower-case file extension to its reader. Extensions
+// may have two parts, like ".fb2.zip".
+var localFormats = map[string]localFormat{
+	".txt":     {load: loadTxtBook, info: loadTxtBook, archived: true},
+	".epub":    {load: loadEPUB, info: loadEPUB},
+	".fb2":     {load: loadFB2, info: loadFB2},
+	".fb2.zip": {load: loadFB2, info: loadFB2},
+	".docx":    {load: loadDOCX, info: loadDOCX},
+	".odt":     {load: loadODT, info: loadODT},
+	".html":    {load: loadHTML, info: loadHTML, archived: true},
+	".htm":     {load: loadHTML, info: loadHTML, archived: true},
+	".md":      {load: loadMarkdown, info: loadMarkdown, archived: true},
+}
+
+// compoundExts are the localFormats keys filepath.Ext alone cannot find.
+var compoundExts = []string{".fb2.zip"}
+
+// bookExt returns the extension of name as a localFormats key.
+func bookExt(name string) string {
+	lower := strings.ToLower(filepath.Base(name))
+	for _, ext := range compoundExts {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return strings.ToLower(filepath.Ext(lower))
+}
+
+func formatOf(path string) (localFormat, bool) {
+	f, ok := localFormats[bookExt(path)]
+	return f, ok
+}
+
+// localFormatOf is formatOf for the path of a library book, which may also
+// be a folder book.
+func localFormatOf(path string) (localFormat, bool) {
+	if isDir(path) {
+		return folderFormat, true
+	}
+	return formatOf(path)
+}
+
+// IsLocalBookFile reports whether name has an extension the library reads.
+func IsLocalBookFile(name string) bool {
+	_, ok := formatOf(name)
+	return ok
+}
+
+// LocalBookName is the display name of a local file before any metadata:
+// its base name without the extension.
+func LocalBookName(path string) string {
+	base := filepath.Base(path)
+	return base[:len(base)-len(bookExt(base))]
+}
+
+// LoadLocalBook converts the local file, or folder book, at path for the
+// reader.
+func LoadLocalBook(path string) (LocalBook, error) {
+	f, ok := localFormatOf(path)
+	if !ok {
+		return LocalBook{}, newError(KindParse, "book", path, errUnsupportedFormat)
+	}
+	book, err := f.load(path)
+	if err != nil {
+		return LocalBook{}, err
+	}
+	if book.Title == "" {
+		book.Title = LocalBookName(path)
+	}
+	return book, nil
+}
+
+// EncodingGuesses scores the encodings the local book at path may be in,
+// best first, see utils.DetectEncoding. Folder books are scored on their
+// first chapter file. Formats that are not read as plain text, like EPUB,
+// have none.
+func EncodingGuesses(path string) ([]utils.EncodingGuess, error) {
+	file := path
+	if files, ok := folderChapters(path); ok {
+		file = files[0]
+	}
+	switch bookExt(file) {
+	case ".txt", ".md", ".html", ".htm":
+	default:
+		return nil, nil
+	}
+	data, err := readLocalFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return utils.DetectEncoding(data), nil
+}
+
+// localBookInfo returns the title, author and metadata a local file carries
+// about itself, if its format has any.
+func localBookInfo(path string) (LocalBook, bool) {
+	f, ok := localFormatOf(path)
+	if !ok || f.info == nil {
+		return LocalBook{}, false
+	}
+	book, err := f.info(path)
+	if err != nil {
+		return LocalBook{}, false
+	}
+	return book, true
+}
+
+func loadTxtBook(path string) (LocalBook, error) {
+	data, err := readLocalFile(path)
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "txt", path, err)
+	}
+	book := LocalBook{Lines: utils.ExtractTextAs(data, utils.FileEncoding(path))}
+	// the title, author … of the header, see textHeader
+	header := textHeader(book.Lines)
+	book.Title, book.Author, book.BookMeta = header.Title, header.Author, header.BookMeta
+	return book, nil
+}
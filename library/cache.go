@@ -12,96 +12,98 @@ import (
 	"novel_reader/utils"
 )
 
+// CachedNovel is the meta.json of an online novel. The cache directory is
+// named after the book ID, so Title is the only place the name is kept.
+// See metaVersion for the schema history.
 type CachedNovel struct {
+	Version       int    `json:"version"`
+	ID            string `json:"id,omitempty"`
 	Title         string `json:"title"`
 	Author        string `json:"author"`
 	URL           string `json:"url"`
 	LastScraped   string `json:"last_scraped"`
 	TotalChapters int    `json:"total_chapters"`
+	BookMeta
 }
 
+// CacheDir holds one directory per cached online novel.
 func CacheDir() string {
-	return filepath.Join(os.Getenv("HOME"), ".config/novel_reader/cache")
+	return utils.CacheDir()
 }
 
-func NovelCachePath(title string) string {
-	return filepath.Join(CacheDir(), title)
+// NovelCachePath returns the cache directory of the book with the given ID.
+func NovelCachePath(id string) string {
+	return filepath.Join(CacheDir(), id)
 }
 
-func SaveChapter(title string, chapterNum int, content string) error {
-	dir := NovelCachePath(title)
-	os.MkdirAll(dir, 0755)
-	path := filepath.Join(dir, fmt.Sprintf("%d.txt", chapterNum))
-	return os.WriteFile(path, []byte(content), 0644)
-}
-
-func LoadChapter(title string, chapterNum int) (string, error) {
-	path := filepath.Join(NovelCachePath(title), fmt.Sprintf("%d.txt", chapterNum))
-	data, err := os.ReadFile(path)
-	return string(data), err
-}
-
-func SaveMeta(title string, meta CachedNovel) error {
-	dir := NovelCachePath(title)
-	os.MkdirAll(dir, 0755)
-	path := filepath.Join(dir, "meta.json")
-	f, err := os.Create(path)
-	if err != nil {
+func SaveMeta(id string, meta CachedNovel) error {
+	dir := NovelCachePath(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(meta)
+	meta.Version = metaVersion
+	if meta.ID == "" {
+		meta.ID = id
+	}
+	return writeJSON(filepath.Join(dir, "meta.json"), meta)
 }
 
-func LoadMeta(title string) (CachedNovel, error) {
+// LoadMeta reads meta.json, upgrading files from older versions in place.
+func LoadMeta(id string) (CachedNovel, error) {
 	var meta CachedNovel
-	path := filepath.Join(NovelCachePath(title), "meta.json")
-	f, err := os.Open(path)
-	if err != nil {
-		return meta, err
+	path := filepath.Join(NovelCachePath(id), "meta.json")
+	if err := readJSON(path, &meta); err != nil {
+		return CachedNovel{}, cacheReadError("meta", path, err)
+	}
+	if migrateMeta(id, &meta) {
+		writeJSON(path, meta) // best effort; the next load migrates again
 	}
-	defer f.Close()
-	err = json.NewDecoder(f).Decode(&meta)
-	return meta, err
+	return meta, nil
 }
 
-func chapterListPath(title string) string {
-	return filepath.Join(NovelCachePath(title), "chapters.json")
+func chapterListPath(id string) string {
+	return filepath.Join(NovelCachePath(id), "chapters.json")
 }
 
-func SaveChapterList(title string, chapters []ChapterLink) error {
+func SaveChapterList(id string, chapters []ChapterLink) error {
 	if len(chapters) == 0 {
 		return fmt.Errorf("no chapters to save")
 	}
 
-	dir := NovelCachePath(title)
+	dir := NovelCachePath(id)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	path := chapterListPath(title)
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+	return writeJSON(chapterListPath(id), chapters)
+}
 
-	return json.NewEncoder(f).Encode(chapters)
+func LoadChapterList(id string) ([]ChapterLink, error) {
+	path := chapterListPath(id)
+	var chapters []ChapterLink
+	if err := readJSON(path, &chapters); err != nil {
+		return nil, cacheReadError("toc", path, err)
+	}
+	return chapters, nil
 }
 
-func LoadChapterList(title string) ([]ChapterLink, error) {
-	path := chapterListPath(title)
-	f, err := os.Open(path)
+// writeJSON replaces a cache JSON file atomically, keeping the previous
+// copy as a .bak for readJSON.
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer f.Close()
+	return utils.WriteFileWithBackup(path, append(data, '\n'), 0644)
+}
 
-	var chapters []ChapterLink
-	if err := json.NewDecoder(f).Decode(&chapters); err != nil {
-		return nil, err
-	}
-	return chapters, nil
+// readJSON decodes a cache JSON file, falling back to its .bak copy when
+// the file is missing its tail or otherwise fails to parse.
+func readJSON(path string, v any) error {
+	_, _, err := utils.ReadFileRecover(path, func(data []byte) error {
+		return json.Unmarshal(data, v)
+	})
+	return err
 }
 
 // LoadAllCachedNovels returns all cached online novels as library.Novel structs
@@ -112,7 +114,7 @@ func LoadAllCachedNovels() ([]Novel, error) {
 		return nil, err
 	}
 
-	progressMap, _ := utils.Load()
+	progressMap := loadProgress()
 	var novels []Novel
 
 	for _, d := range dirs {
@@ -120,65 +122,104 @@ func LoadAllCachedNovels() ([]Novel, error) {
 			continue
 		}
 
-		dirPath := NovelCachePath(d.Name())
-		info, err := os.Stat(dirPath)
-		var dirMod time.Time
-		if err == nil {
-			dirMod = info.ModTime()
-		}
-
-		meta, err := LoadMeta(d.Name())
+		novel, err := loadCachedNovel(d.Name(), progressMap)
 		if err != nil {
 			continue
 		}
+		novels = append(novels, novel)
+	}
 
-		addedTime := dirMod
-		if meta.LastScraped != "" {
-			if t, err := time.Parse(time.RFC3339, meta.LastScraped); err == nil {
-				addedTime = t
-			}
-		}
+	return novels, nil
+}
 
-		novel := Novel{
-			Name:      d.Name(),
-			Path:      dirPath,
-			Latest:    "",
-			Current:   "",
-			Modified:  time.Now(), // will overwrite from progress if available
-			Added:     addedTime,
-			OnlineURL: meta.URL,
-			IsLocal:   false,
-		}
-		novel.Author = strings.TrimSpace(meta.Author)
+// HasCachedNovel reports whether a readable cached copy of the book exists.
+func HasCachedNovel(id string) bool {
+	if strings.TrimSpace(id) == "" {
+		return false
+	}
+	if _, err := LoadMeta(id); err != nil {
+		return false
+	}
+	return len(CachedChapterIndices(id)) > 0
+}
 
-		if chapters, err := LoadChapterList(d.Name()); err == nil && len(chapters) > 0 {
-			last := chapters[len(chapters)-1]
-			latestTitle := strings.TrimSpace(last.Title)
-			if latestTitle == "" {
-				latestTitle = lang.ChapterTitle(last.Index)
-			}
-			novel.Latest = latestTitle
+// LoadCachedNovel returns the cached online novel with the given ID.
+func LoadCachedNovel(id string) (Novel, error) {
+	return loadCachedNovel(id, loadProgress())
+}
+
+func loadCachedNovel(id string, progressMap map[string]utils.Progress) (Novel, error) {
+	dirPath := NovelCachePath(id)
+	info, err := os.Stat(dirPath)
+	var dirMod time.Time
+	if err == nil {
+		dirMod = info.ModTime()
+	}
+
+	meta, err := LoadMeta(id)
+	if err != nil {
+		return Novel{}, err
+	}
+	name := strings.TrimSpace(meta.Title)
+	if name == "" {
+		name = id
+	}
+
+	addedTime := dirMod
+	if meta.LastScraped != "" {
+		if t, err := time.Parse(time.RFC3339, meta.LastScraped); err == nil {
+			addedTime = t
 		}
+	}
 
-		if p, ok := utils.GetProgress(progressMap, novel.Name, "online"); ok {
-			if !p.LastRead.IsZero() {
-				novel.Modified = p.LastRead
-			}
-			if p.LastChapter != "" {
-				novel.Current = p.LastChapter
-			}
-		} else if meta.LastScraped != "" {
-			if t, err := time.Parse(time.RFC3339, meta.LastScraped); err == nil {
-				novel.Modified = t
+	novel := Novel{
+		ID:        id,
+		Name:      name,
+		Path:      dirPath,
+		Latest:    "",
+		Current:   "",
+		Modified:  time.Now(), // will overwrite from progress if available
+		Added:     addedTime,
+		OnlineURL: meta.URL,
+		IsLocal:   false,
+		BookMeta:  meta.BookMeta,
+	}
+	novel.Author = strings.TrimSpace(meta.Author)
+
+	if chapters, err := LoadChapterList(id); err == nil && len(chapters) > 0 {
+		last := chapters[len(chapters)-1]
+		latestTitle := strings.TrimSpace(last.Title)
+		if latestTitle == "" {
+			latestTitle = lang.ChapterTitle(last.Index)
+		}
+		novel.Latest = latestTitle
+
+		cached := CachedChapterIndices(id)
+		novel.FullyCached = true
+		for _, ch := range chapters {
+			if !cached[ch.Index] {
+				novel.FullyCached = false
+				break
 			}
 		}
+	}
 
-		if novel.Latest == "" {
-			novel.Latest = lang.ChapterTitle(meta.TotalChapters)
+	if p, ok := utils.GetProgress(progressMap, id); ok {
+		if !p.LastRead.IsZero() {
+			novel.Modified = p.LastRead
+		}
+		if p.LastChapter != "" {
+			novel.Current = p.LastChapter
+		}
+	} else if meta.LastScraped != "" {
+		if t, err := time.Parse(time.RFC3339, meta.LastScraped); err == nil {
+			novel.Modified = t
 		}
+	}
 
-		novels = append(novels, novel)
+	if novel.Latest == "" {
+		novel.Latest = lang.ChapterTitle(meta.TotalChapters)
 	}
 
-	return novels, nil
+	return novel, nil
 }
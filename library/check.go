@@ -0,0 +1,155 @@
+package library
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SelectorCheck is the outcome of one selector against a live or recorded page.
+type SelectorCheck struct {
+	Stage    string // "search", "info", "toc" or "chapter"
+	Selector string
+	Matches  int
+	Required bool // the scraper cannot work without at least one match
+}
+
+func (c SelectorCheck) OK() bool { return !c.Required || c.Matches > 0 }
+
+// StageError records a stage that could not be checked at all.
+type StageError struct {
+	Stage string
+	URL   string
+	Err   error
+}
+
+// SourceReport summarizes a search → toc → chapter run against the source.
+type SourceReport struct {
+	Query  string
+	Checks []SelectorCheck
+	Errors []StageError
+}
+
+// Failed returns the required selectors that matched nothing.
+func (r SourceReport) Failed() []SelectorCheck {
+	var out []SelectorCheck
+	for _, c := range r.Checks {
+		if !c.OK() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// OK reports whether every stage ran and every required selector matched.
+func (r SourceReport) OK() bool {
+	return len(r.Errors) == 0 && len(r.Failed()) == 0
+}
+
+// Print writes a human readable report to w.
+func (r SourceReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "source: %s  query: %q\n", BaseURL, r.Query)
+	for _, c := range r.Checks {
+		status := "ok"
+		switch {
+		case !c.OK():
+			status = "FAIL"
+		case c.Matches == 0:
+			status = "none"
+		}
+		fmt.Fprintf(w, "  %-4s %-8s %-24s %d\n", status, c.Stage, c.Selector, c.Matches)
+	}
+	for _, e := range r.Errors {
+		fmt.Fprintf(w, "  FAIL %-8s %s: %v\n", e.Stage, e.URL, e.Err)
+	}
+	if r.OK() {
+		fmt.Fprintln(w, "all required selectors matched")
+	}
+}
+
+func (r *SourceReport) check(stage string, sel *goquery.Selection, selector string, required bool) {
+	r.Checks = append(r.Checks, SelectorCheck{
+		Stage:    stage,
+		Selector: selector,
+		Matches:  sel.Length(),
+		Required: required,
+	})
+}
+
+func (r *SourceReport) fail(stage, url string, err error) SourceReport {
+	r.Errors = append(r.Errors, StageError{Stage: stage, URL: url, Err: err})
+	return *r
+}
+
+// CheckSource runs search → toc → chapter for query and reports which
+// selectors still match. It goes through the same HTTP client as the
+// scraper, so it works against recorded fixtures when replay is enabled.
+func CheckSource(query string) SourceReport {
+	r := SourceReport{Query: query}
+
+	// search
+	doc, err := fetchSearchPage(query)
+	if err != nil {
+		return r.fail("search", searchURL, err)
+	}
+	r.check("search", doc.Find(selSearchList), selSearchList, true)
+	rows := doc.Find(selSearchRow).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return s.Find("b").Length() == 0
+	})
+	r.check("search", rows, selSearchRow, true)
+	if rows.Length() == 0 {
+		return r
+	}
+	row := rows.First()
+	r.check("search", row.Find(selSearchCat), selSearchCat, false)
+	r.check("search", row.Find(selSearchTitle), selSearchTitle, true)
+	r.check("search", row.Find(selSearchLatest), selSearchLatest, false)
+	r.check("search", row.Find(selSearchAuthor), selSearchAuthor, false)
+	r.check("search", row.Find(selSearchUpdated), selSearchUpdated, false)
+
+	novelURL, _ := row.Find(selSearchTitle).Attr("href")
+	if novelURL == "" {
+		return r
+	}
+	if !strings.HasPrefix(novelURL, "http") {
+		novelURL = BaseURL + novelURL
+	}
+
+	// info page
+	info, err := fetchHTML("meta", novelURL)
+	if err != nil {
+		return r.fail("info", novelURL, err)
+	}
+	r.check("info", info.Find(selInfoLines), selInfoLines, false)
+	r.check("info", info.Find(selInfoDesc), selInfoDesc, false)
+	r.check("info", info.Find(selInfoCover), selInfoCover, false)
+
+	// toc
+	tocURL := novelURL + "1"
+	toc, err := fetchHTML("toc", tocURL)
+	if err != nil {
+		return r.fail("toc", tocURL, err)
+	}
+	lists := toc.Find(selTOCList)
+	r.check("toc", lists, selTOCList, true)
+	items := lists.Eq(1).Find(selTOCItem)
+	r.check("toc", items, selTOCList+" "+selTOCItem, true)
+	href, _ := items.First().Find("a").Attr("href")
+	if href == "" {
+		return r
+	}
+
+	// chapter
+	chapterURL := BaseURL + href
+	ch, err := fetchHTML("chapter", chapterURL)
+	if err != nil {
+		return r.fail("chapter", chapterURL, err)
+	}
+	r.check("chapter", ch.Find(selChapterBody), selChapterBody, true)
+	r.check("chapter", ch.Find(selChapterPara), selChapterPara, false)
+	r.check("chapter", ch.Find(selChapterHead), selChapterHead, false)
+
+	return r
+}
@@ -0,0 +1,424 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"novel_reader/utils"
+)
+
+// IssueKind is one kind of problem Diagnose can find.
+type IssueKind int
+
+const (
+	IssueBadMeta        IssueKind = iota // meta.json missing or unparsable
+	IssueBadTOC                          // chapters.json missing or unparsable
+	IssueBadIndex                        // chapters.idx unparsable
+	IssueTOCGap                          // chapter list indices skip numbers
+	IssueStrayChapter                    // cached chapter not in the chapter list
+	IssueEmptyChapter                    // cached chapter with no text
+	IssueOrphanDir                       // cache directory that is not a book
+	IssueBadProgress                     // progress.json unparsable
+	IssueOrphanProgress                  // progress entry for a book that is gone
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case IssueBadMeta:
+		return "bad-meta"
+	case IssueBadTOC:
+		return "bad-toc"
+	case IssueBadIndex:
+		return "bad-index"
+	case IssueTOCGap:
+		return "toc-gap"
+	case IssueStrayChapter:
+		return "stray-chapter"
+	case IssueEmptyChapter:
+		return "empty-chapter"
+	case IssueOrphanDir:
+		return "orphan-dir"
+	case IssueBadProgress:
+		return "bad-progress"
+	case IssueOrphanProgress:
+		return "orphan-progress"
+	default:
+		return "unknown"
+	}
+}
+
+// Fix is the repair Repair applies to an issue.
+type Fix int
+
+const (
+	FixNone           Fix = iota
+	FixRebuildMeta        // write meta.json from the chapter list
+	FixRebuildIndex       // rescan the pack for chapters.idx
+	FixRefreshTOC         // download the chapter list again
+	FixRefetchChapter     // download the chapter again
+	FixPrune              // delete the stray data
+)
+
+func (f Fix) String() string {
+	switch f {
+	case FixRebuildMeta:
+		return "rebuild meta"
+	case FixRebuildIndex:
+		return "rebuild index"
+	case FixRefreshTOC:
+		return "refresh chapter list"
+	case FixRefetchChapter:
+		return "re-fetch chapter"
+	case FixPrune:
+		return "prune"
+	default:
+		return "none"
+	}
+}
+
+// Issue is one finding of Diagnose.
+type Issue struct {
+	Kind    IssueKind
+	Fix     Fix
+	BookID  string // cache directory or progress key
+	Title   string
+	Chapter int // 1-based, for chapter issues
+	Err     error
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-15s %s", i.Kind, i.BookID)
+	if i.Title != "" && i.Title != i.BookID {
+		fmt.Fprintf(&b, " (%s)", i.Title)
+	}
+	if i.Chapter > 0 {
+		fmt.Fprintf(&b, " chapter %d", i.Chapter)
+	}
+	if i.Err != nil {
+		fmt.Fprintf(&b, ": %v", i.Err)
+	}
+	return b.String()
+}
+
+// DoctorReport is the result of scanning the cache and progress files.
+type DoctorReport struct {
+	Books  int
+	Issues []Issue
+}
+
+// Fixable returns the issues Repair can do something about.
+func (r DoctorReport) Fixable() []Issue {
+	var out []Issue
+	for _, i := range r.Issues {
+		if i.Fix != FixNone {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Print writes a human readable report to w.
+func (r DoctorReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "cache: %s\n", CacheDir())
+	fmt.Fprintf(w, "books: %d  issues: %d  fixable: %d\n", r.Books, len(r.Issues), len(r.Fixable()))
+	for _, i := range r.Issues {
+		fmt.Fprintf(w, "  %s  [fix: %s]\n", i, i.Fix)
+	}
+}
+
+// Diagnose scans the cache and progress files and changes nothing, except
+// that reading the chapters of a book rebuilds an unreadable chapters.idx
+// once the issue has been reported.
+func Diagnose() (DoctorReport, error) {
+	var r DoctorReport
+
+	dirs, err := os.ReadDir(CacheDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return r, err
+	}
+	progressMap, progressErr := utils.Load()
+	if progressErr != nil {
+		r.Issues = append(r.Issues, Issue{Kind: IssueBadProgress, Fix: FixPrune, BookID: "progress.json", Err: progressErr})
+		progressMap = map[string]utils.Progress{}
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		id := d.Name()
+		issues, isBook := diagnoseBook(id, progressMap)
+		if isBook {
+			r.Books++
+		}
+		r.Issues = append(r.Issues, issues...)
+	}
+
+	if progressErr == nil {
+		r.Issues = append(r.Issues, orphanProgress(progressMap)...)
+	}
+	return r, nil
+}
+
+func diagnoseBook(id string, progressMap map[string]utils.Progress) ([]Issue, bool) {
+	title := id
+	if p, ok := progressMap[id]; ok && p.Title != "" {
+		title = p.Title
+	}
+
+	meta, metaErr := LoadMeta(id)
+	if metaErr == nil && meta.Title != "" {
+		title = meta.Title
+	}
+	chapters, tocErr := LoadChapterList(id)
+	_, _, idxErr := loadPackIndex(NovelCachePath(id))
+	cached := CachedChapterIndices(id)
+
+	if !IsBookID(id) || (metaErr != nil && tocErr != nil && len(cached) == 0) {
+		return []Issue{{Kind: IssueOrphanDir, Fix: FixPrune, BookID: id, Title: title}}, false
+	}
+
+	var issues []Issue
+	add := func(i Issue) {
+		i.BookID, i.Title = id, title
+		issues = append(issues, i)
+	}
+
+	if metaErr != nil {
+		fix := FixNone
+		if tocErr == nil && novelURLFromChapters(id, chapters) != "" {
+			fix = FixRebuildMeta
+		}
+		add(Issue{Kind: IssueBadMeta, Fix: fix, Err: metaErr})
+	}
+	refresh := FixNone
+	if metaErr == nil && meta.URL != "" {
+		refresh = FixRefreshTOC
+	}
+	if tocErr != nil {
+		add(Issue{Kind: IssueBadTOC, Fix: refresh, Err: tocErr})
+	}
+	if idxErr != nil {
+		add(Issue{Kind: IssueBadIndex, Fix: FixRebuildIndex, Err: idxErr})
+	}
+
+	if tocErr == nil {
+		inList := make(map[int]bool, len(chapters))
+		sorted := make([]int, 0, len(chapters))
+		for _, ch := range chapters {
+			inList[ch.Index] = true
+			sorted = append(sorted, ch.Index)
+		}
+		sort.Ints(sorted)
+		for i, n := range sorted {
+			if n != i+1 {
+				add(Issue{Kind: IssueTOCGap, Fix: refresh, Chapter: i + 1})
+				break
+			}
+		}
+		var stray []int
+		for n := range cached {
+			if !inList[n] {
+				stray = append(stray, n)
+			}
+		}
+		sort.Ints(stray)
+		for _, n := range stray {
+			add(Issue{Kind: IssueStrayChapter, Fix: FixPrune, Chapter: n})
+		}
+	}
+
+	if texts, err := LoadCachedChapters(id); err == nil {
+		for _, ch := range texts {
+			if strings.TrimSpace(ch.Text) == "" {
+				add(Issue{Kind: IssueEmptyChapter, Fix: FixRefetchChapter, Chapter: ch.Index})
+			}
+		}
+	}
+	return issues, true
+}
+
+// novelURLFromChapters recovers the book URL from a chapter link, e.g.
+// https://host/biqu3628/123.html → https://host/biqu3628/, and checks that it
+// maps back to id.
+func novelURLFromChapters(id string, chapters []ChapterLink) string {
+	for _, ch := range chapters {
+		u, err := url.Parse(ch.Link)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		book := strings.Trim(u.Path, "/")
+		if i := strings.Index(book, "/"); i >= 0 {
+			book = book[:i]
+		}
+		novelURL := u.Scheme + "://" + u.Host + "/" + book + "/"
+		if OnlineBookID(novelURL) == id {
+			return novelURL
+		}
+	}
+	return ""
+}
+
+// orphanProgress reports progress entries whose book is gone. Online books
+// are gone with their cache directory and can be pruned. Local books are
+// only reported: a book that looks gone may be on a drive that is not
+// there right now, or have a new ID since its head was edited. When a
+// library folder cannot be read at all, local entries are not checked.
+func orphanProgress(progressMap map[string]utils.Progress) []Issue {
+	var localIDs map[string]bool
+	var localErr error
+	var issues []Issue
+	keys := make([]string, 0, len(progressMap))
+	for k := range progressMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		p := progressMap[key]
+		orphan, fix := false, FixPrune
+		switch {
+		case !IsBookID(key):
+			orphan = true // legacy key the ID migration could not match
+		case strings.HasPrefix(key, "o"):
+			_, err := os.Stat(NovelCachePath(key))
+			orphan = errors.Is(err, os.ErrNotExist)
+		default:
+			if localIDs == nil && localErr == nil {
+				localIDs, localErr = localBookIDs()
+			}
+			orphan, fix = localErr == nil && !localIDs[key], FixNone
+		}
+		if orphan {
+			issues = append(issues, Issue{Kind: IssueOrphanProgress, Fix: fix, BookID: key, Title: p.Title})
+		}
+	}
+	return issues
+}
+
+// localBookIDs returns the IDs of every book in the library folders. It
+// fails when a folder is missing or cannot be read, e.g. an unmounted
+// share, whose books would otherwise all look gone.
+func localBookIDs() (map[string]bool, error) {
+	ids := make(map[string]bool)
+	for _, dir := range utils.AppConfig.Library.Paths {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		err := walkLocalBooks(dir, func(path string) error {
+			if id, err := LocalBookID(path); err == nil {
+				ids[id] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// Repair applies the fix of every issue and returns how many succeeded
+// along with the errors of the rest. Re-fetching needs the network.
+func Repair(issues []Issue) (int, []error) {
+	if utils.ReadOnly() {
+		return 0, []error{utils.ErrReadOnly}
+	}
+	fixed := 0
+	var errs []error
+	for _, i := range issues {
+		if i.Fix == FixNone {
+			continue
+		}
+		if err := repair(i); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", i, err))
+			continue
+		}
+		fixed++
+	}
+	return fixed, errs
+}
+
+func repair(i Issue) error {
+	switch i.Fix {
+	case FixRebuildMeta:
+		chapters, err := LoadChapterList(i.BookID)
+		if err != nil {
+			return err
+		}
+		novelURL := novelURLFromChapters(i.BookID, chapters)
+		if novelURL == "" {
+			return fmt.Errorf("no chapter link leads back to the book")
+		}
+		return SaveMeta(i.BookID, CachedNovel{
+			ID:            i.BookID,
+			Title:         i.Title,
+			URL:           novelURL,
+			LastScraped:   time.Now().Format(time.RFC3339),
+			TotalChapters: len(chapters),
+		})
+
+	case FixRebuildIndex:
+		_, err := RebuildPackIndex(i.BookID)
+		return err
+
+	case FixRefreshTOC:
+		meta, err := LoadMeta(i.BookID)
+		if err != nil {
+			return err
+		}
+		if NetworkDisabled() {
+			return offlineError("toc", meta.URL)
+		}
+		chapters, err := GetChapterLinks(meta.URL, "")
+		if err != nil {
+			return err
+		}
+		if len(chapters) == 0 {
+			return newError(KindParse, "toc", meta.URL, errNoChapters)
+		}
+		return SaveChapterList(i.BookID, chapters)
+
+	case FixRefetchChapter:
+		chapters, err := LoadChapterList(i.BookID)
+		if err != nil {
+			return err
+		}
+		for _, ch := range chapters {
+			if ch.Index != i.Chapter {
+				continue
+			}
+			if NetworkDisabled() {
+				return offlineError("chapter", ch.Link)
+			}
+			content, err := ScrapeChapterWithSubpages(ch)
+			if err != nil {
+				return err
+			}
+			return SaveChapter(i.BookID, ch.Index, content)
+		}
+		return newError(KindNotFound, "chapter", "", fmt.Errorf("chapter %d is not in the chapter list", i.Chapter))
+
+	case FixPrune:
+		switch i.Kind {
+		case IssueOrphanDir:
+			_, err := RemoveNovelCache(i.BookID)
+			return err
+		case IssueStrayChapter:
+			_, err := DeleteChapters(i.BookID, []int{i.Chapter})
+			return err
+		case IssueOrphanProgress:
+			return utils.DeleteProgress(i.BookID)
+		case IssueBadProgress:
+			return utils.SetAsideProgress()
+		}
+	}
+	return fmt.Errorf("no fix for %s", i.Kind)
+}
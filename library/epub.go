@@ -0,0 +1,392 @@
+package library
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// EPUB 2 and 3 support. The OPF package gives the metadata and the spine
+// (reading order); the table of contents comes from the EPUB 3 nav document
+// or, failing that, the EPUB 2 NCX. Chapters are XHTML converted to
+// paragraphs with bookText.
+
+var errNotEPUB = errors.New("not an EPUB: META-INF/container.xml has no rootfile")
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles      []string `xml:"title"`
+		Creators    []string `xml:"creator"`
+		Description string   `xml:"description"`
+		Subjects    []string `xml:"subject"`
+		Dates       []string `xml:"date"`
+	} `xml:"metadata"`
+	Manifest []opfItem `xml:"manifest>item"`
+	Spine    struct {
+		TOC      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type opfItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// epubNavPoint is one TOC entry: a title and the zip path plus fragment
+// it links to.
+type epubNavPoint struct {
+	Title    string
+	File     string
+	Fragment string
+	Depth    int
+}
+
+// epubBook is an opened EPUB with its package document parsed.
+type epubBook struct {
+	zr    *zip.ReadCloser
+	files map[string]*zip.File
+	opf   opfPackage
+	path  string // of the OPF; manifest hrefs are relative to it
+	items map[string]opfItem
+}
+
+func openEPUB(filePath string) (*epubBook, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, newError(KindParse, "epub", filePath, err)
+	}
+	b := &epubBook{zr: zr, files: make(map[string]*zip.File), items: make(map[string]opfItem)}
+	for _, f := range zr.File {
+		b.files[f.Name] = f
+	}
+
+	var container epubContainer
+	if err := b.decode("META-INF/container.xml", &container); err != nil {
+		zr.Close()
+		return nil, newError(KindParse, "epub", filePath, err)
+	}
+	opfPath := ""
+	for _, rf := range container.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			opfPath = rf.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		zr.Close()
+		return nil, newError(KindParse, "epub", filePath, errNotEPUB)
+	}
+	if err := b.decode(opfPath, &b.opf); err != nil {
+		zr.Close()
+		return nil, newError(KindParse, "epub", filePath, err)
+	}
+	b.path = opfPath
+	for _, it := range b.opf.Manifest {
+		b.items[it.ID] = it
+	}
+	return b, nil
+}
+
+func (b *epubBook) Close() error {
+	return b.zr.Close()
+}
+
+func (b *epubBook) open(name string) (io.ReadCloser, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: not in the archive", name)
+	}
+	return f.Open()
+}
+
+func (b *epubBook) decode(name string, v any) error {
+	rc, err := b.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := newXMLDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// resolveHref turns an href found in the document at base into a zip path and
+// a fragment.
+func resolveHref(base, href string) (string, string) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return path.Join(path.Dir(base), href), ""
+	}
+	if u.Path == "" {
+		return base, u.Fragment
+	}
+	return path.Join(path.Dir(base), u.Path), u.Fragment
+}
+
+func (b *epubBook) itemPath(it opfItem) string {
+	p, _ := resolveHref(b.path, it.Href)
+	return p
+}
+
+// spine returns the zip paths of the reading order. Items marked
+// linear="no", such as notes and answer keys, come after the main text so
+// links to them still resolve.
+func (b *epubBook) spine() []string {
+	var out, aside []string
+	for _, ref := range b.opf.Spine.ItemRefs {
+		it, ok := b.items[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if strings.TrimSpace(ref.Linear) == "no" {
+			aside = append(aside, b.itemPath(it))
+		} else {
+			out = append(out, b.itemPath(it))
+		}
+	}
+	return append(out, aside...)
+}
+
+func (b *epubBook) meta() LocalBook {
+	md := b.opf.Metadata
+	book := LocalBook{}
+	if len(md.Titles) > 0 {
+		book.Title = collapseSpace(md.Titles[0])
+	}
+	if len(md.Creators) > 0 {
+		book.Author = collapseSpace(md.Creators[0])
+	}
+	book.Synopsis = cleanSynopsis(stripTags(md.Description))
+	for _, s := range md.Subjects {
+		if s = collapseSpace(s); s != "" {
+			book.Tags = append(book.Tags, s)
+		}
+	}
+	if len(md.Dates) > 0 {
+		book.FirstUpdate = parseUpdateTime(md.Dates[0])
+	}
+	return book
+}
+
+// stripTags drops markup some books put in dc:description.
+func stripTags(s string) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+	var t bookText
+	if err := appendXHTML(&t, strings.NewReader("<div>"+s+"</div>"), nil); err != nil {
+		return s
+	}
+	return strings.TrimSpace(strings.ReplaceAll(strings.Join(t.lines, "\n"), cjkIndent, ""))
+}
+
+// navPoints returns the book's table of contents in reading order, nested
+// entries flattened.
+func (b *epubBook) navPoints() []epubNavPoint {
+	for _, it := range b.opf.Manifest {
+		if strings.Contains(" "+it.Properties+" ", " nav ") {
+			if points := b.navDocument(b.itemPath(it)); len(points) > 0 {
+				return points
+			}
+		}
+	}
+	ncx, ok := b.items[b.opf.Spine.TOC]
+	if !ok {
+		for _, it := range b.opf.Manifest {
+			if it.MediaType == "application/x-dtbncx+xml" {
+				ncx, ok = it, true
+				break
+			}
+		}
+	}
+	if ok {
+		return b.ncxDocument(b.itemPath(ncx))
+	}
+	return nil
+}
+
+// navDocument reads the <nav epub:type="toc"> list of an EPUB 3 nav file.
+func (b *epubBook) navDocument(name string) []epubNavPoint {
+	rc, err := b.open(name)
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+
+	var (
+		points  []epubNavPoint
+		inNav   bool
+		navSeen bool
+		depth   int // nesting inside the toc nav
+		lists   int // open <ol> elements inside it
+		link    *epubNavPoint
+		label   strings.Builder
+	)
+	d := newXMLDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			if inNav {
+				depth++
+				if el.Name.Local == "ol" {
+					lists++
+				}
+				if el.Name.Local == "a" {
+					link = &epubNavPoint{Depth: max(lists-1, 0)}
+					for _, a := range el.Attr {
+						if a.Name.Local == "href" {
+							link.File, link.Fragment = resolveHref(name, a.Value)
+						}
+					}
+					label.Reset()
+				}
+				continue
+			}
+			if el.Name.Local == "nav" && !navSeen {
+				for _, a := range el.Attr {
+					if a.Name.Local == "type" && strings.Contains(a.Value, "toc") {
+						inNav, navSeen, depth = true, true, 0
+					}
+				}
+			}
+		case xml.EndElement:
+			if !inNav {
+				continue
+			}
+			if depth == 0 {
+				inNav = false
+				continue
+			}
+			depth--
+			if el.Name.Local == "ol" {
+				lists--
+			}
+			if el.Name.Local == "a" && link != nil {
+				link.Title = collapseSpace(label.String())
+				if link.Title != "" && link.File != "" {
+					points = append(points, *link)
+				}
+				link = nil
+			}
+		case xml.CharData:
+			if link != nil {
+				label.Write(el)
+			}
+		}
+	}
+	return points
+}
+
+type ncxDoc struct {
+	Points []ncxNavPoint `xml:"navMap>navPoint"`
+}
+
+type ncxNavPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Points []ncxNavPoint `xml:"navPoint"`
+}
+
+// ncxDocument reads the navMap of an EPUB 2 toc.ncx.
+func (b *epubBook) ncxDocument(name string) []epubNavPoint {
+	var doc ncxDoc
+	if err := b.decode(name, &doc); err != nil {
+		return nil
+	}
+	var points []epubNavPoint
+	var walk func([]ncxNavPoint, int)
+	walk = func(list []ncxNavPoint, depth int) {
+		for _, p := range list {
+			title := collapseSpace(p.Label)
+			if title != "" && p.Content.Src != "" {
+				file, frag := resolveHref(name, p.Content.Src)
+				points = append(points, epubNavPoint{Title: title, File: file, Fragment: frag, Depth: depth})
+			}
+			walk(p.Points, depth+1)
+		}
+	}
+	walk(doc.Points, 0)
+	return points
+}
+
+// loadEPUB converts the spine to reader lines and maps the navigation onto
+// them. Without navigation the TOC stays nil and the reader falls back to
+// detecting chapter headings. It is the info of EPUB books as well: which
+// navigation entries make the TOC depends on where they land in the text,
+// and the library should count the chapters the reader shows.
+func loadEPUB(filePath string) (LocalBook, error) {
+	b, err := openEPUB(filePath)
+	if err != nil {
+		return LocalBook{}, err
+	}
+	defer b.Close()
+	book := b.meta()
+
+	var text bookText
+	fileStart := make(map[string]int)
+	anchors := make(map[string]int)
+	for _, name := range b.spine() {
+		if _, seen := fileStart[name]; seen {
+			continue
+		}
+		fileStart[name] = text.next()
+		rc, err := b.open(name)
+		if err != nil {
+			continue // a missing spine item should not cost the whole book
+		}
+		appendXHTML(&text, rc, func(id string, line int) {
+			anchors[name+"#"+id] = line
+		})
+		rc.Close()
+	}
+	if len(text.lines) == 0 {
+		return LocalBook{}, newError(KindParse, "epub", filePath, errors.New("no readable text in the spine"))
+	}
+	book.Lines = text.lines
+
+	last := -1
+	for _, p := range b.navPoints() {
+		line, ok := anchors[p.File+"#"+p.Fragment]
+		if !ok || p.Fragment == "" {
+			line, ok = fileStart[p.File]
+		}
+		if !ok || line >= len(book.Lines) || line < last {
+			continue // outside the spine or out of reading order
+		}
+		if line == last && len(book.TOC) > 0 {
+			// several entries on one spot, e.g. a part and its first chapter:
+			// keep the innermost, which comes last
+			book.TOC[len(book.TOC)-1].Title = p.Title
+			book.TOC[len(book.TOC)-1].Depth = p.Depth
+			continue
+		}
+		book.TOC = append(book.TOC, BookChapter{Title: p.Title, Line: line, Depth: p.Depth})
+		last = line
+	}
+	return book, nil
+}
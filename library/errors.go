@@ -0,0 +1,239 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"novel_reader/utils"
+)
+
+// ErrorKind classifies failures from library operations so the UI can decide
+// which recovery actions to offer.
+type ErrorKind int
+
+const (
+	KindUnknown ErrorKind = iota
+	KindNetwork
+	KindHTTPStatus
+	KindRateLimited
+	KindParse
+	KindNotFound
+	KindCacheCorrupt
+	KindOffline
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNetwork:
+		return "network"
+	case KindHTTPStatus:
+		return "http-status"
+	case KindRateLimited:
+		return "rate-limited"
+	case KindParse:
+		return "parse"
+	case KindNotFound:
+		return "not-found"
+	case KindCacheCorrupt:
+		return "cache-corrupt"
+	case KindOffline:
+		return "offline"
+	default:
+		return "unknown"
+	}
+}
+
+// RecoveryAction is a user-facing way out of a failed operation.
+type RecoveryAction int
+
+const (
+	ActionRetry RecoveryAction = iota
+	ActionSwitchSource
+	ActionOpenCached
+	ActionReport
+)
+
+// Error is the typed error returned by scraping and cache operations.
+type Error struct {
+	Kind       ErrorKind
+	Op         string // "search", "toc", "chapter", "meta", ...
+	URL        string
+	Status     int           // HTTP status for KindHTTPStatus / KindRateLimited
+	RetryAfter time.Duration // hint from the server when rate limited
+	Err        error
+}
+
+func (e *Error) Error() string {
+	msg := e.Op
+	if msg == "" {
+		msg = "library"
+	}
+	switch e.Kind {
+	case KindHTTPStatus, KindRateLimited:
+		msg += fmt.Sprintf(": status %d", e.Status)
+	default:
+		msg += ": " + e.Kind.String()
+	}
+	if e.URL != "" {
+		msg += " (" + e.URL + ")"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Actions lists the recovery actions that make sense for this error,
+// most useful first.
+func (e *Error) Actions() []RecoveryAction {
+	switch e.Kind {
+	case KindNetwork:
+		return []RecoveryAction{ActionRetry, ActionOpenCached}
+	case KindRateLimited:
+		return []RecoveryAction{ActionRetry, ActionOpenCached, ActionSwitchSource}
+	case KindHTTPStatus:
+		if e.Status >= 500 {
+			return []RecoveryAction{ActionRetry, ActionOpenCached, ActionSwitchSource}
+		}
+		return []RecoveryAction{ActionSwitchSource, ActionOpenCached, ActionReport}
+	case KindParse:
+		return []RecoveryAction{ActionSwitchSource, ActionOpenCached, ActionReport}
+	case KindNotFound:
+		return []RecoveryAction{ActionSwitchSource, ActionReport}
+	case KindCacheCorrupt:
+		return []RecoveryAction{ActionRetry, ActionReport}
+	case KindOffline:
+		return []RecoveryAction{ActionOpenCached}
+	default:
+		return []RecoveryAction{ActionRetry, ActionReport}
+	}
+}
+
+// errOffline is wrapped in KindOffline errors returned instead of a request.
+var errOffline = errors.New("offline mode is enabled")
+
+func offlineError(op, url string) *Error {
+	return newError(KindOffline, op, url, errOffline)
+}
+
+// OfflineError returns the error used when op would need the network while
+// offline mode is on.
+func OfflineError(op string) error {
+	return offlineError(op, "")
+}
+
+func newError(kind ErrorKind, op, url string, err error) *Error {
+	return &Error{Kind: kind, Op: op, URL: url, Err: err}
+}
+
+// KindOf returns the ErrorKind of err, or KindUnknown for untyped errors.
+func KindOf(err error) ErrorKind {
+	var le *Error
+	if errors.As(err, &le) {
+		return le.Kind
+	}
+	return KindUnknown
+}
+
+// ActionsFor returns the recovery actions for any error.
+func ActionsFor(err error) []RecoveryAction {
+	if err == nil {
+		return nil
+	}
+	var le *Error
+	if errors.As(err, &le) {
+		return le.Actions()
+	}
+	return []RecoveryAction{ActionRetry, ActionReport}
+}
+
+// classifyRequestError wraps a transport error from http.Client.Do. Anything
+// that fails before a response arrives (DNS, refused, timeout) counts as the
+// network being unreachable.
+func classifyRequestError(op, url string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var le *Error
+	if errors.As(err, &le) {
+		return err
+	}
+	return newError(KindNetwork, op, url, err)
+}
+
+// checkResponse turns a non-2xx response into a typed error.
+func checkResponse(op, url string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	e := &Error{Kind: KindHTTPStatus, Op: op, URL: url, Status: resp.StatusCode}
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		e.Kind = KindNotFound
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "" {
+			e.Kind = KindRateLimited
+		}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+	}
+	return e
+}
+
+// cacheReadError classifies a failure reading a cached JSON file.
+func cacheReadError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return newError(KindNotFound, op, path, err)
+	}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return err
+	}
+	return newError(KindCacheCorrupt, op, path, err)
+}
+
+// WriteErrorReport saves the details of err to a timestamped file in the
+// data directory so it can be attached to a bug report. It returns the file path.
+func WriteErrorReport(err error) (string, error) {
+	if err == nil {
+		return "", errors.New("no error to report")
+	}
+	dir := filepath.Join(utils.DataDir(), "reports")
+	if mkErr := os.MkdirAll(dir, 0755); mkErr != nil {
+		return "", mkErr
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "kind: %s\n", KindOf(err))
+	var le *Error
+	if errors.As(err, &le) {
+		fmt.Fprintf(&b, "op: %s\n", le.Op)
+		if le.URL != "" {
+			fmt.Fprintf(&b, "url: %s\n", le.URL)
+		}
+		if le.Status != 0 {
+			fmt.Fprintf(&b, "status: %d\n", le.Status)
+		}
+	}
+	fmt.Fprintf(&b, "error: %v\n", err)
+
+	path := filepath.Join(dir, "error-"+now.Format("20060102-150405")+".txt")
+	if wErr := os.WriteFile(path, []byte(b.String()), 0644); wErr != nil {
+		return "", wErr
+	}
+	return path, nil
+}
@@ -0,0 +1,314 @@
+package library
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// FictionBook 2 support, plain or zipped. <description> gives the
+// metadata; every <section> with a <title> becomes a TOC entry nested as
+// deep as the section. Footnote bodies are appended after the text and
+// their links shown as [1].
+
+var errNoFB2 = errors.New("no .fb2 file in the archive")
+
+type fb2Description struct {
+	TitleInfo struct {
+		Genres     []string    `xml:"genre"`
+		Authors    []fb2Author `xml:"author"`
+		BookTitle  string      `xml:"book-title"`
+		Annotation struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"annotation"`
+		Keywords string `xml:"keywords"`
+		Date     string `xml:"date"`
+	} `xml:"title-info"`
+}
+
+type fb2Author struct {
+	First    string `xml:"first-name"`
+	Middle   string `xml:"middle-name"`
+	Last     string `xml:"last-name"`
+	Nickname string `xml:"nickname"`
+}
+
+func (a fb2Author) String() string {
+	name := collapseSpace(strings.Join([]string{a.First, a.Middle, a.Last}, " "))
+	if name == "" {
+		return collapseSpace(a.Nickname)
+	}
+	return name
+}
+
+func (d fb2Description) meta() LocalBook {
+	ti := d.TitleInfo
+	book := LocalBook{Title: collapseSpace(ti.BookTitle)}
+	var authors []string
+	for _, a := range ti.Authors {
+		if name := a.String(); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	book.Author = strings.Join(authors, ", ")
+	book.Synopsis = cleanSynopsis(stripTags(ti.Annotation.Inner))
+	for _, g := range ti.Genres {
+		if g = collapseSpace(g); g != "" {
+			book.Tags = append(book.Tags, g)
+		}
+	}
+	for _, k := range strings.Split(ti.Keywords, ",") {
+		if k = collapseSpace(k); k != "" {
+			book.Tags = append(book.Tags, k)
+		}
+	}
+	if len(ti.Genres) > 0 {
+		book.Category = collapseSpace(ti.Genres[0])
+	}
+	book.FirstUpdate = parseUpdateTime(collapseSpace(ti.Date))
+	return book
+}
+
+// openFB2 returns the FictionBook document in path, unpacking .fb2.zip.
+func openFB2(path string) (io.ReadCloser, error) {
+	if bookExt(path) != ".fb2.zip" {
+		return os.Open(path)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if strings.HasSuffix(strings.ToLower(f.Name), ".fb2") {
+			rc, err := f.Open()
+			if err != nil {
+				zr.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{rc, closeBoth{rc, zr}}, nil
+		}
+	}
+	zr.Close()
+	return nil, errNoFB2
+}
+
+type closeBoth [2]io.Closer
+
+func (c closeBoth) Close() error {
+	err := c[0].Close()
+	if err2 := c[1].Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// fb2Parser walks the bodies of a FictionBook.
+type fb2Parser struct {
+	text     bookText
+	toc      []BookChapter
+	sections int              // nesting depth of <section>
+	notes    bool             // inside a footnote body
+	title    *strings.Builder // text of the open section or body title
+	titleAt  int
+	links    []bool   // open <a> elements, true for footnote links
+	stack    []string // open elements
+}
+
+// loadFB2 is also the format's info function: the TOC is only known after
+// reading the bodies, and FB2 files are small enough to parse whole.
+func loadFB2(path string) (LocalBook, error) {
+	rc, err := openFB2(path)
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "fb2", path, err)
+	}
+	defer rc.Close()
+
+	var book LocalBook
+	var p fb2Parser
+	d := newXMLDecoder(rc)
+	skip := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(p.text.lines) > 0 {
+				break // keep what was read of a truncated file
+			}
+			return LocalBook{}, newError(KindParse, "fb2", path, err)
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			name := el.Name.Local
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch name {
+			case "description":
+				var desc fb2Description
+				if err := d.DecodeElement(&desc, &el); err != nil {
+					return LocalBook{}, newError(KindParse, "fb2", path, err)
+				}
+				book = desc.meta()
+			case "binary", "stylesheet":
+				skip = 1
+			default:
+				p.start(el)
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			p.end(el.Name.Local)
+		case xml.CharData:
+			if skip == 0 {
+				p.chars(string(el))
+			}
+		}
+	}
+	p.text.flush()
+	if len(p.text.lines) == 0 {
+		return LocalBook{}, newError(KindParse, "fb2", path, errors.New("no readable text in the bodies"))
+	}
+	book.Lines = p.text.lines
+	book.TOC = p.toc
+	return book, nil
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (p *fb2Parser) start(el xml.StartElement) {
+	t := &p.text
+	parent := ""
+	if n := len(p.stack); n > 0 {
+		parent = p.stack[n-1]
+	}
+	p.stack = append(p.stack, el.Name.Local)
+	switch el.Name.Local {
+	case "body":
+		t.blank()
+		name := attr(el, "name")
+		p.notes = name == "notes" || name == "comments"
+	case "section":
+		t.flush()
+		p.sections++
+	case "title":
+		t.flush()
+		t.heading++
+		if parent == "section" || parent == "body" {
+			p.title = &strings.Builder{}
+			p.titleAt = t.next()
+		}
+	case "subtitle":
+		t.flush()
+		t.heading++
+	case "p", "v", "text-author", "td", "th":
+		t.flush()
+		if el.Name.Local == "text-author" {
+			t.write("— ")
+		}
+	case "empty-line":
+		t.blank()
+	case "epigraph", "cite":
+		t.flush()
+		t.quote++
+	case "poem":
+		t.blank()
+		t.verse++
+	case "stanza":
+		t.blank()
+	case "a":
+		note := attr(el, "type") == "note"
+		p.links = append(p.links, note)
+		if note {
+			t.write("[")
+		}
+	}
+}
+
+func (p *fb2Parser) end(name string) {
+	t := &p.text
+	if n := len(p.stack); n > 0 {
+		p.stack = p.stack[:n-1]
+	}
+	switch name {
+	case "section":
+		t.flush()
+		if p.sections > 0 {
+			p.sections--
+		}
+	case "title":
+		t.flush()
+		if t.heading > 0 {
+			t.heading--
+		}
+		if p.title != nil {
+			title := collapseSpace(p.title.String())
+			// section titles are chapters; of the notes only the body title
+			// is listed, and the main body title is just the book's name
+			switch {
+			case title == "":
+			case !p.notes && p.sections > 0:
+				p.toc = append(p.toc, BookChapter{Title: title, Line: p.titleAt, Depth: p.sections - 1})
+			case p.notes && p.sections == 0:
+				p.toc = append(p.toc, BookChapter{Title: title, Line: p.titleAt})
+			}
+			p.title = nil
+		}
+	case "subtitle":
+		t.flush()
+		if t.heading > 0 {
+			t.heading--
+		}
+	case "p", "v", "text-author", "td", "th":
+		t.flush()
+		if p.title != nil {
+			p.title.WriteByte(' ')
+		}
+	case "tr":
+		t.flush()
+	case "epigraph", "cite":
+		t.flush()
+		if t.quote > 0 {
+			t.quote--
+		}
+		t.blank()
+	case "poem":
+		t.blank()
+		if t.verse > 0 {
+			t.verse--
+		}
+	case "stanza":
+		t.blank()
+	case "a":
+		if n := len(p.links); n > 0 {
+			if p.links[n-1] {
+				t.write("]")
+			}
+			p.links = p.links[:n-1]
+		}
+	}
+}
+
+func (p *fb2Parser) chars(s string) {
+	p.text.write(s)
+	if p.title != nil {
+		p.title.WriteString(s)
+	}
+}
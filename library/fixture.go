@@ -0,0 +1,232 @@
+package library
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"novel_reader/utils"
+)
+
+// fixture is one recorded HTTP exchange. Bodies are []byte so they survive
+// any page encoding; encoding/json stores them as base64.
+type fixture struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	ReqBody    []byte      `json:"request_body,omitempty"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	RecordedAt string      `json:"recorded_at"`
+}
+
+// fixtureKey names the file for a request: the same method, URL and body
+// always map to the same fixture.
+func fixtureKey(method, rawURL string, body []byte) string {
+	h := sha1.New()
+	io.WriteString(h, method+" "+rawURL+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))[:20] + ".json"
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// ----------------------------
+// RECORD
+// ----------------------------
+
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fx := fixture{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		ReqBody:    reqBody,
+		Status:     resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		RecordedAt: time.Now().Format(time.RFC3339),
+	}
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(t.dir, fixtureKey(fx.Method, fx.URL, reqBody))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// EnableRecording saves every HTTP exchange the scraper makes to dir.
+func EnableRecording(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &recordingTransport{dir: dir, next: next}
+	return nil
+}
+
+// ----------------------------
+// REPLAY
+// ----------------------------
+
+// replaying is set while requests are answered from fixtures, which never
+// leave the machine and so are allowed in offline mode.
+var replaying atomic.Bool
+
+// NetworkDisabled reports whether a request must be refused: offline mode is
+// on and no fixture replay is active.
+func NetworkDisabled() bool {
+	return utils.Offline() && !replaying.Load()
+}
+
+// originalURLHeader carries the real URL to the local fixture server.
+const originalURLHeader = "X-Fixture-Original-URL"
+
+// ReplayServer serves recorded fixtures from a local HTTP server.
+type ReplayServer struct {
+	dir      string
+	listener net.Listener
+	server   *http.Server
+}
+
+// StartReplay serves the fixtures in dir on a loopback port.
+func StartReplay(dir string) (*ReplayServer, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	rs := &ReplayServer{dir: dir, listener: ln}
+	rs.server = &http.Server{Handler: http.HandlerFunc(rs.serve)}
+	go rs.server.Serve(ln)
+	return rs, nil
+}
+
+// Addr returns the host:port the server listens on.
+func (rs *ReplayServer) Addr() string { return rs.listener.Addr().String() }
+
+// Close stops the server.
+func (rs *ReplayServer) Close() error { return rs.server.Close() }
+
+func (rs *ReplayServer) serve(w http.ResponseWriter, r *http.Request) {
+	original := r.Header.Get(originalURLHeader)
+	if original == "" {
+		http.Error(w, "missing "+originalURLHeader, http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(rs.dir, fixtureKey(r.Method, original, body)))
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "no fixture for "+r.Method+" "+original, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var fx fixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for k, vs := range fx.Header {
+		// the body is served decoded and in full
+		if k == "Content-Length" || k == "Content-Encoding" || k == "Transfer-Encoding" {
+			continue
+		}
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(fx.Status)
+	w.Write(fx.Body)
+}
+
+type replayTransport struct {
+	addr string
+	next http.RoundTripper
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Header.Set(originalURLHeader, req.URL.String())
+	out.URL = &url.URL{Scheme: "http", Host: t.addr, Path: "/"}
+	out.Host = t.addr
+	return t.next.RoundTrip(out)
+}
+
+// EnableReplay routes every scraper request to a local server that answers
+// from the fixtures in dir. Call the returned function to stop it.
+func EnableReplay(dir string) (func(), error) {
+	rs, err := StartReplay(dir)
+	if err != nil {
+		return nil, err
+	}
+	previous := httpClient.Transport
+	httpClient.Transport = &replayTransport{addr: rs.Addr(), next: http.DefaultTransport}
+	replaying.Store(true)
+	return func() {
+		httpClient.Transport = previous
+		replaying.Store(false)
+		rs.Close()
+	}, nil
+}
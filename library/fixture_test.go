@@ -0,0 +1,59 @@
+package library
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"novel_reader/utils"
+)
+
+func TestReplayFixture(t *testing.T) {
+	dir := t.TempDir()
+	const page = "https://example.invalid/book/1/"
+	fx := fixture{
+		Method: "GET",
+		URL:    page,
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:   []byte("<html><body><h1>第1章 开始</h1></body></html>"),
+	}
+	data, err := json.Marshal(fx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, fixtureKey(fx.Method, fx.URL, nil)), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := utils.ForceOffline
+	utils.ForceOffline = true
+	defer func() { utils.ForceOffline = previous }()
+
+	stop, err := EnableReplay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NetworkDisabled() {
+		t.Error("NetworkDisabled during replay, want requests allowed")
+	}
+	doc, err := fetchHTML("test", page)
+	if err != nil {
+		stop()
+		t.Fatalf("fetchHTML: %v", err)
+	}
+	if got := strings.TrimSpace(doc.Find("h1").Text()); got != "第1章 开始" {
+		t.Errorf("replayed title = %q", got)
+	}
+	if _, err := fetchHTML("test", page+"missing"); err == nil {
+		t.Error("fetchHTML without a fixture succeeded")
+	}
+	stop()
+
+	if !NetworkDisabled() {
+		t.Error("NetworkDisabled after replay stopped, want offline again")
+	}
+}
@@ -0,0 +1,222 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"novel_reader/utils"
+)
+
+// A folder of chapter files (001.txt, 002.txt … or 第1章.txt …) is one
+// book. A folder is taken for one when most of its book files have chapter
+// names, or when it holds a folderMarker, which is also the book's sidecar.
+// Chapter files are read in natural order and each becomes a TOC entry. A
+// configured library folder is never one book, whatever it holds.
+
+// folderMarker marks a folder as one book whatever its file names are, and
+// holds its LocalMeta. An empty file is enough. Its name is unlike any
+// file's sidecar, so the sidecar of book.txt does not mark its folder.
+const folderMarker = ".novel_reader-book"
+
+// minFolderChapters is how many chapter files a folder needs before it is
+// taken for a book without a marker; they must also be two thirds of its
+// book files.
+const minFolderChapters = 3
+
+var errEmptyFolder = errors.New("no chapter files in the folder")
+
+// chapterFileName matches names that number a chapter: 001, 12-title,
+// 第12章, 第十二回, chapter 3, ch03.
+var chapterFileName = regexp.MustCompile(`(?i)^(?:\d+(?:$|[\s._\-、]))|^第\s*[0-9０-９零〇一二三四五六七八九十百千万两]+\s*[章节節回话話卷集篇]|^(?:chapter|chap|ch)[\s._\-]*\d+`)
+
+// isDir reports whether path is a real directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// folderChapters returns the chapter files of dir in reading order and
+// whether dir is a folder book at all.
+func folderChapters(dir string) ([]string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, false
+	}
+	marked := false
+	var files []string
+	numbered := 0
+	for _, e := range entries {
+		name := e.Name()
+		if name == folderMarker {
+			marked = true
+			continue
+		}
+		if e.IsDir() || isArchive(name) || !IsLocalBookFile(name) {
+			continue
+		}
+		if format, _ := formatOf(name); !format.archived {
+			continue // whole books like EPUB are never chapters
+		}
+		files = append(files, name)
+		if chapterFileName.MatchString(LocalBookName(name)) {
+			numbered++
+		}
+	}
+	if len(files) == 0 {
+		return nil, false
+	}
+	if !marked && (numbered < minFolderChapters || numbered*3 < len(files)*2) {
+		return nil, false
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return naturalLess(LocalBookName(files[i]), LocalBookName(files[j]))
+	})
+	for i, name := range files {
+		files[i] = filepath.Join(dir, name)
+	}
+	return files, true
+}
+
+// isLibraryRoot reports whether dir is one of the configured library
+// folders, which hold books and are never one themselves.
+func isLibraryRoot(dir string) bool {
+	dir = filepath.Clean(dir)
+	for _, root := range utils.AppConfig.Library.Paths {
+		if filepath.Clean(root) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// isFolderBook reports whether dir is read as one book.
+func isFolderBook(dir string) bool {
+	_, ok := folderChapters(dir)
+	return ok
+}
+
+// chapterFileTitle is the TOC title of one chapter file: its name, unless
+// the file starts with a short line that the name is a bare number for or a
+// prefix of, like "第1章 开始" in 第1章.txt, which is then the heading.
+func chapterFileTitle(name string, lines []string) string {
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l == "" {
+			continue
+		}
+		if utf8.RuneCountInString(l) <= 40 {
+			if _, bare := utils.ParseDigits(name); bare || strings.HasPrefix(l, name) {
+				return l
+			}
+		}
+		break
+	}
+	return name
+}
+
+// loadFolderBook joins the chapter files of dir. A chapter whose file does
+// not start with its title gets the title as a heading line.
+func loadFolderBook(dir string) (LocalBook, error) {
+	files, ok := folderChapters(dir)
+	if !ok {
+		return LocalBook{}, newError(KindParse, "folder", dir, errEmptyFolder)
+	}
+	book := LocalBook{Title: filepath.Base(dir), TOC: []BookChapter{}}
+	for _, file := range files {
+		format, _ := formatOf(file)
+		ch, err := format.load(file)
+		if err != nil {
+			continue // one unreadable chapter should not cost the book
+		}
+		title := chapterFileTitle(LocalBookName(file), ch.Lines)
+		line := len(book.Lines)
+		first := 0
+		for first < len(ch.Lines) && strings.TrimSpace(ch.Lines[first]) == "" {
+			first++
+		}
+		if first < len(ch.Lines) && strings.TrimSpace(ch.Lines[first]) == title {
+			ch.Lines = ch.Lines[first:]
+		} else {
+			book.Lines = append(book.Lines, title)
+		}
+		book.Lines = append(book.Lines, ch.Lines...)
+		book.TOC = append(book.TOC, BookChapter{Title: title, Line: line})
+	}
+	if len(book.TOC) == 0 {
+		return LocalBook{}, newError(KindParse, "folder", dir, errEmptyFolder)
+	}
+	return book, nil
+}
+
+// folderInfo lists the chapters without reading them, for library scans.
+// Only the last file is opened, for the latest chapter's title.
+func folderInfo(dir string) (LocalBook, error) {
+	files, ok := folderChapters(dir)
+	if !ok {
+		return LocalBook{}, newError(KindParse, "folder", dir, errEmptyFolder)
+	}
+	book := LocalBook{Title: filepath.Base(dir)}
+	for i, file := range files {
+		title := LocalBookName(file)
+		if i == len(files)-1 {
+			format, _ := formatOf(file)
+			if ch, err := format.load(file); err == nil {
+				title = chapterFileTitle(title, ch.Lines)
+			}
+		}
+		book.TOC = append(book.TOC, BookChapter{Title: title})
+	}
+	return book, nil
+}
+
+// folderFormat reads folder books; localFormatOf hands it out for
+// directories.
+var folderFormat = localFormat{load: loadFolderBook, info: folderInfo}
+
+// folderBookID fingerprints the head of the chapters read in order, so
+// chapters added at the end keep the ID.
+func folderBookID(dir string) (string, error) {
+	files, ok := folderChapters(dir)
+	if !ok {
+		return "", newError(KindParse, "folder", dir, errEmptyFolder)
+	}
+	var head []byte
+	for _, file := range files {
+		data, err := readLocalFile(file)
+		if err != nil {
+			return "", err
+		}
+		head = append(head, data[:min(len(data), fingerprintSize-len(head))]...)
+		if len(head) >= fingerprintSize {
+			return hashID("l", string(head)), nil
+		}
+	}
+	return pathBookID(dir), nil
+}
+
+// folderStat is the size of all chapter files and the time the newest was
+// changed.
+func folderStat(dir string) (int64, time.Time, error) {
+	files, ok := folderChapters(dir)
+	if !ok {
+		return 0, time.Time{}, newError(KindParse, "folder", dir, errEmptyFolder)
+	}
+	var size int64
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		size += info.Size()
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return size, latest, nil
+}
@@ -0,0 +1,64 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"novel_reader/utils"
+)
+
+func TestLibraryRootIsNeverAFolderBook(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "nested")
+	series := filepath.Join(root, "series")
+	for _, path := range []string{
+		filepath.Join(root, "book.txt"),
+		filepath.Join(root, "001 first.txt"),
+		filepath.Join(root, "002 second.txt"),
+		filepath.Join(root, "003 third.txt"),
+		filepath.Join(nested, "01.txt"),
+		filepath.Join(nested, "02.txt"),
+		filepath.Join(nested, "03.txt"),
+		filepath.Join(series, "01.txt"),
+		filepath.Join(series, "02.txt"),
+		filepath.Join(series, "03.txt"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("text\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := utils.AppConfig.Library.Paths
+	utils.AppConfig.Library.Paths = []string{root, nested}
+	defer func() { utils.AppConfig.Library.Paths = previous }()
+
+	if err := SaveLocalMeta(filepath.Join(root, "book.txt"), LocalMeta{Title: "Book"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if err := walkLocalBooks(root, func(path string) error {
+		got = append(got, path)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(root, "001 first.txt"),
+		filepath.Join(root, "002 second.txt"),
+		filepath.Join(root, "003 third.txt"),
+		filepath.Join(root, "book.txt"),
+		filepath.Join(nested, "01.txt"),
+		filepath.Join(nested, "02.txt"),
+		filepath.Join(nested, "03.txt"),
+		series,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("books under the library folder:\n got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,228 @@
+package library
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"novel_reader/utils"
+)
+
+// Text books carry no metadata of their own, but most open with a header
+// before the first chapter:
+//
+//	《斗破苍穹》
+//	作者：天蚕土豆
+//	内容简介：
+//	　　这里是属于斗气的世界……
+//
+// and their file names often follow "书名 作者：作者名.txt" or
+// "[author] title.txt". Both are read into a LocalMeta; the header wins over
+// the name, and the sidecar over both.
+
+const (
+	// headerLines is how far into a text the header is looked for.
+	headerLines = 80
+	// maxSynopsisLength caps a synopsis that runs into the text.
+	maxSynopsisLength = 1000
+	// maxAuthorLength rules out sentences taken for an author.
+	maxAuthorLength = 30
+)
+
+// headerLabels maps the labels of header fields to the field they fill.
+var headerLabels = map[string]string{
+	"书名":       "title",
+	"书 名":      "title",
+	"作者":       "author",
+	"作 者":      "author",
+	"内容简介":     "synopsis",
+	"作品简介":     "synopsis",
+	"内容介绍":     "synopsis",
+	"书籍简介":     "synopsis",
+	"简介":       "synopsis",
+	"文案":       "synopsis",
+	"分类":       "category",
+	"类型":       "category",
+	"类别":       "category",
+	"状态":       "status",
+	"title":    "title",
+	"author":   "author",
+	"synopsis": "synopsis",
+	"summary":  "synopsis",
+	"category": "category",
+	"status":   "status",
+}
+
+// headerTitleLine matches a line that starts with the title in book
+// quotes, like 《斗破苍穹》 or 《斗破苍穹》作者：天蚕土豆.
+var headerTitleLine = regexp.MustCompile(`^《([^》]+)》\s*(.*)$`)
+
+// headerField splits a trimmed line into a header label and its value:
+// 作者：xxx, 【作者】xxx, [作者] xxx, Author: xxx. A label alone on a line,
+// like 内容简介, has an empty value.
+func headerField(line string) (field, value string, ok bool) {
+	rest := line
+	bracketed := false
+	for _, pair := range [][2]string{{"【", "】"}, {"[", "]"}, {"〔", "〕"}} {
+		if strings.HasPrefix(rest, pair[0]) {
+			if end := strings.Index(rest, pair[1]); end > 0 {
+				label := strings.TrimSpace(rest[len(pair[0]):end])
+				if field, ok = headerLabels[strings.ToLower(label)]; ok {
+					rest, bracketed = rest[end+len(pair[1]):], true
+				}
+			}
+			break
+		}
+	}
+	if !bracketed {
+		end := strings.IndexAny(rest, "：:")
+		if end < 0 {
+			end = len(rest)
+		}
+		if field, ok = headerLabels[strings.ToLower(strings.TrimSpace(rest[:end]))]; !ok {
+			return "", "", false
+		}
+		rest = rest[end:]
+	}
+	rest = strings.TrimLeft(rest, "：: 　")
+	return field, strings.TrimSpace(rest), true
+}
+
+// textHeader reads the header of a text book. Only lines before the first
+// chapter heading are looked at.
+func textHeader(lines []string) LocalMeta {
+	var meta LocalMeta
+	head := lines[:min(len(lines), headerLines)]
+	rules := utils.ChapterRulesFor("", head)
+	var synopsis []string
+	inSynopsis := false
+	blanks := 0
+	for _, raw := range head {
+		line := strings.Trim(raw, " \t　\ufeff")
+		if rules.Match(line) {
+			break
+		}
+		if line == "" {
+			blanks++
+			if inSynopsis && len(synopsis) > 0 && blanks >= 2 {
+				inSynopsis = false
+			}
+			continue
+		}
+		blanks = 0
+		if utils.IsSeparatorLine(line) {
+			inSynopsis = false
+			continue
+		}
+		if m := headerTitleLine.FindStringSubmatch(line); m != nil && meta.Title == "" {
+			meta.Title = strings.TrimSpace(m[1])
+			line = strings.TrimSpace(m[2])
+			if line == "" {
+				continue
+			}
+		}
+		field, value, ok := headerField(line)
+		if !ok {
+			if inSynopsis {
+				synopsis = append(synopsis, line)
+				if utf8.RuneCountInString(strings.Join(synopsis, "\n")) >= maxSynopsisLength {
+					inSynopsis = false
+				}
+			}
+			continue
+		}
+		inSynopsis = false
+		switch field {
+		case "title":
+			if meta.Title == "" {
+				meta.Title = strings.Trim(value, "《》")
+			}
+		case "author":
+			if meta.Author == "" && utf8.RuneCountInString(value) <= maxAuthorLength {
+				meta.Author = value
+			}
+		case "synopsis":
+			if len(synopsis) == 0 {
+				inSynopsis = true
+				if value != "" {
+					synopsis = append(synopsis, value)
+				}
+			}
+		case "category":
+			if meta.Category == "" {
+				meta.Category = value
+			}
+		case "status":
+			meta.Status = ParseBookStatus(value)
+		}
+	}
+	meta.Synopsis = truncateRunes(strings.Join(synopsis, "\n"), maxSynopsisLength)
+	return meta
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+var (
+	// [author] title, 【author】title
+	bracketAuthorName = regexp.MustCompile(`^[\[【]([^\]】]+)[\]】]\s*(.+)$`)
+	// 《title》author, 《title》作者：author
+	quotedTitleName = regexp.MustCompile(`^《([^》]+)》\s*(?:作者\s*[：:]?)?\s*(.*)$`)
+	// title 作者：author
+	labelledAuthorName = regexp.MustCompile(`^(.+?)\s*作者\s*[：:]\s*(.+)$`)
+	// 书名 作者著
+	signedAuthorName = regexp.MustCompile(`^(\S+)\s+(\S+?)\s*著$`)
+)
+
+// nameWords are what file names end with that are not an author.
+var nameWords = []string{"全本", "全集", "完本", "完结", "精校", "校对", "精校版", "全文", "番外", "txt", "TXT", "合集", "最新"}
+
+// fileNameMeta reads the title and author from the name of a local book,
+// without its extension. Only names that mark the author, with brackets,
+// 作者 or 著, are split, unless known is the author, as the header says, and
+// the name ends in it. Names that follow no convention give only the
+// title, which is the name itself.
+func fileNameMeta(name, known string) LocalMeta {
+	name = strings.TrimSpace(name)
+	author := func(s string) string {
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "著"))
+		if s == "" || utf8.RuneCountInString(s) > maxAuthorLength {
+			return ""
+		}
+		for _, w := range nameWords {
+			if strings.Contains(s, w) {
+				return ""
+			}
+		}
+		return s
+	}
+	if m := bracketAuthorName.FindStringSubmatch(name); m != nil {
+		if a := author(m[1]); a != "" {
+			return LocalMeta{Title: strings.TrimSpace(m[2]), Author: a}
+		}
+	}
+	if m := quotedTitleName.FindStringSubmatch(name); m != nil {
+		return LocalMeta{Title: strings.TrimSpace(m[1]), Author: author(m[2])}
+	}
+	if m := labelledAuthorName.FindStringSubmatch(name); m != nil {
+		if a := author(m[2]); a != "" {
+			return LocalMeta{Title: strings.TrimSpace(m[1]), Author: a}
+		}
+	}
+	if m := signedAuthorName.FindStringSubmatch(name); m != nil && hasCJK(m[1]) {
+		if a := author(m[2]); a != "" {
+			return LocalMeta{Title: m[1], Author: a}
+		}
+	}
+	if known = strings.TrimSpace(known); known != "" && strings.HasSuffix(name, known) {
+		title := strings.TrimRight(strings.TrimSuffix(name, known), " 　_-—·")
+		if title != "" && title != name {
+			return LocalMeta{Title: title, Author: known}
+		}
+	}
+	return LocalMeta{Title: name}
+}
@@ -0,0 +1,101 @@
+package library
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Book IDs are short, filesystem-safe strings that stay the same when a
+// novel is renamed or moved:
+//
+//	o<16 hex>  online book: hash of the source host and the book's path
+//	l<16 hex>  local file:  hash of the first fingerprintSize bytes, or of
+//	                        the path while the file is shorter than that
+//
+// The local fingerprint only covers the head of the file, so a book that is
+// still being appended to by a download tool keeps its ID once it has
+// fingerprintSize bytes. Before that its head is still changing and the
+// path is all that stays put; the ID changes once when the file gets there,
+// and its progress moves along, see ScanLocalNovels.
+const fingerprintSize = 64 * 1024
+
+func hashID(prefix string, parts ...string) string {
+	h := sha1.New()
+	for _, p := range parts {
+		io.WriteString(h, p)
+		h.Write([]byte{0})
+	}
+	return prefix + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// OnlineBookID derives the ID of an online book from its URL on the source.
+func OnlineBookID(novelURL string) string {
+	novelURL = strings.TrimSpace(novelURL)
+	if strings.HasPrefix(novelURL, "/") {
+		novelURL = BaseURL + novelURL
+	}
+	u, err := url.Parse(novelURL)
+	if err != nil || u.Host == "" {
+		return hashID("o", novelURL)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	// the book id is the first path segment, e.g. /biqu3628/ or /biqu3628/1
+	book := strings.Trim(u.Path, "/")
+	if i := strings.Index(book, "/"); i >= 0 {
+		book = book[:i]
+	}
+	return hashID("o", host, book)
+}
+
+// LocalBookID fingerprints a local file by content. Books inside archives
+// are fingerprinted by the member's content, folder books by their first
+// chapters'.
+func LocalBookID(path string) (string, error) {
+	if isDir(path) {
+		return folderBookID(path)
+	}
+	f, err := openLocalFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, fingerprintSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if n < fingerprintSize {
+		return pathBookID(path), nil
+	}
+	return hashID("l", string(buf[:n])), nil
+}
+
+// pathBookID is the ID of a local book too short to fingerprint.
+func pathBookID(path string) string {
+	return hashID("l", "path", filepath.Clean(path))
+}
+
+// IsBookID reports whether s looks like an ID produced by this package.
+func IsBookID(s string) bool {
+	if len(s) != 17 || (s[0] != 'o' && s[0] != 'l') {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Source returns "local" or "online" for progress entries.
+func (n Novel) Source() string {
+	if n.IsLocal {
+		return "local"
+	}
+	return "online"
+}
@@ -0,0 +1,180 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"novel_reader/utils"
+)
+
+// The library index remembers what scanning each local book found, so a
+// scan only reads the books that are new or changed since the last one.
+// Entries are keyed by path and kept while the book's size and modification
+// time, and the chapter rules it was scanned with, stay the same. It lives
+// in the cache directory as library.json; losing it only costs a full scan.
+
+// libraryIndexVersion changes when entries need to be scanned again.
+//
+//	1  size, mtime, rules, id, encoding, latest, chapters, info, sidecar
+//	2  info of text books read from their header
+const libraryIndexVersion = 2
+
+type libraryIndex struct {
+	Version int                   `json:"version"`
+	Books   map[string]indexEntry `json:"books"` // by path
+}
+
+type indexEntry struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Rules    string    `json:"rules"` // utils.ChapterRulesKey at scan time
+	ID       string    `json:"id"`
+	Encoding string    `json:"encoding,omitempty"` // plain text only
+	Forced   string    `json:"forced,omitempty"`   // utils.FileEncoding at scan time
+	Latest   string    `json:"latest,omitempty"`
+	Chapters int       `json:"chapters"`
+	Info     LocalMeta `json:"info"`    // title, author … from the file
+	Sidecar  LocalMeta `json:"sidecar"` // as last read, for the list before a scan
+}
+
+// fresh reports whether the entry still describes the book.
+func (e indexEntry) fresh(path string, size int64, modTime time.Time) bool {
+	return e.ID != "" && e.Size == size && e.ModTime.Equal(modTime) &&
+		e.Rules == utils.ChapterRulesKey(e.ID) && e.Forced == utils.FileEncoding(path)
+}
+
+// indexMu guards loadedIndex and the file; a scan and LatestChapter may
+// run at the same time.
+var indexMu sync.Mutex
+
+// loadedIndex is the index as last read or written, so looking up every
+// book in a list reads the file once. Callers must not change its map.
+var loadedIndex *libraryIndex
+
+func libraryIndexPath() string {
+	return filepath.Join(CacheDir(), "library.json")
+}
+
+// loadLibraryIndex reads the index; a missing or unreadable one is empty.
+// indexMu must be held.
+func loadLibraryIndex() libraryIndex {
+	if loadedIndex != nil {
+		return *loadedIndex
+	}
+	var idx libraryIndex
+	if err := readJSON(libraryIndexPath(), &idx); err != nil || idx.Version != libraryIndexVersion {
+		idx = libraryIndex{Version: libraryIndexVersion}
+	}
+	if idx.Books == nil {
+		idx.Books = make(map[string]indexEntry)
+	}
+	loadedIndex = &idx
+	return idx
+}
+
+// saveLibraryIndex replaces the index; indexMu must be held.
+func saveLibraryIndex(idx libraryIndex) error {
+	loadedIndex = &idx
+	if utils.ReadOnly() {
+		return nil // the index is only a speedup
+	}
+	if err := os.MkdirAll(CacheDir(), 0755); err != nil {
+		return err
+	}
+	return writeJSON(libraryIndexPath(), idx)
+}
+
+// scanLocalBook reads what the library shows of the book at path.
+func scanLocalBook(path string, size int64, modTime time.Time) (indexEntry, error) {
+	id, err := LocalBookID(path)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	entry := indexEntry{Size: size, ModTime: modTime, Rules: utils.ChapterRulesKey(id), ID: id, Forced: utils.FileEncoding(path)}
+	var info *LocalBook
+	if book, ok := localBookInfo(path); ok {
+		info = &book
+		entry.Info = LocalMeta{Title: book.Title, Author: book.Author, BookMeta: book.BookMeta}
+	}
+	entry.Latest, entry.Chapters, _ = chapterSummary(path, id, info)
+	if bookExt(path) == ".txt" {
+		if data, err := readLocalFile(path); err == nil {
+			entry.Encoding = entry.Forced
+			if entry.Encoding == "" {
+				entry.Encoding = utils.TextEncoding(data)
+			}
+		}
+	}
+	return entry, nil
+}
+
+// novel is the library entry of the book at path.
+func (e indexEntry) novel(path string) Novel {
+	n := Novel{
+		ID:       e.ID,
+		Name:     LocalBookName(path),
+		Path:     path,
+		Latest:   e.Latest,
+		Modified: e.ModTime,
+		Added:    e.ModTime,
+		IsLocal:  true,
+	}
+	// a guess from the name shows only when it found an author; otherwise
+	// the name is left as it is
+	if meta := fileNameMeta(n.Name, e.Info.Author); meta.Author != "" {
+		applyLocalMeta(&n, meta)
+	}
+	applyLocalMeta(&n, e.Info)
+	applyLocalMeta(&n, e.Sidecar)
+	return n
+}
+
+// IndexedLocalNovels returns the local novels as the last scan found them,
+// without looking at the files, for showing the library before a scan.
+// Books outside the configured library paths are left out.
+func IndexedLocalNovels() []Novel {
+	indexMu.Lock()
+	idx := loadLibraryIndex()
+	indexMu.Unlock()
+	var novels []Novel
+	for path, e := range idx.Books {
+		if inLibrary(path) {
+			novels = append(novels, e.novel(path))
+		}
+	}
+	progressMap, _ := utils.Load()
+	applyProgress(novels, progressMap)
+	sortByModified(novels)
+	return novels
+}
+
+// inLibrary reports whether path is under one of the library paths.
+func inLibrary(path string) bool {
+	path = filepath.Clean(path)
+	for _, dir := range utils.AppConfig.Library.Paths {
+		dir = filepath.Clean(dir)
+		if path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexedLatestChapter returns the latest chapter the index has for the
+// book at path, if its entry is still fresh.
+func indexedLatestChapter(path string) (string, bool) {
+	size, modTime, err := localStat(path)
+	if err != nil {
+		return "", false
+	}
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	e, ok := loadLibraryIndex().Books[path]
+	if !ok || !e.fresh(path, size, modTime) {
+		return "", false
+	}
+	return e.Latest, true
+}
@@ -1,52 +1,73 @@
 package library
 
 import (
-	"bufio"
-	"io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"time"
-	"unicode/utf8"
-
-	"golang.org/x/text/encoding/simplifiedchinese"
 
 	"novel_reader/utils"
 )
 
-var chapterPattern = regexp.MustCompile(`^第[0-9一二三四五六七八九十百千~-]+章(?:\s*：?\s*.*)?$`)
-
 // LoadLocalNovels scans local library folders and returns Novel structs
 func LoadLocalNovels() ([]Novel, error) {
+	return ScanLocalNovels(nil)
+}
+
+// ScanLocalNovels is LoadLocalNovels calling found with each book as soon
+// as it is known, so lists can fill in while a slow library is scanned.
+// Only books that are new or changed since the last scan are read, see
+// libraryIndex.
+func ScanLocalNovels(found func(Novel)) ([]Novel, error) {
 	var novels []Novel
 
+	progressMap := loadProgress()
+
+	indexMu.Lock()
+	old := loadLibraryIndex()
+	indexMu.Unlock()
+	idx := libraryIndex{Version: libraryIndexVersion, Books: make(map[string]indexEntry)}
+	changed := false
+	moved := make(map[string]string) // new ID by old, for books whose ID changed
+	var skipped []SkippedBook
+
 	// Scan all configured library paths
 	for _, dir := range utils.AppConfig.Library.Paths {
-		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
+		// Only formats we can read, see localFormats; archives are looked into
+		err := walkLocalBooks(dir, func(path string) error {
+			// one unreadable book should not cost the library
+			size, modified, statErr := localStat(path)
+			if statErr != nil {
+				skipped = append(skipped, SkippedBook{Path: path, Err: statErr})
+				return nil
 			}
 
-			// Only .txt files
-			if !d.IsDir() && strings.HasSuffix(d.Name(), ".txt") {
-				info, statErr := os.Stat(path)
-				if statErr != nil {
-					return statErr
+			entry, ok := old.Books[path]
+			if !ok || !entry.fresh(path, size, modified) {
+				var scanErr error
+				if entry, scanErr = scanLocalBook(path, size, modified); scanErr != nil {
+					skipped = append(skipped, SkippedBook{Path: path, Err: scanErr})
+					return nil
+				}
+				if prev := old.Books[path].ID; ok && prev != "" && prev != entry.ID {
+					moved[prev] = entry.ID
 				}
+				changed = true
+			}
+			// a broken sidecar only costs the extra fields, not the book
+			if meta, err := LoadLocalMeta(path); err == nil {
+				changed = changed || !reflect.DeepEqual(meta, entry.Sidecar)
+				entry.Sidecar = meta
+			}
+			idx.Books[path] = entry
 
-				latest, _ := detectLatestChapter(path)
-
-				novels = append(novels, Novel{
-					Name:     strings.TrimSuffix(d.Name(), ".txt"),
-					Path:     path,
-					Latest:   latest,
-					Current:  "",
-					Modified: info.ModTime(),
-					Added:    info.ModTime(),
-					IsLocal:  true,
-				})
+			novels = append(novels, entry.novel(path))
+			applyProgress(novels[len(novels)-1:], progressMap)
+			if found != nil {
+				found(novels[len(novels)-1])
 			}
 			return nil
 		})
@@ -55,10 +76,84 @@ func LoadLocalNovels() ([]Novel, error) {
 		}
 	}
 
-	// Load saved progress and merge into Novel structs
-	progressMap, _ := utils.Load()
+	for from, to := range moved {
+		// progress saved since Load above must not be lost, so each move
+		// loads it again
+		if err := utils.MoveProgress(from, to); err == nil {
+			if p, ok := progressMap[from]; ok {
+				progressMap[to] = p
+			}
+		}
+	}
+	if len(moved) > 0 {
+		applyProgress(novels, progressMap)
+	}
+
+	if changed || len(idx.Books) != len(old.Books) {
+		indexMu.Lock()
+		_ = saveLibraryIndex(idx) // a stale index only costs time
+		indexMu.Unlock()
+	}
+
+	skippedMu.Lock()
+	skippedBooks = skipped
+	skippedMu.Unlock()
+
+	sortByModified(novels)
+	return novels, nil
+}
+
+// SkippedBook is a book the last scan could not read and left out.
+type SkippedBook struct {
+	Path string
+	Err  error
+}
+
+var (
+	skippedMu    sync.Mutex
+	skippedBooks []SkippedBook
+)
+
+// SkippedBooks returns the books the last completed scan left out.
+func SkippedBooks() []SkippedBook {
+	skippedMu.Lock()
+	defer skippedMu.Unlock()
+	return append([]SkippedBook(nil), skippedBooks...)
+}
+
+// progressErr is the error the last loadProgress met, if any.
+var (
+	progressErrMu sync.Mutex
+	progressErr   error
+)
+
+// loadProgress is utils.Load for listing books: a progress file that
+// cannot be read costs the books their progress, not the list. The error
+// is kept for ProgressError.
+func loadProgress() map[string]utils.Progress {
+	progressMap, err := utils.Load()
+	if err != nil {
+		err = newError(KindCacheCorrupt, "progress", "", err)
+		progressMap = make(map[string]utils.Progress)
+	}
+	progressErrMu.Lock()
+	progressErr = err
+	progressErrMu.Unlock()
+	return progressMap
+}
+
+// ProgressError returns why books were last listed without their progress,
+// or nil.
+func ProgressError() error {
+	progressErrMu.Lock()
+	defer progressErrMu.Unlock()
+	return progressErr
+}
+
+// applyProgress sets when each novel was last read and where.
+func applyProgress(novels []Novel, progressMap map[string]utils.Progress) {
 	for i := range novels {
-		if p, ok := utils.GetProgress(progressMap, novels[i].Name, "local"); ok {
+		if p, ok := utils.GetProgress(progressMap, novels[i].ID); ok {
 			if !p.LastRead.IsZero() {
 				novels[i].Modified = p.LastRead
 			}
@@ -67,13 +162,25 @@ func LoadLocalNovels() ([]Novel, error) {
 			}
 		}
 	}
+}
 
-	// Sort by most recently read
+// sortByModified puts the most recently read first.
+func sortByModified(novels []Novel) {
 	sort.Slice(novels, func(i, j int) bool {
 		return novels[i].Modified.After(novels[j].Modified)
 	})
+}
 
-	return novels, nil
+// applyLocalMeta copies what the sidecar knows over the fields taken from
+// the file name.
+func applyLocalMeta(n *Novel, meta LocalMeta) {
+	if title := strings.TrimSpace(meta.Title); title != "" {
+		n.Name = title
+	}
+	if author := strings.TrimSpace(meta.Author); author != "" {
+		n.Author = author
+	}
+	n.BookMeta = mergeMeta(n.BookMeta, meta.BookMeta)
 }
 
 // GroupLocalNovelsByRoot organizes local novels under each configured library path.
@@ -103,86 +210,173 @@ func GroupLocalNovelsByRoot(novels []Novel) map[string][]Novel {
 	}
 
 	for dir := range grouped {
-		sort.SliceStable(grouped[dir], func(i, j int) bool {
-			return grouped[dir][i].Added.After(grouped[dir][j].Added)
-		})
+		SortLocalNovels(grouped[dir], SortByAdded)
 	}
 
 	return grouped
 }
 
-// ScanLatestChapter scans a local .txt file for the last chapter title
+// LocalSort is an order of the books in a library folder.
+type LocalSort int
+
+const (
+	SortByAdded  LocalSort = iota // newest first
+	SortByTitle                   // title as the header or sidecar gives it
+	SortByAuthor                  // then by title; books without one last
+)
+
+// SortLocalNovels orders novels in place.
+func SortLocalNovels(novels []Novel, by LocalSort) {
+	sort.SliceStable(novels, func(i, j int) bool {
+		a, b := novels[i], novels[j]
+		switch by {
+		case SortByTitle:
+			if a.Name != b.Name {
+				return naturalLess(a.Name, b.Name)
+			}
+		case SortByAuthor:
+			if a.Author != b.Author {
+				if a.Author == "" || b.Author == "" {
+					return b.Author == ""
+				}
+				return naturalLess(a.Author, b.Author)
+			}
+			if a.Name != b.Name {
+				return naturalLess(a.Name, b.Name)
+			}
+		}
+		if !a.Added.Equal(b.Added) {
+			return a.Added.After(b.Added)
+		}
+		// books unpacked together share a time; keep an author's together
+		if a.Author != b.Author {
+			return a.Author < b.Author
+		}
+		return naturalLess(a.Name, b.Name)
+	})
+}
+
+// ScanLatestChapter scans a local file for the last chapter title
 // and updates both the Novel and the saved progress.
-func ScanLatestChapter(n *Novel) {
+func ScanLatestChapter(n *Novel) error {
 	if !n.IsLocal || n.Path == "" {
-		return
+		return nil
 	}
 
-	lastChapter, err := detectLatestChapter(n.Path)
-	if err != nil || lastChapter == "" {
-		return
+	lastChapter, err := detectLatestChapter(n.Path, n.ID)
+	if err != nil {
+		return err
+	}
+	if lastChapter == "" {
+		return nil
 	}
 
 	n.Latest = lastChapter
 	n.Current = lastChapter
 
-	progressMap, _ := utils.Load()
-	p, _ := utils.GetProgress(progressMap, n.Name, "local")
+	progressMap, err := utils.Load()
+	if err != nil {
+		return newError(KindCacheCorrupt, "progress", "", err)
+	}
+	p, _ := utils.GetProgress(progressMap, n.ID)
 
 	// Ensure Source is always "local"
 	p.Source = "local"
+	p.Title = n.Name
 	p.LastChapter = lastChapter
 	if p.LastRead.IsZero() {
 		p.LastRead = time.Now()
 	}
 
-	utils.SetProgress(progressMap, n.Name, "local", p)
-	utils.Save(progressMap)
+	utils.SetProgress(progressMap, n.ID, p)
+	return utils.Save(progressMap)
+}
+
+// detectLatestChapter returns the title of the last chapter of the book at
+// path with the given ID, found the way the reader will find it.
+func detectLatestChapter(path, id string) (string, error) {
+	latest, _, err := chapterSummary(path, id, nil)
+	return latest, err
 }
 
-func detectLatestChapter(path string) (string, error) {
+// chapterSummary returns the title of the last chapter of the book at path
+// and how many chapters it has. info is the book's info when the caller
+// has it already.
+func chapterSummary(path, id string, info *LocalBook) (string, int, error) {
+	_, ownRules := utils.BookChapterRules(id)
+	if f, ok := localFormatOf(path); ok && f.info != nil {
+		var book LocalBook
+		if info != nil {
+			book = *info
+		} else {
+			var err error
+			if book, err = f.info(path); err != nil {
+				return "", 0, err
+			}
+		}
+		if len(book.TOC) > 0 && !ownRules {
+			return book.TOC[len(book.TOC)-1].Title, len(book.TOC), nil
+		}
+		if ownRules && len(book.Lines) == 0 {
+			// the info of folder books has no text to apply them to
+			var err error
+			if book, err = f.load(path); err != nil {
+				return "", 0, err
+			}
+		}
+		// no headings of its own: the reader will detect chapters in the text
+		latest, count := headings(book.Lines, id)
+		return latest, count, nil
+	}
+
 	content, err := readNovelContent(path)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
+	latest, count := headings(strings.Split(content, "\n"), id)
+	return latest, count, nil
+}
 
-	var lastChapter string
-	scanner := bufio.NewScanner(strings.NewReader(content))
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if chapterPattern.MatchString(line) {
-			lastChapter = line
+// headings returns the last line the book's chapter rules match and how
+// many do, or the last section and the number of sections when none do.
+func headings(lines []string, id string) (string, int) {
+	rules := utils.ChapterRulesFor(id, lines)
+	latest, count := "", 0
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); rules.Match(line) {
+			latest = line
+			count++
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return "", err
+	if count > 0 {
+		return latest, count
+	}
+	if sections := utils.SplitSections(lines); len(sections) > 0 {
+		return sections[len(sections)-1].Title, len(sections)
 	}
-	return lastChapter, nil
+	return "", 0
 }
 
-// LatestChapter reads the local novel file and returns the last detected chapter title.
-func LatestChapter(path string) (string, error) {
-	return detectLatestChapter(path)
+// LatestChapter returns the last detected chapter title of a local novel,
+// from the library index when the file has not changed since it was scanned.
+func LatestChapter(path, id string) (string, error) {
+	if latest, ok := indexedLatestChapter(path); ok {
+		return latest, nil
+	}
+	return detectLatestChapter(path, id)
 }
 
 func readNovelContent(path string) (string, error) {
-	data, err := os.ReadFile(path)
+	data, err := readLocalFile(path)
 	if err != nil {
 		return "", err
 	}
-	if utf8.Valid(data) {
-		return string(data), nil
-	}
-	if decoded, decErr := simplifiedchinese.GB18030.NewDecoder().Bytes(data); decErr == nil {
-		return string(decoded), nil
-	}
-	if decoded, decErr := simplifiedchinese.GBK.NewDecoder().Bytes(data); decErr == nil {
-		return string(decoded), nil
-	}
-	if decoded, decErr := simplifiedchinese.HZGB2312.NewDecoder().Bytes(data); decErr == nil {
-		return string(decoded), nil
-	}
-	return string(data), nil
+	return utils.DecodeTextAs(data, utils.FileEncoding(path)), nil
+}
+
+// LocalBookExists reports whether the local book at path can still be found,
+// including books inside archives and folder books.
+func LocalBookExists(path string) bool {
+	_, _, err := localStat(path)
+	return err == nil
 }
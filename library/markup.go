@@ -0,0 +1,400 @@
+package library
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+
+	"novel_reader/utils"
+)
+
+// HTML and Markdown support. Both take their chapters from the document's
+// own headings: h1 and h2, or # and ##. A single top-level heading before
+// the chapters is the book's title rather than a chapter. Documents without
+// such headings get a nil TOC, so the reader detects chapters as for .txt.
+
+// headingTOC collects the chapter headings of a document.
+type headingTOC struct {
+	toc    []BookChapter
+	levels []int
+}
+
+// add records a level 1 or 2 heading starting on line; deeper ones are
+// only shown as headings.
+func (h *headingTOC) add(title string, line, level int) {
+	if title == "" || level < 1 || level > 2 {
+		return
+	}
+	h.toc = append(h.toc, BookChapter{Title: title, Line: line})
+	h.levels = append(h.levels, level)
+}
+
+// result returns the TOC and, when the first heading is the only level 1
+// one and level 2 chapters follow it, that heading as the book title.
+func (h *headingTOC) result() ([]BookChapter, string) {
+	ones := 0
+	for _, l := range h.levels {
+		if l == 1 {
+			ones++
+		}
+	}
+	toc, levels, title := h.toc, h.levels, ""
+	if ones == 1 && len(levels) > 1 && levels[0] == 1 {
+		title, toc, levels = toc[0].Title, toc[1:], levels[1:]
+	}
+	top := 2
+	for _, l := range levels {
+		top = min(top, l)
+	}
+	for i := range toc {
+		toc[i].Depth = levels[i] - top
+	}
+	if len(toc) == 0 {
+		return nil, title
+	}
+	return toc, title
+}
+
+// readDocument returns the file at path as UTF-8. HTML may declare its
+// charset; anything else is guessed the way .txt files are. An encoding set
+// for the file wins over both.
+func readDocument(path string, isHTML bool) (string, error) {
+	data, err := readLocalFile(path)
+	if err != nil {
+		return "", err
+	}
+	if forced := utils.FileEncoding(path); forced != "" {
+		return utils.DecodeTextAs(data, forced), nil
+	}
+	if isHTML {
+		if enc, _, certain := charset.DetermineEncoding(data, "text/html"); certain {
+			if decoded, err := enc.NewDecoder().Bytes(data); err == nil {
+				return string(decoded), nil
+			}
+		}
+	}
+	return utils.DecodeText(data), nil
+}
+
+// ----------------------------
+// HTML
+// ----------------------------
+
+// htmlWalker converts a parsed HTML body to reader lines.
+type htmlWalker struct {
+	text     bookText
+	headings headingTOC
+	pre      int
+}
+
+func (w *htmlWalker) walk(n *html.Node) {
+	t := &w.text
+	switch n.Type {
+	case html.TextNode:
+		if w.pre == 0 {
+			t.write(n.Data)
+			return
+		}
+		for i, line := range strings.Split(n.Data, "\n") {
+			if i > 0 {
+				t.flush()
+			}
+			t.write(line)
+		}
+		return
+	case html.ElementNode:
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			w.walk(c)
+		}
+		return
+	}
+
+	name := strings.ToLower(n.Data)
+	if htmlSkipped[name] || name == "nav" || name == "noscript" || name == "template" {
+		return
+	}
+	block := htmlBlocks[name]
+	if block {
+		t.flush()
+	}
+	line := t.next()
+	switch {
+	case isHeading(name):
+		t.heading++
+	case name == "blockquote":
+		t.quote++
+	case name == "pre":
+		w.pre++
+		t.verse++
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+	if block {
+		t.flush()
+	}
+	switch {
+	case isHeading(name):
+		t.heading--
+		w.headings.add(joinLines(t.lines[line:]), line, int(name[1]-'0'))
+	case name == "blockquote":
+		t.quote--
+		t.blank()
+	case name == "pre":
+		w.pre--
+		t.verse--
+		t.blank()
+	}
+}
+
+// joinLines turns the lines of a heading back into one title.
+func joinLines(lines []string) string {
+	var parts []string
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			parts = append(parts, l)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// htmlMeta reads the title and the author, description and keywords meta
+// tags, including the og:novel ones of saved novel pages.
+func htmlMeta(doc *goquery.Document) LocalBook {
+	meta := func(attr, name string) string {
+		v, _ := doc.Find(`meta[` + attr + `="` + name + `"]`).Attr("content")
+		return collapseSpace(v)
+	}
+	first := func(vs ...string) string {
+		for _, v := range vs {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	book := LocalBook{
+		Title:  first(meta("property", "og:novel:book_name"), collapseSpace(doc.Find("title").First().Text())),
+		Author: first(meta("property", "og:novel:author"), meta("name", "author")),
+	}
+	book.Synopsis = cleanSynopsis(first(meta("property", "og:description"), meta("name", "description")))
+	book.Category = meta("property", "og:novel:category")
+	book.Status = ParseBookStatus(meta("property", "og:novel:status"))
+	for _, k := range strings.FieldsFunc(meta("name", "keywords"), func(r rune) bool { return r == ',' || r == '，' }) {
+		if k = collapseSpace(k); k != "" {
+			book.Tags = append(book.Tags, k)
+		}
+	}
+	return book
+}
+
+// loadHTML is the format's info function too: the TOC needs the whole page.
+func loadHTML(path string) (LocalBook, error) {
+	content, err := readDocument(path, true)
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "html", path, err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "html", path, err)
+	}
+	book := htmlMeta(doc)
+
+	var w htmlWalker
+	for _, n := range doc.Find("body").Nodes {
+		w.walk(n)
+	}
+	w.text.flush()
+	if len(w.text.lines) == 0 {
+		return LocalBook{}, newError(KindParse, "html", path, errNoText)
+	}
+	book.Lines = w.text.lines
+	var title string
+	book.TOC, title = w.headings.result()
+	if book.Title == "" {
+		book.Title = title
+	}
+	return book, nil
+}
+
+// ----------------------------
+// MARKDOWN
+// ----------------------------
+
+var (
+	mdATXHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdRule       = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdListItem   = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d{1,9}[.)])[ \t]+`)
+	mdFence      = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdStrong     = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdEmphasis   = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	mdCode       = regexp.MustCompile("`([^`]+)`")
+	mdEscape     = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!>])")
+)
+
+// mdInline drops the inline markup of one line: links and images keep
+// their text, emphasis and code spans their content.
+func mdInline(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdStrong.ReplaceAllString(s, "$1$2")
+	s = mdEmphasis.ReplaceAllString(s, "$1")
+	s = mdCode.ReplaceAllString(s, "$1")
+	return mdEscape.ReplaceAllString(s, "$1")
+}
+
+// mdParser converts Markdown line by line. Only what books exported as
+// Markdown use is understood: headings, paragraphs, quotes, lists, rules
+// and fenced code.
+type mdParser struct {
+	text     bookText
+	headings headingTOC
+	para     []string
+	quote    bool // para is a blockquote
+	fence    string
+}
+
+func (p *mdParser) flush() {
+	if len(p.para) == 0 {
+		return
+	}
+	if p.quote {
+		p.text.quote++
+	}
+	p.text.write(strings.Join(p.para, "\n"))
+	p.text.flush()
+	if p.quote {
+		p.text.quote--
+	}
+	p.para, p.quote = p.para[:0], false
+}
+
+func (p *mdParser) heading(title string, level int) {
+	p.flush()
+	t := &p.text
+	title = collapseSpace(mdInline(title))
+	if title == "" {
+		return
+	}
+	line := t.next()
+	t.heading++
+	t.write(title)
+	t.flush()
+	t.heading--
+	p.headings.add(title, line, level)
+}
+
+func (p *mdParser) line(line string) {
+	t := &p.text
+	if p.fence != "" {
+		if strings.HasPrefix(strings.TrimSpace(line), p.fence) {
+			p.fence = ""
+			t.verse--
+			t.blank()
+			return
+		}
+		t.write(line)
+		t.flush()
+		return
+	}
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		p.flush()
+	case mdFence.MatchString(line):
+		p.flush()
+		p.fence = mdFence.FindStringSubmatch(line)[1]
+		t.blank()
+		t.verse++
+	case mdATXHeading.MatchString(line):
+		m := mdATXHeading.FindStringSubmatch(line)
+		p.heading(m[2], len(m[1]))
+	case len(p.para) > 0 && !p.quote && strings.Trim(trimmed, "=") == "":
+		// setext headings underline the paragraph before them
+		title := strings.Join(p.para, " ")
+		p.para = p.para[:0]
+		p.heading(title, 1)
+	case len(p.para) > 0 && !p.quote && strings.Trim(trimmed, "-") == "":
+		title := strings.Join(p.para, " ")
+		p.para = p.para[:0]
+		p.heading(title, 2)
+	case mdRule.MatchString(line):
+		p.flush()
+		t.blank()
+	case strings.HasPrefix(trimmed, ">"):
+		if !p.quote {
+			p.flush()
+		}
+		p.quote = true
+		p.para = append(p.para, mdInline(strings.TrimSpace(strings.TrimLeft(trimmed, ">"))))
+	case mdListItem.MatchString(line):
+		p.flush()
+		p.para = append(p.para, mdInline(trimmed))
+	default:
+		p.para = append(p.para, mdInline(trimmed))
+		if strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\") {
+			// hard line break
+			p.para[len(p.para)-1] = strings.TrimSuffix(p.para[len(p.para)-1], "\\")
+			p.flush()
+		}
+	}
+}
+
+// mdFrontMatter splits off a leading "---" YAML block and returns its title
+// and author, the only keys read from it.
+func mdFrontMatter(lines []string) (rest []string, title, author string) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines, "", ""
+	}
+	for i := 1; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		if l == "---" || l == "..." {
+			return lines[i+1:], title, author
+		}
+		key, value, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			title = value
+		case "author":
+			author = value
+		}
+	}
+	return lines, "", "" // never closed: not front matter
+}
+
+// loadMarkdown is the format's info function too.
+func loadMarkdown(path string) (LocalBook, error) {
+	content, err := readDocument(path, false)
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "markdown", path, err)
+	}
+	content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\r", "\n")
+	lines, title, author := mdFrontMatter(strings.Split(content, "\n"))
+
+	var p mdParser
+	for _, line := range lines {
+		p.line(line)
+	}
+	p.flush()
+	p.text.flush()
+	if len(p.text.lines) == 0 {
+		return LocalBook{}, newError(KindParse, "markdown", path, errNoText)
+	}
+	book := LocalBook{Title: title, Author: author, Lines: p.text.lines}
+	var heading string
+	book.TOC, heading = p.headings.result()
+	if book.Title == "" {
+		book.Title = heading
+	}
+	return book, nil
+}
@@ -0,0 +1,242 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"novel_reader/utils"
+)
+
+// metaVersion is the schema version of meta.json and local sidecars.
+//
+//	1  id, title, author, url, last_scraped, total_chapters (no version field)
+//	2  the version field itself and everything in BookMeta
+const metaVersion = 2
+
+// BookStatus tells whether the author is still writing the book.
+type BookStatus string
+
+const (
+	StatusUnknown   BookStatus = ""
+	StatusOngoing   BookStatus = "ongoing"
+	StatusCompleted BookStatus = "completed"
+)
+
+// BookMeta is the descriptive metadata online and local books share.
+// Times are RFC 3339; FirstUpdate is the earliest update time seen on the
+// source, LastUpdate the latest.
+type BookMeta struct {
+	Synopsis    string     `json:"synopsis,omitempty"`
+	Category    string     `json:"category,omitempty"`
+	Status      BookStatus `json:"status,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	WordCount   int        `json:"word_count,omitempty"`
+	CoverURL    string     `json:"cover_url,omitempty"`
+	SourceID    string     `json:"source_id,omitempty"` // the book's id on its site, e.g. biqu3628
+	FirstUpdate string     `json:"first_update,omitempty"`
+	LastUpdate  string     `json:"last_update,omitempty"`
+}
+
+// mergeMeta returns old with every field newer has a value for replaced.
+func mergeMeta(old, newer BookMeta) BookMeta {
+	set := func(dst *string, v string) {
+		if strings.TrimSpace(v) != "" {
+			*dst = v
+		}
+	}
+	set(&old.Synopsis, newer.Synopsis)
+	set(&old.Category, newer.Category)
+	set(&old.CoverURL, newer.CoverURL)
+	set(&old.SourceID, newer.SourceID)
+	set(&old.LastUpdate, newer.LastUpdate)
+	if newer.Status != StatusUnknown {
+		old.Status = newer.Status
+	}
+	if len(newer.Tags) > 0 {
+		old.Tags = newer.Tags
+	}
+	if newer.WordCount > 0 {
+		old.WordCount = newer.WordCount
+	}
+	if old.FirstUpdate == "" || (newer.FirstUpdate != "" && newer.FirstUpdate < old.FirstUpdate) {
+		old.FirstUpdate = newer.FirstUpdate
+	}
+	if old.FirstUpdate == "" {
+		old.FirstUpdate = old.LastUpdate
+	}
+	return old
+}
+
+// metaMigrations[v] upgrades meta.json from version v to v+1. Files
+// without a version field are version 1.
+var metaMigrations = map[int]func(*CachedNovel){
+	1: func(meta *CachedNovel) {
+		meta.SourceID = sourceBookID(meta.URL)
+	},
+}
+
+// migrateMeta brings meta up to metaVersion and reports whether anything
+// changed. Files written by a newer version are left alone.
+func migrateMeta(id string, meta *CachedNovel) bool {
+	if meta.Version > metaVersion {
+		return false
+	}
+	changed := meta.Version != metaVersion
+	v := meta.Version
+	if v == 0 {
+		v = 1
+	}
+	for ; v < metaVersion; v++ {
+		if migrate, ok := metaMigrations[v]; ok {
+			migrate(meta)
+		}
+	}
+	if meta.ID == "" {
+		meta.ID = id
+		changed = true
+	}
+	meta.Version = metaVersion
+	return changed
+}
+
+// sourceBookID returns the book's id on its site: the first path segment
+// of the book URL.
+func sourceBookID(novelURL string) string {
+	u, err := url.Parse(strings.TrimSpace(novelURL))
+	if err != nil {
+		return ""
+	}
+	book := strings.Trim(u.Path, "/")
+	if i := strings.Index(book, "/"); i >= 0 {
+		book = book[:i]
+	}
+	return book
+}
+
+// ParseBookStatus maps the status texts sources use to a BookStatus.
+func ParseBookStatus(s string) BookStatus {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == "":
+		return StatusUnknown
+	case strings.Contains(s, "完") || strings.Contains(s, "complete") || strings.Contains(s, "finish"):
+		return StatusCompleted
+	case strings.Contains(s, "连载") || strings.Contains(s, "連載") || strings.Contains(s, "ongoing") || strings.Contains(s, "serial"):
+		return StatusOngoing
+	}
+	return StatusUnknown
+}
+
+var updateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// parseUpdateTime normalises a source's update time to RFC 3339. Times
+// without a zone are taken as local time. Unknown formats are kept as is.
+func parseUpdateTime(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range updateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+// parseWordCount reads counts like "123456", "123.4万字" or "2.1万".
+func parseWordCount(s string) int {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, "字")
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "万"):
+		mult, s = 1e4, strings.TrimSuffix(s, "万")
+	case strings.HasSuffix(s, "千"):
+		mult, s = 1e3, strings.TrimSuffix(s, "千")
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int(n * mult)
+}
+
+// LocalMeta is the sidecar of a local book, "<name>.meta.json" next to the
+// file. Title and Author replace the ones taken from the file name.
+type LocalMeta struct {
+	Version int    `json:"version"`
+	Title   string `json:"title,omitempty"`
+	Author  string `json:"author,omitempty"`
+	BookMeta
+}
+
+// SidecarPath returns where the sidecar of the book at path lives. A folder
+// book keeps it inside the folder, where it also marks the folder as one.
+func SidecarPath(path string) string {
+	if isDir(path) {
+		return filepath.Join(path, folderMarker)
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".meta.json"
+}
+
+// LoadLocalMeta reads the sidecar of the book at path. A missing or empty
+// sidecar is not an error and yields an empty LocalMeta.
+func LoadLocalMeta(path string) (LocalMeta, error) {
+	var meta LocalMeta
+	sidecar := SidecarPath(path)
+	if info, err := os.Stat(sidecar); err == nil && info.Size() == 0 {
+		return LocalMeta{}, nil
+	}
+	data, err := os.ReadFile(sidecar)
+	if errors.Is(err, os.ErrNotExist) {
+		return LocalMeta{}, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &meta)
+	}
+	if err != nil {
+		return LocalMeta{}, cacheReadError("meta", sidecar, err)
+	}
+	return meta, nil
+}
+
+// EnsureLocalMeta writes meta as the sidecar of the book at path, for the
+// user to correct, unless the book has a sidecar with content already. It
+// returns the sidecar's path.
+func EnsureLocalMeta(path string, meta LocalMeta) (string, error) {
+	existing, err := LoadLocalMeta(path)
+	if err != nil {
+		return "", err
+	}
+	if existing.Title != "" || existing.Author != "" || !reflect.DeepEqual(existing.BookMeta, BookMeta{}) {
+		return SidecarPath(path), nil
+	}
+	return SidecarPath(path), SaveLocalMeta(path, meta)
+}
+
+// SaveLocalMeta writes the sidecar of the book at path. Unlike cache files
+// it keeps no .bak copy, which would clutter the library folder.
+func SaveLocalMeta(path string, meta LocalMeta) error {
+	if utils.ReadOnly() {
+		return utils.ErrReadOnly
+	}
+	meta.Version = metaVersion
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(SidecarPath(path), append(data, '\n'), 0644)
+}
@@ -0,0 +1,177 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"novel_reader/utils"
+)
+
+// bookIDMarker is created in the cache directory once title-keyed data has
+// been moved to book IDs.
+const bookIDMarker = ".book-ids-v1"
+
+// MigrateBookIDs moves cache directories named after titles and progress
+// entries keyed by "name|source" to stable book IDs. It runs once; legacy
+// progress entries that match no known book are kept as they are.
+func MigrateBookIDs() error {
+	if utils.ReadOnly() {
+		return nil // the instance holding the lock migrates
+	}
+	marker := filepath.Join(CacheDir(), bookIDMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+
+	onlineIDs, err := migrateCacheDirs()
+	if err != nil {
+		return err
+	}
+	if err := migrateProgressKeys(onlineIDs); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(CacheDir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(marker, nil, 0644)
+}
+
+// migrateCacheDirs renames every title-named cache directory to its book ID
+// and returns title → ID for all online books in the cache.
+func migrateCacheDirs() (map[string]string, error) {
+	titleToID := make(map[string]string)
+	dirs, err := os.ReadDir(CacheDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return titleToID, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		name := d.Name()
+		meta, err := LoadMeta(name)
+		if err != nil {
+			continue
+		}
+		if IsBookID(name) {
+			if meta.Title != "" {
+				titleToID[meta.Title] = name
+			}
+			continue
+		}
+		if meta.URL == "" {
+			continue
+		}
+
+		id := OnlineBookID(meta.URL)
+		if err := mergeDir(NovelCachePath(name), NovelCachePath(id)); err != nil {
+			return nil, err
+		}
+		meta.ID = id
+		if strings.TrimSpace(meta.Title) == "" {
+			meta.Title = name
+		}
+		if err := SaveMeta(id, meta); err != nil {
+			return nil, err
+		}
+		titleToID[name] = id
+		titleToID[meta.Title] = id
+	}
+	return titleToID, nil
+}
+
+// mergeDir moves src to dst. If dst already exists, files missing from dst
+// are moved over and the rest of src is dropped.
+func mergeDir(src, dst string) error {
+	if _, err := os.Stat(dst); errors.Is(err, os.ErrNotExist) {
+		return os.Rename(src, dst)
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		target := filepath.Join(dst, e.Name())
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(src, e.Name()), target); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(src)
+}
+
+// localIDsByName maps a base name without extension to the IDs of every
+// book file with that name in the configured library folders. Before IDs,
+// all of them shared one progress entry, so all of them inherit it.
+func localIDsByName() map[string][]string {
+	out := make(map[string][]string)
+	for _, dir := range utils.AppConfig.Library.Paths {
+		walkLocalBooks(dir, func(path string) error {
+			id, err := LocalBookID(path)
+			if err != nil {
+				return nil
+			}
+			name := LocalBookName(path)
+			out[name] = append(out[name], id)
+			return nil
+		})
+	}
+	return out
+}
+
+func migrateProgressKeys(onlineIDs map[string]string) error {
+	progressMap, err := utils.Load()
+	if err != nil {
+		return err
+	}
+
+	var localIDs map[string][]string
+	changed := false
+	for key, p := range progressMap {
+		name, source, ok := utils.LegacyKey(key)
+		if !ok {
+			continue
+		}
+
+		var ids []string
+		switch source {
+		case "online":
+			if id, ok := onlineIDs[name]; ok {
+				ids = []string{id}
+			}
+		case "local":
+			if localIDs == nil {
+				localIDs = localIDsByName()
+			}
+			ids = localIDs[name]
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		p.Title = name
+		p.Source = source
+		for _, id := range ids {
+			if existing, ok := progressMap[id]; ok && existing.LastRead.After(p.LastRead) {
+				continue
+			}
+			progressMap[id] = p
+		}
+		delete(progressMap, key)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return utils.Save(progressMap)
+}
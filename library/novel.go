@@ -1,12 +1,14 @@
 package library
 
 import (
+	"strings"
 	"time"
 
 	"novel_reader/lang"
 )
 
 type Novel struct {
+	ID        string // stable book ID, see OnlineBookID / LocalBookID
 	Name      string
 	Author    string
 	Path      string
@@ -16,14 +18,51 @@ type Novel struct {
 	Added     time.Time // when the novel file/cache was created or detected
 	OnlineURL string    // optional, empty if local
 	IsLocal   bool
+
+	FullyCached bool // online only: every chapter is on disk and readable offline
+
+	BookMeta // synopsis, status, tags …; from meta.json or the local sidecar
 }
 
 // list.Item interface for Bubble Tea
 func (n Novel) Title() string { return n.Name }
 func (n Novel) Description() string {
 	if n.IsLocal {
-		return lang.Active().Novel.LocalPrefix + " | " + n.Latest
+		desc := lang.Active().Novel.LocalPrefix
+		if n.Author != "" {
+			desc += " | " + n.Author
+		}
+		desc += " | " + n.Latest
+		if status := StatusLabel(n.Status); status != "" {
+			desc += " | " + status
+		}
+		return desc
+	}
+	desc := n.Author + " | " + n.Latest
+	if status := StatusLabel(n.Status); status != "" {
+		desc += " | " + status
+	}
+	if n.FullyCached {
+		desc += " | " + lang.Active().Novel.FullyCached
+	}
+	return desc
+}
+func (n Novel) FilterValue() string {
+	fields := append([]string{n.Name, n.Author, n.Category}, n.Tags...)
+	if n.IsLocal {
+		// the file name still finds a book its header or sidecar renamed
+		fields = append(fields, LocalBookName(n.Path))
+	}
+	return strings.Join(fields, " | ")
+}
+
+// StatusLabel returns the localized name of s, or "" when it is unknown.
+func StatusLabel(s BookStatus) string {
+	switch s {
+	case StatusOngoing:
+		return lang.Active().Novel.StatusOngoing
+	case StatusCompleted:
+		return lang.Active().Novel.StatusCompleted
 	}
-	return n.Author + " | " + n.Latest
+	return ""
 }
-func (n Novel) FilterValue() string { return n.Name + " | " + n.Author }
@@ -0,0 +1,55 @@
+package library
+
+import (
+	"unicode"
+
+	"novel_reader/utils"
+)
+
+// naturalLess orders names the way people number files: digit runs compare
+// by value, so "2.txt" comes before "10.txt", and Chinese numbers count too,
+// so 第二章 comes before 第十章.
+func naturalLess(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		na, ei, oka := numberAt(ra, i)
+		nb, ej, okb := numberAt(rb, j)
+		if oka && okb {
+			if na != nb {
+				return na < nb
+			}
+			i, j = ei, ej
+			continue
+		}
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	return len(ra)-i < len(rb)-j
+}
+
+// numberAt reads the run of Arabic or Chinese digits starting at i and
+// returns its value and the index after it.
+func numberAt(r []rune, i int) (int, int, bool) {
+	end := i
+	arabic := r[i] >= '0' && r[i] <= '9' || r[i] >= '０' && r[i] <= '９'
+	for end < len(r) {
+		c := r[end]
+		if arabic && !(c >= '0' && c <= '9' || c >= '０' && c <= '９') {
+			break
+		}
+		if !arabic && !utils.IsCNNumeral(c) {
+			break
+		}
+		end++
+	}
+	if end == i {
+		return 0, i, false
+	}
+	n, ok := utils.ParseCNNumber(string(r[i:end]))
+	return n, end, ok
+}
@@ -0,0 +1,427 @@
+package library
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Word (.docx) and OpenDocument (.odt) support. Both are zip files holding
+// XML: paragraphs become reader lines and headings become chapters, nested
+// by their outline level. Generated tables of contents, comments, deleted
+// revisions and footnote bodies are left out.
+
+var errNoText = errors.New("no readable text in the document")
+
+// officeText collects the paragraphs of a document and the headings among
+// them. Levels are outline levels, 0 for "Heading 1"; the TOC depth is the
+// level relative to the shallowest heading in the document.
+type officeText struct {
+	text   bookText
+	toc    []BookChapter
+	levels []int
+}
+
+// paragraph adds one document paragraph. Soft line breaks split it into
+// several reader lines. level is -1 for body text.
+func (o *officeText) paragraph(parts []string, level int) {
+	t := &o.text
+	if level >= 0 {
+		title := collapseSpace(strings.Join(parts, " "))
+		if title == "" {
+			return
+		}
+		o.toc = append(o.toc, BookChapter{Title: title, Line: t.next()})
+		o.levels = append(o.levels, level)
+		t.heading++
+		t.write(title)
+		t.flush()
+		t.heading--
+		return
+	}
+	for _, part := range parts {
+		t.write(part)
+		t.flush()
+	}
+}
+
+// book completes the metadata in book with the text and TOC.
+func (o *officeText) book(book LocalBook, op, path string) (LocalBook, error) {
+	if len(o.text.lines) == 0 {
+		return LocalBook{}, newError(KindParse, op, path, errNoText)
+	}
+	top := -1
+	for _, l := range o.levels {
+		if top < 0 || l < top {
+			top = l
+		}
+	}
+	for i := range o.toc {
+		o.toc[i].Depth = o.levels[i] - top
+	}
+	book.Lines, book.TOC = o.text.lines, o.toc
+	return book, nil
+}
+
+// decodeZipXML decodes the XML file name of an opened zip into v.
+func decodeZipXML(zr *zip.ReadCloser, name string, v any) error {
+	rc, err := openZipFile(zr, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := newOfficeDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// newOfficeDecoder is newXMLDecoder without the HTML void elements: office
+// XML is well-formed, and ODF has an element called meta.
+func newOfficeDecoder(r io.Reader) *xml.Decoder {
+	d := newXMLDecoder(r)
+	d.AutoClose = nil
+	return d
+}
+
+func openZipFile(zr *zip.ReadCloser, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("%s: not in the archive", name)
+}
+
+// officeMeta is the part of the Dublin Core metadata both formats store.
+type officeMeta struct {
+	Title       string   `xml:"title"`
+	Creators    []string `xml:"creator"`
+	Initial     string   `xml:"initial-creator"`
+	Description string   `xml:"description"`
+	Subject     string   `xml:"subject"`
+	Keywords    []string `xml:"keywords"` // docx: one comma separated list
+	Keyword     []string `xml:"keyword"`  // odt: one element each
+	Created     string   `xml:"created"`
+	CreatedODT  string   `xml:"creation-date"`
+}
+
+func (m officeMeta) book() LocalBook {
+	book := LocalBook{Title: collapseSpace(m.Title)}
+	if len(m.Creators) > 0 {
+		book.Author = collapseSpace(m.Creators[0])
+	}
+	if book.Author == "" {
+		book.Author = collapseSpace(m.Initial)
+	}
+	book.Synopsis = cleanSynopsis(m.Description)
+	book.Category = collapseSpace(m.Subject)
+	for _, list := range append(m.Keywords, m.Keyword...) {
+		for _, k := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ';' || r == '，' }) {
+			if k = collapseSpace(k); k != "" {
+				book.Tags = append(book.Tags, k)
+			}
+		}
+	}
+	created := m.Created
+	if created == "" {
+		created = m.CreatedODT
+	}
+	if created = collapseSpace(created); created != "" {
+		book.FirstUpdate = parseUpdateTime(created)
+	}
+	return book
+}
+
+// ----------------------------
+// DOCX
+// ----------------------------
+
+type docxStyles struct {
+	Styles []struct {
+		ID      string `xml:"styleId,attr"`
+		Name    val    `xml:"name"`
+		BasedOn val    `xml:"basedOn"`
+		Outline *val   `xml:"pPr>outlineLvl"`
+	} `xml:"style"`
+}
+
+// val is the w:val attribute most WordprocessingML properties carry.
+type val struct {
+	Val string `xml:"val,attr"`
+}
+
+// docxStyleLevel is the outline level a paragraph style gives, -1 for body
+// text and -2 for the entries of a generated table of contents.
+type docxStyleLevel map[string]int
+
+// headingLevel reads style names such as "heading 2" and "Heading2"; Word
+// keeps the English name in styles.xml whatever the UI language.
+func headingLevel(name string) int {
+	name = strings.ToLower(strings.ReplaceAll(name, " ", ""))
+	for _, prefix := range []string{"heading", "toc"} {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			if n, err := strconv.Atoi(rest); err == nil && n >= 1 && n <= 9 {
+				if prefix == "toc" {
+					return -2
+				}
+				return n - 1
+			}
+		}
+	}
+	return -1
+}
+
+func docxLevels(zr *zip.ReadCloser) docxStyleLevel {
+	levels := make(docxStyleLevel)
+	var styles docxStyles
+	if err := decodeZipXML(zr, "word/styles.xml", &styles); err != nil {
+		return levels
+	}
+	own := make(map[string]int)
+	set := make(map[string]bool) // the style decides itself, even for body text
+	parent := make(map[string]string)
+	for _, s := range styles.Styles {
+		level := headingLevel(s.Name.Val)
+		if s.Outline != nil {
+			level = -1 // 9 is "body text"
+			if n, err := strconv.Atoi(s.Outline.Val); err == nil && n < 9 {
+				level = n
+			}
+		}
+		own[s.ID] = level
+		set[s.ID] = s.Outline != nil || level != -1
+		parent[s.ID] = s.BasedOn.Val
+	}
+	for id := range own {
+		// other styles inherit from the style they are based on
+		cur := id
+		for i := 0; !set[cur] && parent[cur] != "" && i < 10; i++ {
+			cur = parent[cur]
+		}
+		levels[id] = own[cur]
+	}
+	return levels
+}
+
+// level returns the outline level of a paragraph style id, falling back to
+// the id itself when styles.xml did not list it.
+func (l docxStyleLevel) level(id string) int {
+	if level, ok := l[id]; ok {
+		return level
+	}
+	return headingLevel(id)
+}
+
+// loadDOCX reads word/document.xml. Paragraph styles, or an outline level
+// set on the paragraph itself, decide what is a heading. It is the format's
+// info function too: the TOC needs the whole document, and documents are
+// small.
+func loadDOCX(path string) (LocalBook, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "docx", path, err)
+	}
+	defer zr.Close()
+	rc, err := openZipFile(zr, "word/document.xml")
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "docx", path, err)
+	}
+	defer rc.Close()
+	styles := docxLevels(zr)
+	var meta officeMeta
+	decodeZipXML(zr, "docProps/core.xml", &meta) // optional
+
+	var (
+		doc   officeText
+		parts []string
+		cur   strings.Builder
+		level = -1
+		inP   bool
+		inT   bool
+		skip  int
+	)
+	d := newOfficeDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(doc.text.lines) > 0 {
+				break // keep what was read of a damaged file
+			}
+			return LocalBook{}, newError(KindParse, "docx", path, err)
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch el.Name.Local {
+			case "Fallback", "del", "instrText":
+				// a Fallback repeats the text of its AlternateContent
+				skip = 1
+			case "p":
+				inP, level = true, -1
+				parts = parts[:0]
+				cur.Reset()
+			case "pStyle":
+				level = styles.level(attr(el, "val"))
+			case "outlineLvl":
+				if n, err := strconv.Atoi(attr(el, "val")); err == nil && n < 9 {
+					level = n
+				}
+			case "t":
+				inT = true
+			case "tab":
+				cur.WriteByte(' ')
+			case "br", "cr":
+				if attr(el, "type") != "page" && attr(el, "type") != "column" {
+					parts = append(parts, cur.String())
+					cur.Reset()
+				}
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch el.Name.Local {
+			case "t":
+				inT = false
+			case "p":
+				if !inP {
+					continue
+				}
+				inP = false
+				if level == -2 {
+					continue // an entry of the document's own table of contents
+				}
+				doc.paragraph(append(parts, cur.String()), level)
+			}
+		case xml.CharData:
+			if inT && skip == 0 {
+				cur.Write(el)
+			}
+		}
+	}
+	doc.text.flush()
+	return doc.book(meta.book(), "docx", path)
+}
+
+// ----------------------------
+// ODT
+// ----------------------------
+
+type odtMeta struct {
+	Meta officeMeta `xml:"meta"`
+}
+
+// loadODT reads content.xml: <text:h> elements are headings at their
+// text:outline-level, <text:p> elements paragraphs. Like loadDOCX it is
+// also the info function.
+func loadODT(path string) (LocalBook, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "odt", path, err)
+	}
+	defer zr.Close()
+	rc, err := openZipFile(zr, "content.xml")
+	if err != nil {
+		return LocalBook{}, newError(KindParse, "odt", path, err)
+	}
+	defer rc.Close()
+	var meta odtMeta
+	decodeZipXML(zr, "meta.xml", &meta) // optional
+
+	var (
+		doc   officeText
+		parts []string
+		cur   strings.Builder
+		level = -1
+		depth int // nesting of <text:p>/<text:h>; paragraphs may hold frames with more
+		inDoc bool
+		skip  int
+	)
+	d := newOfficeDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(doc.text.lines) > 0 {
+				break
+			}
+			return LocalBook{}, newError(KindParse, "odt", path, err)
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch el.Name.Local {
+			case "body":
+				inDoc = true
+			case "note-body", "annotation", "tracked-changes", "table-of-content",
+				"index-body", "sequence-decls":
+				skip = 1
+			case "note-citation":
+				cur.WriteByte('[')
+			case "p", "h":
+				if depth == 0 {
+					parts = parts[:0]
+					cur.Reset()
+					level = -1
+					if el.Name.Local == "h" {
+						level = 0
+						if n, err := strconv.Atoi(attr(el, "outline-level")); err == nil && n >= 1 {
+							level = n - 1
+						}
+					}
+				}
+				depth++
+			case "s":
+				n, err := strconv.Atoi(attr(el, "c"))
+				if err != nil || n < 1 {
+					n = 1
+				}
+				cur.WriteString(strings.Repeat(" ", n))
+			case "tab":
+				cur.WriteByte(' ')
+			case "line-break":
+				parts = append(parts, cur.String())
+				cur.Reset()
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch el.Name.Local {
+			case "note-citation":
+				cur.WriteByte(']')
+			case "p", "h":
+				if depth == 0 {
+					continue
+				}
+				if depth--; depth == 0 {
+					doc.paragraph(append(parts, cur.String()), level)
+				}
+			}
+		case xml.CharData:
+			if inDoc && depth > 0 && skip == 0 {
+				cur.Write(el)
+			}
+		}
+	}
+	doc.text.flush()
+	return doc.book(meta.Meta.book(), "odt", path)
+}
@@ -0,0 +1,665 @@
+package library
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"novel_reader/utils"
+)
+
+// Chapter text of an online novel is kept in one packed file per book
+// instead of one N.txt per chapter:
+//
+//	chapters-<gen>.pack  concatenated gzip members, one per chapter, each
+//	                     named "<index>.txt" in its gzip header
+//	chapters.idx         JSON: which pack is live and the offset and
+//	                     length of every chapter in it
+//
+// Both formats are stdlib only; `zcat chapters-1.pack` prints every chapter
+// ever written. New and replaced chapters are appended, so a crash can at
+// worst leave unreferenced bytes at the end of the pack. Replaced and
+// deleted chapters leave dead bytes that compaction drops by copying the
+// live members into the next generation pack, switching the index over and
+// only then deleting the old pack.
+const (
+	packIndexName = "chapters.idx"
+	packLockName  = ".pack.lock"
+	packVersion   = 1
+
+	// compact once dead bytes exceed both this and the live bytes
+	compactMinDead = 256 << 10
+)
+
+type packEntry struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"` // compressed
+	Size   int64 `json:"size"`   // uncompressed
+}
+
+type packIndex struct {
+	Version  int               `json:"version"`
+	Gen      int               `json:"gen"`
+	Pack     string            `json:"pack"`
+	Dead     int64             `json:"dead"`
+	Chapters map[int]packEntry `json:"chapters"`
+}
+
+func (idx *packIndex) live() int64 {
+	var n int64
+	for _, e := range idx.Chapters {
+		n += e.Length
+	}
+	return n
+}
+
+// sortedIndices returns the chapter indices in ascending order.
+func (idx *packIndex) sortedIndices() []int {
+	out := make([]int, 0, len(idx.Chapters))
+	for n := range idx.Chapters {
+		out = append(out, n)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func packName(gen int) string {
+	return fmt.Sprintf("chapters-%d.pack", gen)
+}
+
+// withPack runs fn on the book's pack index while holding the book's pack
+// lock, migrating loose N.txt files first. An unreadable index is rebuilt
+// from the pack. The index is saved if fn reports a change. Nothing is
+// created for a book that has no cache directory. Only writers use it;
+// readers go through readPack.
+func withPack(id string, fn func(dir string, idx *packIndex) (bool, error)) error {
+	dir := NovelCachePath(id)
+	if _, err := os.Stat(dir); err != nil {
+		return cacheReadError("chapter", dir, err)
+	}
+	unlock, err := utils.LockFile(filepath.Join(dir, packLockName))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	idx, unreadable, err := loadPackIndex(dir)
+	if unreadable {
+		idx, err = rebuildPackIndex(dir)
+		if err == nil {
+			err = writePackIndex(dir, idx)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	migrated, err := migrateLooseChapters(dir, idx)
+	if err != nil {
+		return err
+	}
+	changed, err := fn(dir, idx)
+	if err != nil {
+		return err
+	}
+	if changed || migrated {
+		if err := writePackIndex(dir, idx); err != nil {
+			return err
+		}
+	}
+	if migrated {
+		removeLooseChapters(dir, idx)
+	}
+	return nil
+}
+
+// readPack runs fn on the book's pack index under a shared lock, so readers
+// neither wait for each other nor write anything. Loose N.txt files that no
+// writer has moved into the pack yet are passed along as they are. An
+// unreadable index is rebuilt under the exclusive lock first, or only in
+// memory when the data directories are read-only.
+func readPack(id string, fn func(dir string, idx *packIndex, loose map[int]string) error) error {
+	dir := NovelCachePath(id)
+	if _, err := os.Stat(dir); err != nil {
+		return cacheReadError("chapter", dir, err)
+	}
+	lockPath := filepath.Join(dir, packLockName)
+	unlock, err := utils.LockFileShared(lockPath)
+	if err != nil {
+		return err
+	}
+	defer func() { unlock() }()
+
+	idx, unreadable, err := loadPackIndex(dir)
+	switch {
+	case unreadable && utils.ReadOnly():
+		idx, err = rebuildPackIndex(dir)
+	case unreadable:
+		unlock()
+		unlock = func() {}
+		if _, err := RebuildPackIndex(id); err != nil {
+			return err
+		}
+		if unlock, err = utils.LockFileShared(lockPath); err != nil {
+			unlock = func() {}
+			return err
+		}
+		idx, _, err = loadPackIndex(dir)
+	}
+	if err != nil {
+		return err
+	}
+	loose := looseChapters(dir)
+	for n := range idx.Chapters {
+		delete(loose, n)
+	}
+	return fn(dir, idx, loose)
+}
+
+// loadPackIndex reads chapters.idx; a missing index is an empty pack. It is
+// not backed up, as an older copy may name a pack that compaction already
+// deleted. unreadable reports an index that exists but cannot be read or
+// parsed, which callers rebuild from the pack instead.
+func loadPackIndex(dir string) (idx *packIndex, unreadable bool, err error) {
+	idx = &packIndex{Version: packVersion, Gen: 1, Pack: packName(1), Chapters: map[int]packEntry{}}
+	path := filepath.Join(dir, packIndexName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, false, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, idx)
+	}
+	if err != nil {
+		return nil, true, cacheReadError("chapter", path, err)
+	}
+	if idx.Version > packVersion {
+		return nil, false, newError(KindCacheCorrupt, "chapter", "", fmt.Errorf("%s: unsupported pack version %d", path, idx.Version))
+	}
+	if idx.Chapters == nil {
+		idx.Chapters = map[int]packEntry{}
+	}
+	return idx, false, nil
+}
+
+// writePackIndex replaces chapters.idx atomically, without a .bak copy.
+func writePackIndex(dir string, idx *packIndex) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, packIndexName), append(data, '\n'), 0644)
+}
+
+// looseChapters returns the N.txt files of the pre-pack layout.
+func looseChapters(dir string) map[int]string {
+	out := make(map[int]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return out
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".txt") {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(name, ".txt")); err == nil {
+			out[n] = filepath.Join(dir, name)
+		}
+	}
+	return out
+}
+
+// migrateLooseChapters appends N.txt files that are not in the pack yet.
+// The files are removed by the caller once the index is saved.
+func migrateLooseChapters(dir string, idx *packIndex) (bool, error) {
+	loose := looseChapters(dir)
+	if len(loose) == 0 {
+		return false, nil
+	}
+	texts := make(map[int][]byte)
+	for n, path := range loose {
+		if _, ok := idx.Chapters[n]; ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		texts[n] = data
+	}
+	if err := appendChapters(dir, idx, texts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func removeLooseChapters(dir string, idx *packIndex) {
+	for n, path := range looseChapters(dir) {
+		if _, ok := idx.Chapters[n]; ok {
+			os.Remove(path)
+		}
+	}
+}
+
+// appendChapters compresses each text into its own gzip member at the end
+// of the live pack and points the index at it.
+func appendChapters(dir string, idx *packIndex, texts map[int][]byte) error {
+	if len(texts) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(dir, idx.Pack), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+
+	order := make([]int, 0, len(texts))
+	for n := range texts {
+		order = append(order, n)
+	}
+	sort.Ints(order)
+
+	var buf bytes.Buffer
+	for _, n := range order {
+		buf.Reset()
+		zw := gzip.NewWriter(&buf)
+		zw.Name = fmt.Sprintf("%d.txt", n)
+		zw.ModTime = time.Now()
+		if _, err := zw.Write(texts[n]); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		if old, ok := idx.Chapters[n]; ok {
+			idx.Dead += old.Length
+		}
+		idx.Chapters[n] = packEntry{Offset: offset, Length: int64(buf.Len()), Size: int64(len(texts[n]))}
+		offset += int64(buf.Len())
+	}
+	return f.Sync()
+}
+
+func readPackEntry(f *os.File, e packEntry) ([]byte, error) {
+	zr, err := gzip.NewReader(io.NewSectionReader(f, e.Offset, e.Length))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	zr.Multistream(false)
+	return io.ReadAll(zr)
+}
+
+// compactPack copies the live members into the next generation pack. The
+// old pack is deleted only after the index points at the new one.
+func compactPack(dir string, idx *packIndex) error {
+	src, err := os.Open(filepath.Join(dir, idx.Pack))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && len(idx.Chapters) == 0 {
+			idx.Dead = 0
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+
+	next := *idx
+	next.Gen = idx.Gen + 1
+	next.Pack = packName(next.Gen)
+	next.Dead = 0
+	next.Chapters = make(map[int]packEntry, len(idx.Chapters))
+
+	dst, err := os.OpenFile(filepath.Join(dir, next.Pack), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var offset int64
+	for _, n := range idx.sortedIndices() {
+		e := idx.Chapters[n]
+		if _, err := io.Copy(dst, io.NewSectionReader(src, e.Offset, e.Length)); err != nil {
+			dst.Close()
+			return err
+		}
+		next.Chapters[n] = packEntry{Offset: offset, Length: e.Length, Size: e.Size}
+		offset += e.Length
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := writePackIndex(dir, &next); err != nil {
+		return err
+	}
+	old := idx.Pack
+	*idx = next
+	return os.Remove(filepath.Join(dir, old))
+}
+
+func maybeCompact(dir string, idx *packIndex) error {
+	if idx.Dead > compactMinDead && idx.Dead > idx.live() {
+		return compactPack(dir, idx)
+	}
+	return nil
+}
+
+// ---------------- Chapter store API ----------------
+
+func SaveChapter(id string, chapterNum int, content string) error {
+	dir := NovelCachePath(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	err := withPack(id, func(dir string, idx *packIndex) (bool, error) {
+		if err := appendChapters(dir, idx, map[int][]byte{chapterNum: []byte(content)}); err != nil {
+			return false, err
+		}
+		return true, maybeCompact(dir, idx)
+	})
+	if err != nil {
+		return err
+	}
+	enforceQuotaAfterSave(id, chapterNum)
+	return nil
+}
+
+func LoadChapter(id string, chapterNum int) (string, error) {
+	var text string
+	err := readPack(id, func(dir string, idx *packIndex, loose map[int]string) error {
+		e, ok := idx.Chapters[chapterNum]
+		if !ok {
+			path, ok := loose[chapterNum]
+			if !ok {
+				return newError(KindNotFound, "chapter", "", fmt.Errorf("chapter %d of %s is not cached", chapterNum, id))
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return cacheReadError("chapter", path, err)
+			}
+			text = string(data)
+			return nil
+		}
+		f, err := os.Open(filepath.Join(dir, idx.Pack))
+		if err != nil {
+			return cacheReadError("chapter", filepath.Join(dir, idx.Pack), err)
+		}
+		defer f.Close()
+		data, err := readPackEntry(f, e)
+		if err != nil {
+			return cacheReadError("chapter", filepath.Join(dir, idx.Pack), err)
+		}
+		text = string(data)
+		return nil
+	})
+	return text, err
+}
+
+// CachedChapter is one chapter's text from the packed store.
+type CachedChapter struct {
+	Index int // 1-based
+	Text  string
+}
+
+// LoadCachedChapters returns every cached chapter of the book in order.
+// Chapters whose member fails to decompress are skipped.
+func LoadCachedChapters(id string) ([]CachedChapter, error) {
+	var out []CachedChapter
+	err := readPack(id, func(dir string, idx *packIndex, loose map[int]string) error {
+		var f *os.File
+		if len(idx.Chapters) > 0 {
+			var err error
+			if f, err = os.Open(filepath.Join(dir, idx.Pack)); err != nil {
+				return cacheReadError("chapter", filepath.Join(dir, idx.Pack), err)
+			}
+			defer f.Close()
+		}
+		order := idx.sortedIndices()
+		for n := range loose {
+			order = append(order, n)
+		}
+		sort.Ints(order)
+		for _, n := range order {
+			var data []byte
+			var err error
+			if path, ok := loose[n]; ok {
+				data, err = os.ReadFile(path)
+			} else {
+				data, err = readPackEntry(f, idx.Chapters[n])
+			}
+			if err != nil {
+				continue
+			}
+			out = append(out, CachedChapter{Index: n, Text: string(data)})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// CachedChapterIndices returns the 1-based indices of chapters whose text is
+// already on disk for the book.
+func CachedChapterIndices(id string) map[int]bool {
+	cached := make(map[int]bool)
+	readPack(id, func(_ string, idx *packIndex, loose map[int]string) error {
+		for n := range idx.Chapters {
+			cached[n] = true
+		}
+		for n := range loose {
+			cached[n] = true
+		}
+		return nil
+	})
+	return cached
+}
+
+// cachedChapterSizes returns the bytes each cached chapter takes in the pack.
+func cachedChapterSizes(id string) map[int]int64 {
+	sizes := make(map[int]int64)
+	readPack(id, func(_ string, idx *packIndex, loose map[int]string) error {
+		for n, e := range idx.Chapters {
+			sizes[n] = e.Length
+		}
+		for n, path := range loose {
+			if info, err := os.Stat(path); err == nil {
+				sizes[n] = info.Size()
+			}
+		}
+		return nil
+	})
+	return sizes
+}
+
+// DeleteChapters drops chapters from the book's pack and compacts it. It
+// returns how much smaller the book's directory got.
+func DeleteChapters(id string, indices []int) (int64, error) {
+	dir := NovelCachePath(id)
+	before := dirSize(dir)
+	err := withPack(id, func(dir string, idx *packIndex) (bool, error) {
+		changed := false
+		for _, n := range indices {
+			if e, ok := idx.Chapters[n]; ok {
+				idx.Dead += e.Length
+				delete(idx.Chapters, n)
+				changed = true
+			}
+		}
+		if !changed {
+			return false, nil
+		}
+		// compaction saves the index itself
+		return false, compactPack(dir, idx)
+	})
+	freed := before - dirSize(dir)
+	if freed < 0 {
+		freed = 0
+	}
+	return freed, err
+}
+
+// ExportNovel writes the book in the layout used before the packed store:
+// one N.txt per cached chapter plus meta.json and chapters.json. It returns
+// the number of chapters written.
+func ExportNovel(id, outDir string) (int, error) {
+	chapters, err := LoadCachedChapters(id)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return 0, err
+	}
+	for i, ch := range chapters {
+		path := filepath.Join(outDir, fmt.Sprintf("%d.txt", ch.Index))
+		if err := utils.WriteFileAtomic(path, []byte(ch.Text), 0644); err != nil {
+			return i, err
+		}
+	}
+	if meta, err := LoadMeta(id); err == nil {
+		if err := writeJSON(filepath.Join(outDir, "meta.json"), meta); err != nil {
+			return len(chapters), err
+		}
+	}
+	if list, err := LoadChapterList(id); err == nil {
+		if err := writeJSON(filepath.Join(outDir, "chapters.json"), list); err != nil {
+			return len(chapters), err
+		}
+	}
+	return len(chapters), nil
+}
+
+// countingReader counts the bytes gzip consumes. It implements
+// io.ByteReader so gzip reads through it directly instead of buffering
+// ahead, which keeps the count on member boundaries.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// packFiles returns the pack files in dir by generation.
+func packFiles(dir string) map[int]string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "chapters-*.pack"))
+	packs := make(map[int]string, len(matches))
+	for _, m := range matches {
+		var gen int
+		if _, err := fmt.Sscanf(filepath.Base(m), "chapters-%d.pack", &gen); err == nil && gen > 0 {
+			packs[gen] = filepath.Base(m)
+		}
+	}
+	return packs
+}
+
+// scanPack indexes a pack by reading it member by member. A chapter written
+// more than once keeps its last copy; a truncated member at the end and
+// whatever follows it are dropped.
+func scanPack(dir, name string, gen int) (*packIndex, error) {
+	idx := &packIndex{Version: packVersion, Gen: gen, Pack: name, Chapters: map[int]packEntry{}}
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := &countingReader{r: bufio.NewReader(f)}
+	for {
+		start := cr.n
+		zr, err := gzip.NewReader(cr)
+		if err != nil {
+			break // EOF or a torn tail
+		}
+		zr.Multistream(false)
+		size, err := io.Copy(io.Discard, zr)
+		if err != nil {
+			break
+		}
+		n, convErr := strconv.Atoi(strings.TrimSuffix(zr.Name, ".txt"))
+		if convErr != nil {
+			continue
+		}
+		if old, ok := idx.Chapters[n]; ok {
+			idx.Dead += old.Length
+		}
+		idx.Chapters[n] = packEntry{Offset: start, Length: cr.n - start, Size: size}
+	}
+	return idx, nil
+}
+
+// RebuildPackIndex recreates chapters.idx from the gzip member names in a
+// pack. The pack the old index names wins while it is still there: an
+// interrupted compaction leaves a partial next generation behind that the
+// index never switched to. Otherwise every pack is read and the one with
+// the most chapters wins, the newest of equals.
+func RebuildPackIndex(id string) (int, error) {
+	dir := NovelCachePath(id)
+	unlock, err := utils.LockFile(filepath.Join(dir, packLockName))
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	idx, err := rebuildPackIndex(dir)
+	if err != nil {
+		return 0, err
+	}
+	if err := writePackIndex(dir, idx); err != nil {
+		return 0, err
+	}
+	return len(idx.Chapters), nil
+}
+
+// rebuildPackIndex scans the packs in dir for RebuildPackIndex without
+// saving the result.
+func rebuildPackIndex(dir string) (*packIndex, error) {
+	packs := packFiles(dir)
+	var old packIndex
+	if data, err := os.ReadFile(filepath.Join(dir, packIndexName)); err == nil &&
+		json.Unmarshal(data, &old) == nil && packs[old.Gen] == old.Pack {
+		packs = map[int]string{old.Gen: old.Pack}
+	}
+
+	idx := &packIndex{Version: packVersion, Gen: 1, Pack: packName(1), Chapters: map[int]packEntry{}}
+	found := false
+	for gen, name := range packs {
+		candidate, err := scanPack(dir, name, gen)
+		if err != nil {
+			return nil, err
+		}
+		if !found || len(candidate.Chapters) > len(idx.Chapters) ||
+			len(candidate.Chapters) == len(idx.Chapters) && gen > idx.Gen {
+			idx, found = candidate, true
+		}
+	}
+	return idx, nil
+}
@@ -0,0 +1,247 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"novel_reader/utils"
+)
+
+// NovelCacheUsage describes the disk use of one cached online novel.
+type NovelCacheUsage struct {
+	ID       string
+	Title    string
+	Bytes    int64 // whole directory: pack, index, meta.json and chapters.json
+	Chapters int   // cached chapters
+	Current  int   // 1-based chapter the reader is on
+	Total    int   // chapters in the table of contents
+	LastRead time.Time
+	Pinned   bool
+}
+
+// Finished reports whether the reader has reached the last chapter.
+func (u NovelCacheUsage) Finished() bool {
+	return u.Total > 0 && u.Current >= u.Total
+}
+
+// CacheUsage returns per-novel cache sizes, largest first, and the total.
+func CacheUsage() ([]NovelCacheUsage, int64, error) {
+	dirs, err := os.ReadDir(CacheDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	progressMap, err := utils.Load()
+	if err != nil {
+		return nil, 0, newError(KindCacheCorrupt, "progress", "", err)
+	}
+
+	var usage []NovelCacheUsage
+	var total int64
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		u := novelCacheUsage(d.Name(), progressMap)
+		usage = append(usage, u)
+		total += u.Bytes
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].Bytes > usage[j].Bytes })
+	return usage, total, nil
+}
+
+func novelCacheUsage(id string, progressMap map[string]utils.Progress) NovelCacheUsage {
+	u := NovelCacheUsage{ID: id, Title: id, Current: 1, Pinned: utils.IsPinned(id)}
+	if meta, err := LoadMeta(id); err == nil && meta.Title != "" {
+		u.Title = meta.Title
+		u.Total = meta.TotalChapters
+	}
+	if chapters, err := LoadChapterList(id); err == nil {
+		u.Total = len(chapters)
+	}
+	if p, ok := utils.GetProgress(progressMap, id); ok {
+		u.Current = p.Chapter + 1
+		u.LastRead = p.LastRead
+	}
+	u.Chapters = len(CachedChapterIndices(id))
+	u.Bytes = dirSize(NovelCachePath(id))
+	return u
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// evictable returns cached chapters outside the keep window around the
+// reading position, farthest first.
+func evictable(u NovelCacheUsage, keep int) []int {
+	var out []int
+	for idx := range CachedChapterIndices(u.ID) {
+		if idx < u.Current-keep || idx > u.Current+keep {
+			out = append(out, idx)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return distance(out[i], u.Current) > distance(out[j], u.Current)
+	})
+	return out
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// removeChapters deletes the given chapters, in order, until limit bytes
+// would be freed (limit <= 0 means all of them) and returns the bytes freed.
+func removeChapters(id string, indices []int, limit int64) (int64, error) {
+	if limit > 0 {
+		sizes := cachedChapterSizes(id)
+		var planned int64
+		for i, idx := range indices {
+			if planned >= limit {
+				indices = indices[:i]
+				break
+			}
+			planned += sizes[idx]
+		}
+	}
+	if len(indices) == 0 {
+		return 0, nil
+	}
+	return DeleteChapters(id, indices)
+}
+
+func loadUsage(id string) (NovelCacheUsage, error) {
+	progressMap, err := utils.Load()
+	if err != nil {
+		return NovelCacheUsage{}, newError(KindCacheCorrupt, "progress", "", err)
+	}
+	return novelCacheUsage(id, progressMap), nil
+}
+
+// TrimNovelCache drops the book's chapters outside the keep window around
+// the reading position and returns the bytes reclaimed. Pinned books are
+// trimmed too; pinning only protects against automatic eviction.
+func TrimNovelCache(id string) (int64, error) {
+	if utils.ReadOnly() {
+		return 0, utils.ErrReadOnly
+	}
+	u, err := loadUsage(id)
+	if err != nil {
+		return 0, err
+	}
+	return removeChapters(id, evictable(u, utils.CacheKeepAround()), 0)
+}
+
+// ClearNovelCache drops every cached chapter of the book but keeps its
+// metadata and table of contents, so it stays in the library.
+func ClearNovelCache(id string) (int64, error) {
+	if utils.ReadOnly() {
+		return 0, utils.ErrReadOnly
+	}
+	var indices []int
+	for idx := range CachedChapterIndices(id) {
+		indices = append(indices, idx)
+	}
+	return removeChapters(id, indices, 0)
+}
+
+// RemoveNovelCache deletes the book's cache directory and returns the bytes
+// reclaimed.
+func RemoveNovelCache(id string) (int64, error) {
+	if utils.ReadOnly() {
+		return 0, utils.ErrReadOnly
+	}
+	dir := NovelCachePath(id)
+	size := dirSize(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// EnforceQuota evicts chapters until the cache fits the configured quota.
+// Chapters within the keep window of each book and pinned books are never
+// touched. Finished books go first, then the least recently read. It returns
+// the bytes reclaimed; the cache may stay over quota if everything left is
+// protected.
+func EnforceQuota() (int64, error) {
+	return enforceQuota("", 0)
+}
+
+// enforceQuotaAfterSave evicts after a chapter download. The chapters around
+// the one just saved are kept too, as the reader may not have recorded its
+// new position yet. Eviction is best effort here; the next download or
+// start tries again.
+func enforceQuotaAfterSave(id string, chapterNum int) {
+	_, _ = enforceQuota(id, chapterNum)
+}
+
+// enforceQuota is EnforceQuota that also keeps the window around chapter
+// keepChapter of book keepID.
+func enforceQuota(keepID string, keepChapter int) (int64, error) {
+	quota := utils.CacheQuotaBytes()
+	if quota <= 0 || utils.ReadOnly() {
+		return 0, nil
+	}
+	usage, total, err := CacheUsage()
+	if err != nil || total <= quota {
+		return 0, err
+	}
+
+	candidates := usage[:0:0]
+	for _, u := range usage {
+		if !u.Pinned {
+			candidates = append(candidates, u)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Finished() != b.Finished() {
+			return a.Finished()
+		}
+		return a.LastRead.Before(b.LastRead)
+	})
+
+	keep := utils.CacheKeepAround()
+	var freed int64
+	for _, u := range candidates {
+		over := total - freed - quota
+		if over <= 0 {
+			break
+		}
+		indices := evictable(u, keep)
+		if u.ID == keepID {
+			kept := indices[:0]
+			for _, idx := range indices {
+				if distance(idx, keepChapter) > keep {
+					kept = append(kept, idx)
+				}
+			}
+			indices = kept
+		}
+		n, err := removeChapters(u.ID, indices, over)
+		freed += n
+		if err != nil {
+			return freed, err
+		}
+	}
+	return freed, nil
+}
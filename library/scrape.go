@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -18,27 +17,46 @@ var httpClient = &http.Client{
 	Timeout: 60 * time.Second,
 }
 
+var (
+	errNoChapters   = errors.New("no chapters found")
+	errEmptyChapter = errors.New("chapter page has no content")
+)
+
 // ----------------------------
 // TYPES & MODELS
 // ----------------------------
 type ChapterLink struct {
-	Index int    `json:"index"`
-	Link  string `json:"link"`
-	Title string `json:"title"`
+	Index  int    `json:"index"`
+	Link   string `json:"link"`
+	Title  string `json:"title"`
+	Volume string `json:"volume,omitempty"` // section header above it on the TOC page
 }
 
 // ----------------------------
 // UTILS
 // ----------------------------
-func fetchHTML(url string) (*goquery.Document, error) {
-	req, _ := http.NewRequest("GET", url, nil)
+func fetchHTML(op, url string) (*goquery.Document, error) {
+	if NetworkDisabled() {
+		return nil, offlineError(op, url)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, newError(KindParse, op, url, err)
+	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36")
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, classifyRequestError(op, url, err)
 	}
 	defer resp.Body.Close()
-	return goquery.NewDocumentFromReader(resp.Body)
+	if err := checkResponse(op, url, resp); err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, newError(KindParse, op, url, err)
+	}
+	return doc, nil
 }
 
 func cleanChapterTitle(raw string) string {
@@ -55,28 +73,40 @@ func cleanChapterTitle(raw string) string {
 // ----------------------------
 func GetChapterLinks(novelURL, latestChapter string) ([]ChapterLink, error) {
 	var chapters []ChapterLink
+	volume := "" // headers carry over to the next TOC page
 	for page := 1; page <= 500; page++ {
 		url := fmt.Sprintf("%s%d", novelURL, page)
-		doc, err := fetchHTML(url)
+		doc, err := fetchHTML("toc", url)
 		if err != nil {
+			if page > 1 && KindOf(err) == KindNotFound {
+				break
+			}
 			return nil, err
 		}
 
-		ul := doc.Find("ul.section-list.fix").Eq(1)
+		ul := doc.Find(selTOCList).Eq(1)
 		if ul.Length() == 0 {
 			break
 		}
 
 		var last string
-		ul.Find("li").Each(func(i int, s *goquery.Selection) {
-			link, _ := s.Find("a").Attr("href")
+		ul.Find(selTOCItem).Each(func(i int, s *goquery.Selection) {
+			link, ok := s.Find("a").Attr("href")
+			if !ok {
+				// items without a link are the volume headers of the list
+				if text := strings.TrimSpace(s.Text()); text != "" {
+					volume = text
+				}
+				return
+			}
 			link = BaseURL + link
 			rawTitle := strings.TrimSpace(s.Text())
 			title := cleanChapterTitle(rawTitle)
 			chapters = append(chapters, ChapterLink{
-				Index: len(chapters) + 1,
-				Link:  link,
-				Title: title,
+				Index:  len(chapters) + 1,
+				Link:   link,
+				Title:  title,
+				Volume: volume,
 			})
 			last = rawTitle
 		})
@@ -99,7 +129,7 @@ func ScrapeChapter(ch ChapterLink) (string, error) {
 			pageURL = strings.Replace(ch.Link, ".html", fmt.Sprintf("_%d.html", subpage), 1)
 		}
 
-		doc, err := fetchHTML(pageURL)
+		doc, err := fetchHTML("chapter", pageURL)
 		if err != nil {
 			if subpage == 1 {
 				return "", err
@@ -108,7 +138,7 @@ func ScrapeChapter(ch ChapterLink) (string, error) {
 		}
 
 		pContent := ""
-		doc.Find("#content p").Each(func(i int, s *goquery.Selection) {
+		doc.Find(selChapterPara).Each(func(i int, s *goquery.Selection) {
 			text := strings.TrimSpace(s.Text())
 			if text != "" {
 				pContent += "　　" + text + "\n"
@@ -116,7 +146,7 @@ func ScrapeChapter(ch ChapterLink) (string, error) {
 		})
 
 		if pContent == "" {
-			text := strings.TrimSpace(doc.Find("#content").Text())
+			text := strings.TrimSpace(doc.Find(selChapterBody).Text())
 			for _, line := range strings.Split(text, "\n") {
 				line = strings.TrimSpace(line)
 				if line != "" {
@@ -125,7 +155,7 @@ func ScrapeChapter(ch ChapterLink) (string, error) {
 			}
 		}
 
-		title := strings.TrimSpace(doc.Find("h1.title").Text())
+		title := strings.TrimSpace(doc.Find(selChapterHead).Text())
 		if subpage == 1 {
 			content.WriteString(fmt.Sprintf("%s\n%s\n", title, pContent))
 		} else {
@@ -139,6 +169,116 @@ func ScrapeChapter(ch ChapterLink) (string, error) {
 	return content.String(), nil
 }
 
+// ----------------------------
+// BOOK INFO PAGE
+// ----------------------------
+
+// bookInfo is what a book's info page says about it.
+type bookInfo struct {
+	Author string
+	Latest string
+	BookMeta
+}
+
+// parseBookInfo reads the og:novel meta tags most sources carry, then the
+// "label：value" lines of the info block for anything they left out.
+func parseBookInfo(doc *goquery.Document) bookInfo {
+	og := func(property string) string {
+		v, _ := doc.Find(`meta[property="og:` + property + `"]`).Attr("content")
+		return strings.TrimSpace(v)
+	}
+	info := bookInfo{
+		Author: og("novel:author"),
+		Latest: og("novel:latest_chapter_name"),
+		BookMeta: BookMeta{
+			Synopsis:   cleanSynopsis(og("description")),
+			Category:   og("novel:category"),
+			Status:     ParseBookStatus(og("novel:status")),
+			CoverURL:   og("image"),
+			LastUpdate: og("novel:update_time"),
+		},
+	}
+
+	doc.Find(selInfoLines).Each(func(_ int, sel *goquery.Selection) {
+		label, value, ok := splitInfoLine(sel.Text())
+		if !ok {
+			return
+		}
+		switch {
+		case strings.Contains(label, "作"):
+			if info.Author == "" {
+				info.Author = value
+			}
+		case strings.Contains(label, "最新章节"):
+			if info.Latest != "" || sel.HasClass("xs-show") {
+				return
+			}
+			if t := strings.TrimSpace(sel.Find("a").Text()); t != "" {
+				value = t
+			}
+			info.Latest = value
+		case strings.Contains(label, "类") || strings.Contains(label, "分类"):
+			if info.Category == "" {
+				info.Category = value
+			}
+		case strings.Contains(label, "状态"):
+			if info.Status == StatusUnknown {
+				info.Status = ParseBookStatus(value)
+			}
+		case strings.Contains(label, "更新"):
+			if info.LastUpdate == "" {
+				info.LastUpdate = value
+			}
+		case strings.Contains(label, "字数"):
+			info.WordCount = parseWordCount(value)
+		case strings.Contains(label, "标签"):
+			info.Tags = strings.FieldsFunc(value, func(r rune) bool {
+				return r == ',' || r == '，' || r == '、' || r == ' ' || r == '/'
+			})
+		}
+	})
+
+	if info.Synopsis == "" {
+		info.Synopsis = cleanSynopsis(doc.Find(selInfoDesc).First().Text())
+	}
+	if info.CoverURL == "" {
+		info.CoverURL, _ = doc.Find(selInfoCover).First().Attr("src")
+	}
+	if strings.HasPrefix(info.CoverURL, "/") {
+		info.CoverURL = BaseURL + info.CoverURL
+	}
+	info.LastUpdate = parseUpdateTime(info.LastUpdate)
+	return info
+}
+
+// splitInfoLine splits "作&nbsp;&nbsp;者：某某" into a label without
+// spaces and a trimmed value.
+func splitInfoLine(text string) (label, value string, ok bool) {
+	text = strings.TrimSpace(text)
+	i := strings.Index(text, "：")
+	sep := len("：")
+	if i < 0 {
+		i, sep = strings.Index(text, ":"), 1
+	}
+	if i < 0 {
+		return "", "", false
+	}
+	label = strings.Join(strings.Fields(text[:i]), "")
+	return label, strings.TrimSpace(text[i+sep:]), true
+}
+
+// cleanSynopsis drops blank lines and the indentation sources pad
+// synopses with.
+func cleanSynopsis(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // ----------------------------
 // SCRAPE & SAVE ALL CHAPTERS
 // ----------------------------
@@ -146,35 +286,23 @@ func ScrapeAndSaveChapters(sr SearchResult) (Novel, error) {
 	if strings.HasPrefix(sr.URL, "/") {
 		sr.URL = BaseURL + sr.URL
 	}
+	id := OnlineBookID(sr.URL)
+	prev, prevErr := LoadMeta(id)
 
+	info := sr.ToNovel().BookMeta
 	author := strings.TrimSpace(sr.Author)
 	latest := strings.TrimSpace(sr.Latest)
-	if author == "" || latest == "" {
-		if doc, err := fetchHTML(sr.URL); err == nil {
-			doc.Find(".top .fix p").Each(func(_ int, sel *goquery.Selection) {
-				text := strings.TrimSpace(sel.Text())
-				switch {
-				case author == "" && (strings.HasPrefix(text, "作") || strings.Contains(text, "作者")):
-					if parts := strings.Split(text, "："); len(parts) == 2 {
-						author = strings.TrimSpace(parts[1])
-					} else if parts := strings.Split(text, ":"); len(parts) == 2 {
-						author = strings.TrimSpace(parts[1])
-					}
-				case latest == "":
-					if sel.HasClass("xs-show") {
-						return
-					}
-					if strings.Contains(text, "最新章节") {
-						if t := strings.TrimSpace(sel.Find("a").Text()); t != "" {
-							latest = t
-						} else if parts := strings.Split(text, "："); len(parts) == 2 {
-							latest = strings.TrimSpace(parts[1])
-						} else if parts := strings.Split(text, ":"); len(parts) == 2 {
-							latest = strings.TrimSpace(parts[1])
-						}
-					}
-				}
-			})
+	needInfo := author == "" || latest == "" || prevErr != nil || prev.Synopsis == ""
+	if needInfo && !NetworkDisabled() {
+		if doc, err := fetchHTML("meta", sr.URL); err == nil {
+			page := parseBookInfo(doc)
+			if author == "" {
+				author = page.Author
+			}
+			if latest == "" {
+				latest = page.Latest
+			}
+			info = mergeMeta(info, page.BookMeta)
 		}
 	}
 	if author != "" {
@@ -184,21 +312,27 @@ func ScrapeAndSaveChapters(sr SearchResult) (Novel, error) {
 		sr.Latest = latest
 	}
 
-	cacheDir := NovelCachePath(sr.Name)
+	cacheDir := NovelCachePath(id)
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return Novel{}, err
 	}
 
 	// Load progress map
-	progressMap, _ := utils.Load()
+	progressMap, err := utils.Load()
+	if err != nil {
+		return Novel{}, newError(KindCacheCorrupt, "progress", "", err)
+	}
 	prog := utils.Progress{}
-	if p, ok := utils.GetProgress(progressMap, sr.Name, "online"); ok {
+	if p, ok := utils.GetProgress(progressMap, id); ok {
 		prog = p
 	}
 
-	chapters, err := loadOrRefreshChapterList(sr.Name, sr.URL, sr.Latest)
-	if err != nil || len(chapters) == 0 {
-		return Novel{}, fmt.Errorf("no chapters found: %w", err)
+	chapters, err := loadOrRefreshChapterList(id, sr.URL, sr.Latest)
+	if err != nil {
+		return Novel{}, err
+	}
+	if len(chapters) == 0 {
+		return Novel{}, newError(KindParse, "toc", sr.URL, errNoChapters)
 	}
 
 	// Determine the chapter we should show (1-based index)
@@ -209,18 +343,30 @@ func ScrapeAndSaveChapters(sr SearchResult) (Novel, error) {
 	currentChapter := chapters[currentIndex-1]
 	latestOverall := chapters[len(chapters)-1]
 
-	chapterPath := filepath.Join(cacheDir, fmt.Sprintf("%d.txt", currentChapter.Index))
-	if _, err := os.Stat(chapterPath); errors.Is(err, os.ErrNotExist) {
+	if !CachedChapterIndices(id)[currentChapter.Index] && !NetworkDisabled() {
 		content, err := ScrapeChapterWithSubpages(currentChapter)
 		if err != nil {
 			return Novel{}, fmt.Errorf("failed to scrape chapter %d: %w", currentChapter.Index, err)
 		}
-		if err := os.WriteFile(chapterPath, []byte(content), 0644); err != nil {
+		if err := SaveChapter(id, currentChapter.Index, content); err != nil {
 			return Novel{}, fmt.Errorf("failed to save chapter %d: %w", currentChapter.Index, err)
 		}
 	}
 
+	meta := CachedNovel{}
+	if prevErr == nil {
+		meta = prev
+	}
+	meta.ID = id
+	meta.Title = sr.Name
+	meta.Author = sr.Author
+	meta.URL = sr.URL
+	meta.LastScraped = time.Now().Format(time.RFC3339)
+	meta.TotalChapters = len(chapters)
+	meta.BookMeta = mergeMeta(meta.BookMeta, info)
+
 	novel := Novel{
+		ID:        id,
 		Name:      sr.Name,
 		Author:    strings.TrimSpace(sr.Author),
 		Path:      cacheDir,
@@ -230,6 +376,7 @@ func ScrapeAndSaveChapters(sr SearchResult) (Novel, error) {
 		Added:     time.Now(),
 		OnlineURL: sr.URL,
 		IsLocal:   false,
+		BookMeta:  meta.BookMeta,
 	}
 
 	// Update progress map
@@ -237,16 +384,15 @@ func ScrapeAndSaveChapters(sr SearchResult) (Novel, error) {
 	prog.LastRead = time.Now()
 	prog.Chapter = currentIndex - 1
 	prog.Source = "online"
-	utils.SetProgress(progressMap, sr.Name, "online", prog)
-	utils.Save(progressMap)
-
-	SaveMeta(sr.Name, CachedNovel{
-		Title:         sr.Name,
-		Author:        sr.Author,
-		URL:           sr.URL,
-		LastScraped:   time.Now().Format(time.RFC3339),
-		TotalChapters: len(chapters),
-	})
+	prog.Title = sr.Name
+	utils.SetProgress(progressMap, id, prog)
+	if err := utils.Save(progressMap); err != nil && !errors.Is(err, utils.ErrReadOnly) {
+		return Novel{}, fmt.Errorf("failed to save progress: %w", err)
+	}
+
+	if err := SaveMeta(id, meta); err != nil {
+		return Novel{}, fmt.Errorf("failed to save metadata: %w", err)
+	}
 
 	return novel, nil
 }
@@ -264,7 +410,7 @@ func ScrapeChapterWithSubpages(ch ChapterLink) (string, error) {
 			pageURL = strings.Replace(ch.Link, ".html", fmt.Sprintf("_%d.html", subpage), 1)
 		}
 
-		doc, err := fetchHTML(pageURL)
+		doc, err := fetchHTML("chapter", pageURL)
 		if err != nil {
 			if subpage == 1 {
 				return "", err
@@ -273,7 +419,7 @@ func ScrapeChapterWithSubpages(ch ChapterLink) (string, error) {
 		}
 
 		var pContent strings.Builder
-		doc.Find("#content p").Each(func(i int, s *goquery.Selection) {
+		doc.Find(selChapterPara).Each(func(i int, s *goquery.Selection) {
 			text := strings.TrimSpace(s.Text())
 			if text != "" {
 				pContent.WriteString("　　" + text + "\n")
@@ -281,7 +427,7 @@ func ScrapeChapterWithSubpages(ch ChapterLink) (string, error) {
 		})
 
 		if pContent.Len() == 0 {
-			text := strings.TrimSpace(doc.Find("#content").Text())
+			text := strings.TrimSpace(doc.Find(selChapterBody).Text())
 			for _, line := range strings.Split(text, "\n") {
 				line = strings.TrimSpace(line)
 				if line != "" {
@@ -291,13 +437,16 @@ func ScrapeChapterWithSubpages(ch ChapterLink) (string, error) {
 		}
 
 		currentContent := pContent.String()
+		if subpage == 1 && currentContent == "" && doc.Find(selChapterBody).Length() == 0 {
+			return "", newError(KindParse, "chapter", pageURL, errEmptyChapter)
+		}
 		if currentContent == previousContent {
 			break
 		}
 		previousContent = currentContent
 
 		if subpage == 1 {
-			title := strings.TrimSpace(doc.Find("h1.title").Text())
+			title := strings.TrimSpace(doc.Find(selChapterHead).Text())
 			content.WriteString(fmt.Sprintf("%s\n%s", title, currentContent))
 		} else {
 			content.WriteString(currentContent)
@@ -307,8 +456,8 @@ func ScrapeChapterWithSubpages(ch ChapterLink) (string, error) {
 	return content.String(), nil
 }
 
-func loadOrRefreshChapterList(title, novelURL, latest string) ([]ChapterLink, error) {
-	chapters, err := LoadChapterList(title)
+func loadOrRefreshChapterList(id, novelURL, latest string) ([]ChapterLink, error) {
+	chapters, err := LoadChapterList(id)
 	refresh := err != nil || len(chapters) == 0
 
 	if !refresh && latest != "" {
@@ -322,15 +471,23 @@ func loadOrRefreshChapterList(title, novelURL, latest string) ([]ChapterLink, er
 		refresh = !found
 	}
 
+	if refresh && NetworkDisabled() {
+		// Stale but present is fine offline; only a missing list is fatal.
+		if err == nil && len(chapters) > 0 {
+			return chapters, nil
+		}
+		return nil, offlineError("toc", novelURL)
+	}
+
 	if refresh {
 		chapters, err = GetChapterLinks(novelURL, latest)
 		if err != nil {
 			return nil, err
 		}
 		if len(chapters) == 0 {
-			return nil, fmt.Errorf("no chapters found")
+			return nil, newError(KindParse, "toc", novelURL, errNoChapters)
 		}
-		if err := SaveChapterList(title, chapters); err != nil {
+		if err := SaveChapterList(id, chapters); err != nil {
 			return nil, err
 		}
 	}
@@ -338,42 +495,97 @@ func loadOrRefreshChapterList(title, novelURL, latest string) ([]ChapterLink, er
 	return chapters, nil
 }
 
-func EnsureChapterCached(title string, index int) (ChapterLink, string, bool, error) {
-	meta, err := LoadMeta(title)
+// RefillChapterList fetches the chapter list of an online book again, for
+// chapters the stored one lacks. Cached chapters that moved to another
+// index are dropped so they are fetched again, and the reading position
+// follows its chapter. It returns how many chapters were added.
+func RefillChapterList(id string) (int, error) {
+	meta, err := LoadMeta(id)
+	if err != nil {
+		return 0, err
+	}
+	if meta.URL == "" {
+		return 0, newError(KindCacheCorrupt, "meta", "", fmt.Errorf("missing novel URL for %s", id))
+	}
+	old, _ := LoadChapterList(id)
+	chapters, err := GetChapterLinks(meta.URL, "")
 	if err != nil {
-		return ChapterLink{}, "", false, err
+		return 0, err
+	}
+	if len(chapters) <= len(old) {
+		return 0, nil
+	}
+
+	newIndex := make(map[string]int, len(chapters))
+	for _, ch := range chapters {
+		newIndex[ch.Link] = ch.Index
+	}
+	moved := make(map[int]int) // old index → new index, 0 when gone
+	for _, ch := range old {
+		if n := newIndex[ch.Link]; n != ch.Index {
+			moved[ch.Index] = n
+		}
+	}
+	var stale []int
+	for n := range CachedChapterIndices(id) {
+		if _, ok := moved[n]; ok {
+			stale = append(stale, n)
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := DeleteChapters(id, stale); err != nil {
+			return 0, err
+		}
+	}
+	if err := SaveChapterList(id, chapters); err != nil {
+		return 0, err
+	}
+
+	if progressMap, err := utils.Load(); err == nil {
+		if p, ok := utils.GetProgress(progressMap, id); ok {
+			if n := moved[p.Chapter+1]; n > 0 {
+				p.Chapter = n - 1
+				utils.SetProgress(progressMap, id, p)
+				_ = utils.Save(progressMap)
+			}
+		}
+	}
+	return len(chapters) - len(old), nil
+}
+
+func EnsureChapterCached(id string, index int) (ChapterLink, bool, error) {
+	meta, err := LoadMeta(id)
+	if err != nil {
+		return ChapterLink{}, false, err
 	}
 
 	if meta.URL == "" {
-		return ChapterLink{}, "", false, fmt.Errorf("missing novel URL for %s", title)
+		return ChapterLink{}, false, newError(KindCacheCorrupt, "meta", "", fmt.Errorf("missing novel URL for %s", id))
 	}
 
-	chapters, err := loadOrRefreshChapterList(title, meta.URL, "")
+	chapters, err := loadOrRefreshChapterList(id, meta.URL, "")
 	if err != nil {
-		return ChapterLink{}, "", false, err
+		return ChapterLink{}, false, err
 	}
 
 	if index <= 0 || index > len(chapters) {
-		return ChapterLink{}, "", false, fmt.Errorf("chapter index %d out of range", index)
+		return ChapterLink{}, false, newError(KindNotFound, "chapter", "", fmt.Errorf("chapter index %d out of range", index))
 	}
 
 	ch := chapters[index-1]
-	cacheDir := NovelCachePath(title)
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return ChapterLink{}, "", false, err
-	}
-
-	path := filepath.Join(cacheDir, fmt.Sprintf("%d.txt", ch.Index))
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+	if !CachedChapterIndices(id)[ch.Index] {
+		if NetworkDisabled() {
+			return ch, false, offlineError("chapter", ch.Link)
+		}
 		content, err := ScrapeChapterWithSubpages(ch)
 		if err != nil {
-			return ChapterLink{}, "", false, err
+			return ChapterLink{}, false, err
 		}
-		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-			return ChapterLink{}, "", false, err
+		if err := SaveChapter(id, ch.Index, content); err != nil {
+			return ChapterLink{}, false, err
 		}
-		return ch, path, true, nil
+		return ch, true, nil
 	}
 
-	return ch, path, false, nil
+	return ch, false, nil
 }
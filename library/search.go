@@ -1,6 +1,7 @@
 package library
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -32,65 +33,53 @@ func (sr SearchResult) FilterValue() string { return sr.Name + " " + sr.Author }
 
 // Convert SearchResult to Novel
 func (sr SearchResult) ToNovel() Novel {
-	path := NovelCachePath(sr.Name)
+	id := OnlineBookID(sr.URL)
 	return Novel{
+		ID:        id,
 		Name:      sr.Name,
 		Author:    sr.Author,
-		Path:      path,
+		Path:      NovelCachePath(id),
 		Latest:    sr.Latest,
 		Modified:  time.Now(),
 		Added:     time.Now(),
 		OnlineURL: sr.URL,
 		IsLocal:   false,
+		BookMeta: BookMeta{
+			Category:   strings.TrimSpace(sr.Category),
+			SourceID:   sourceBookID(sr.URL),
+			LastUpdate: parseUpdateTime(sr.UpdateTime),
+		},
 	}
 }
 
 // ------------------ Search function ------------------
 func SearchNovel(query string) ([]list.Item, error) {
-	form := url.Values{}
-	form.Set("searchkey", query)
-
-	req, err := http.NewRequest("POST", searchURL, strings.NewReader(form.Encode()))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	doc, err := fetchSearchPage(query)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
+	if doc.Find(selSearchList).Length() == 0 {
+		return nil, newError(KindParse, "search", searchURL, errors.New("result list not found"))
 	}
 
 	var items []list.Item
-	doc.Find(".txt-list li").Each(func(i int, sel *goquery.Selection) {
+	doc.Find(selSearchRow).Each(func(i int, sel *goquery.Selection) {
 		// Skip header row
 		if sel.Find("b").Length() > 0 {
 			return
 		}
 
-		category := strings.TrimSpace(sel.Find(".s1").Text())
-		titleSel := sel.Find(".s2 a")
+		category := strings.TrimSpace(sel.Find(selSearchCat).Text())
+		titleSel := sel.Find(selSearchTitle)
 		title := strings.TrimSpace(titleSel.Text())
 		href, _ := titleSel.Attr("href")
 		if !strings.HasPrefix(href, "http") {
 			href = BaseURL + href
 		}
-		chapterSel := sel.Find(".s3 a")
+		chapterSel := sel.Find(selSearchLatest)
 		latest := strings.TrimSpace(chapterSel.Text())
-		author := strings.TrimSpace(sel.Find(".s4").Text())
-		updateTime := strings.TrimSpace(sel.Find(".s5").Text())
+		author := strings.TrimSpace(sel.Find(selSearchAuthor).Text())
+		updateTime := strings.TrimSpace(sel.Find(selSearchUpdated).Text())
 
 		sr := SearchResult{
 			Category:   category,
@@ -108,3 +97,36 @@ func SearchNovel(query string) ([]list.Item, error) {
 
 	return items, nil
 }
+
+// fetchSearchPage posts query to the search form and parses the response.
+func fetchSearchPage(query string) (*goquery.Document, error) {
+	if NetworkDisabled() {
+		return nil, offlineError("search", searchURL)
+	}
+
+	form := url.Values{}
+	form.Set("searchkey", query)
+
+	req, err := http.NewRequest("POST", searchURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, newError(KindParse, "search", searchURL, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, classifyRequestError("search", searchURL, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse("search", searchURL, resp); err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, newError(KindParse, "search", searchURL, err)
+	}
+	return doc, nil
+}
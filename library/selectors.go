@@ -0,0 +1,22 @@
+package library
+
+// Selectors used to scrape the source site. They live in one place so the
+// source checker can report exactly which ones stopped matching.
+const (
+	selSearchList    = ".txt-list"
+	selSearchRow     = ".txt-list li"
+	selSearchCat     = ".s1"
+	selSearchTitle   = ".s2 a"
+	selSearchLatest  = ".s3 a"
+	selSearchAuthor  = ".s4"
+	selSearchUpdated = ".s5"
+
+	selInfoLines   = ".top .fix p"
+	selInfoDesc    = ".top .desc"
+	selInfoCover   = ".top .imgbox img"
+	selTOCList     = "ul.section-list.fix"
+	selTOCItem     = "li"
+	selChapterBody = "#content"
+	selChapterPara = "#content p"
+	selChapterHead = "h1.title"
+)
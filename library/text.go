@@ -0,0 +1,200 @@
+package library
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// cjkIndent is the two full-width spaces CJK paragraphs start with.
+const cjkIndent = "　　"
+
+// bookText collects the paragraphs of a structured document (EPUB, FB2,
+// HTML …) as reader lines: CJK paragraphs indented, others followed by a
+// blank line, headings left flush.
+type bookText struct {
+	lines   []string
+	buf     strings.Builder
+	heading int // >0 while inside a heading element
+	quote   int // nesting of epigraphs and citations, indented once each
+	verse   int // >0 inside poems: one line per verse, no blank lines
+}
+
+// write adds inline text to the current paragraph.
+func (t *bookText) write(s string) {
+	t.buf.WriteString(s)
+}
+
+// flush ends the current paragraph.
+func (t *bookText) flush() {
+	text := collapseSpace(t.buf.String())
+	t.buf.Reset()
+	if text == "" {
+		return
+	}
+	cjk := hasCJK(text)
+	quote := strings.Repeat("    ", t.quote)
+	if cjk {
+		quote = strings.Repeat(cjkIndent, t.quote)
+	}
+	switch {
+	case t.heading > 0:
+		t.lines = append(t.lines, text)
+		if !cjk {
+			t.lines = append(t.lines, "")
+		}
+	case t.verse > 0:
+		t.lines = append(t.lines, quote+text)
+	case cjk:
+		t.lines = append(t.lines, quote+cjkIndent+text)
+	default:
+		t.lines = append(t.lines, quote+text, "")
+	}
+}
+
+// blank ends a block, such as a stanza, with an empty line.
+func (t *bookText) blank() {
+	t.flush()
+	if n := len(t.lines); n > 0 && t.lines[n-1] != "" {
+		t.lines = append(t.lines, "")
+	}
+}
+
+// next returns the line the next paragraph will start on.
+func (t *bookText) next() int {
+	return len(t.lines)
+}
+
+// collapseSpace trims a paragraph and folds runs of white space, including
+// the full-width spaces some books pad with, into one space. Line breaks
+// between two CJK characters disappear instead, as CJK text has no spaces.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	var prev rune
+	inSpace, newline := false, false
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '　' {
+			inSpace = true
+			newline = newline || r == '\n' || r == '\r'
+			continue
+		}
+		if inSpace && b.Len() > 0 && !(newline && isCJK(prev) && isCJK(r)) {
+			b.WriteByte(' ')
+		}
+		inSpace, newline = false, false
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+func hasCJK(s string) bool {
+	for _, r := range s {
+		if isCJK(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// newXMLDecoder returns a forgiving decoder for the XML and XHTML found in
+// e-books: HTML entities, unclosed void elements and non-UTF-8 encodings
+// declared in the prolog are all accepted.
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	return d
+}
+
+// htmlBlocks are the elements that end a paragraph.
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "hr": true,
+	"blockquote": true, "section": true, "article": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"dt": true, "dd": true, "table": true, "ul": true, "ol": true,
+}
+
+// htmlSkipped are the elements whose text is never shown.
+var htmlSkipped = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true, "rt": true,
+}
+
+func isHeading(name string) bool {
+	return len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6'
+}
+
+// appendXHTML converts an (X)HTML document to paragraphs on t. anchor is
+// called with every element id and the line it starts on, so links into
+// the document can be resolved.
+func appendXHTML(t *bookText, r io.Reader, anchor func(id string, line int)) error {
+	d := newXMLDecoder(r)
+	skip := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.flush()
+			return err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(el.Name.Local)
+			if htmlSkipped[name] {
+				skip++
+				continue
+			}
+			if htmlBlocks[name] {
+				t.flush()
+			}
+			if isHeading(name) {
+				t.heading++
+			}
+			if anchor != nil {
+				for _, a := range el.Attr {
+					if a.Name.Local == "id" || (name == "a" && a.Name.Local == "name") {
+						anchor(a.Value, t.next())
+					}
+				}
+			}
+		case xml.EndElement:
+			name := strings.ToLower(el.Name.Local)
+			if htmlSkipped[name] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if htmlBlocks[name] {
+				t.flush()
+			}
+			if isHeading(name) && t.heading > 0 {
+				t.heading--
+			}
+		case xml.CharData:
+			if skip == 0 {
+				t.write(string(el))
+			}
+		}
+	}
+	t.flush()
+	return nil
+}
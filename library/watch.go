@@ -0,0 +1,177 @@
+package library
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Watcher follows the library folders and reports the paths that changed
+// in them. It is notified by the system where it can be (inotify on Linux)
+// and polls the folders otherwise. Changes are batched: a file a download
+// is still appending to is reported every watchMaxDelay, not on each write.
+
+const (
+	// watchQuiet is how long the folders must be still before a batch of
+	// changes is reported.
+	watchQuiet = 500 * time.Millisecond
+	// watchMaxDelay is the longest a change waits while others keep coming.
+	watchMaxDelay = 3 * time.Second
+	// pollInterval is how often the folders are looked at when polling.
+	pollInterval = 2 * time.Second
+)
+
+type Watcher struct {
+	Changes <-chan []string // the changed paths, sorted
+	done    chan struct{}
+	once    sync.Once
+}
+
+// WatchLibrary starts watching dirs and everything below them.
+func WatchLibrary(dirs []string) *Watcher {
+	dirs = append([]string(nil), dirs...)
+	raw := make(chan string, 64)
+	changes := make(chan []string)
+	w := &Watcher{Changes: changes, done: make(chan struct{})}
+	if err := notifyChanges(dirs, raw, w.done); err != nil {
+		go pollChanges(dirs, raw, w.done)
+	}
+	go batchChanges(raw, changes, w.done)
+	return w
+}
+
+// Close stops the watcher; Changes is closed soon after.
+func (w *Watcher) Close() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// sendChange hands a changed path to the batcher, unless the watcher has
+// been closed.
+func sendChange(raw chan<- string, path string, done <-chan struct{}) bool {
+	select {
+	case raw <- path:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// batchChanges collects paths from raw until the folders are quiet and
+// sends them on as one batch. Paths keep being collected while the last
+// batch waits to be taken.
+func batchChanges(raw <-chan string, changes chan<- []string, done <-chan struct{}) {
+	defer close(changes)
+	pending := make(map[string]bool)
+	var ready []string
+	var out chan<- []string
+	var quiet, deadline <-chan time.Time
+	flush := func() {
+		for p := range pending {
+			if !containsString(ready, p) {
+				ready = append(ready, p)
+			}
+		}
+		sort.Strings(ready)
+		pending = make(map[string]bool)
+		quiet, deadline = nil, nil
+		out = changes
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case p := <-raw:
+			if len(pending) == 0 {
+				deadline = time.After(watchMaxDelay)
+			}
+			pending[p] = true
+			quiet = time.After(watchQuiet)
+		case <-quiet:
+			flush()
+		case <-deadline:
+			flush()
+		case out <- ready:
+			ready, out = nil, nil
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fileState is what polling compares to tell that a file changed.
+type fileState struct {
+	size    int64
+	modTime int64
+}
+
+// pollChanges looks at the files below dirs every pollInterval and reports
+// the ones that were added, removed or changed.
+func pollChanges(dirs []string, raw chan<- string, done <-chan struct{}) {
+	last := pollSnapshot(dirs)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		now := pollSnapshot(dirs)
+		for p, s := range now {
+			if old, ok := last[p]; !ok || old != s {
+				if !sendChange(raw, p, done) {
+					return
+				}
+			}
+		}
+		for p := range last {
+			if _, ok := now[p]; !ok {
+				if !sendChange(raw, p, done) {
+					return
+				}
+			}
+		}
+		last = now
+	}
+}
+
+func pollSnapshot(dirs []string) map[string]fileState {
+	files := make(map[string]fileState)
+	for _, dir := range dirs {
+		_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil // an unreadable folder just looks empty
+			}
+			if info, err := d.Info(); err == nil {
+				files[p] = fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// BookChanged reports whether a change to one of the paths changes the
+// local book at path: the book's file itself, a chapter file of a folder
+// book, or the archive a book is in.
+func BookChanged(changed []string, path string) bool {
+	path = filepath.Clean(path)
+	sep := string(filepath.Separator)
+	for _, c := range changed {
+		c = filepath.Clean(c)
+		if c == path || strings.HasPrefix(c, path+sep) || strings.HasPrefix(path, c+sep) {
+			return true
+		}
+	}
+	return false
+}
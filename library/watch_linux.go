@@ -0,0 +1,108 @@
+//go:build linux
+
+package library
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const notifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotify watches every folder below the library folders; inotify itself
+// does not look into subfolders.
+type inotify struct {
+	fd      int
+	watches map[int]string // folder by watch descriptor
+}
+
+// notifyChanges reports changes below dirs as inotify sees them. It fails
+// when inotify cannot watch them all, e.g. past fs.inotify.max_user_watches,
+// and falls back to polling when inotify breaks later.
+func notifyChanges(dirs []string, raw chan<- string, done <-chan struct{}) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	n := &inotify{fd: fd, watches: make(map[int]string)}
+	for _, dir := range dirs {
+		if err := n.addTree(dir); err != nil {
+			syscall.Close(fd)
+			return err
+		}
+	}
+	// non-blocking, so reads go through the runtime poller and Close ends them
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-done
+		f.Close()
+	}()
+	go n.read(f, dirs, raw, done)
+	return nil
+}
+
+// addTree watches root and the folders below it. Folders that cannot be
+// read are left out; running out of watches is an error.
+func (n *inotify) addTree(root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(n.fd, p, notifyMask)
+		if err != nil {
+			return err
+		}
+		n.watches[wd] = p
+		return nil
+	})
+}
+
+func (n *inotify) read(f *os.File, dirs []string, raw chan<- string, done <-chan struct{}) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		size, err := f.Read(buf)
+		if err != nil {
+			select {
+			case <-done:
+			default:
+				f.Close()
+				go pollChanges(dirs, raw, done)
+			}
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= size; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			off = nameStart + int(ev.Len)
+			name := strings.TrimRight(string(buf[nameStart:off]), "\x00")
+
+			var changed []string
+			switch dir, ok := n.watches[int(ev.Wd)]; {
+			case ev.Mask&syscall.IN_Q_OVERFLOW != 0:
+				changed = dirs // events were lost; anything may have changed
+			case ev.Mask&syscall.IN_IGNORED != 0:
+				delete(n.watches, int(ev.Wd))
+			case ok:
+				p := dir
+				if name != "" {
+					p = filepath.Join(dir, name)
+				}
+				if ev.Mask&syscall.IN_ISDIR != 0 && ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					_ = n.addTree(p) // a folder without a watch is only missed, not wrong
+				}
+				changed = []string{p}
+			}
+			for _, p := range changed {
+				if !sendChange(raw, p, done) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package library
+
+import "errors"
+
+// notifyChanges has no system notification to use here; the library
+// folders are polled instead.
+func notifyChanges(dirs []string, raw chan<- string, done <-chan struct{}) error {
+	return errors.ErrUnsupported
+}
@@ -3,9 +3,6 @@ package ui
 import (
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -22,6 +19,7 @@ const (
 	StateLibrary AppState = iota
 	StateReader
 	StateTOC
+	StateRules
 )
 
 type AppModel struct {
@@ -29,6 +27,7 @@ type AppModel struct {
 	libraryUI LibraryModel
 	readerUI  ReaderModel
 	tocUI     TOCModel
+	rulesUI   RulesModel
 }
 
 func (m *LibraryModel) ActiveList() *list.Model {
@@ -49,26 +48,9 @@ func (m AppModel) handleStateLibrary(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		}
 
-		// ---------------- Discovery tab (index 2) ----------------
+		// Discovery tab (index 2) is handled by LibraryModel, which scrapes
+		// the selected result and answers with novelOpenMsg or errMsg.
 		if activeTab == 2 {
-			if len(m.libraryUI.ActiveList().Items()) == 0 {
-				return m, cmd
-			}
-
-			if sr, ok := selectedItem.(library.SearchResult); ok {
-				if strings.HasPrefix(sr.URL, "/") {
-					sr.URL = library.BaseURL + sr.URL
-				}
-				m.libraryUI.searchLoading = true
-
-				return m, func() tea.Msg {
-					novel, err := library.ScrapeAndSaveChapters(sr)
-					if err != nil {
-						return errMsg{err}
-					}
-					return novelOpenMsg{Novel: novel}
-				}
-			}
 			return m, cmd
 		}
 
@@ -82,18 +64,17 @@ func (m AppModel) handleStateLibrary(msg tea.Msg) (tea.Model, tea.Cmd) {
 			var reader ReaderModel
 			if novel.IsLocal {
 				// Local book: one big .txt file
-				reader = NewReaderModel(novel.Path, novel.Name, source)
+				reader = NewReaderModel(novel.Path, novel.ID, novel.Name, source)
 			} else {
-				// Online book: multiple chapter files
-				chapters := loadAllChapters(novel.Path)
-				reader = NewReaderModelFromFiles(chapters, novel.Name, source)
+				// Online book: packed chapter store
+				reader = NewReaderModelFromCache(novel.ID, novel.Name)
 			}
 
 			m.readerUI = reader
 			m.state = StateReader
 			openCmd := m.syncWindowSizeCmd()
 			if source == "online" {
-				cmd = tea.Batch(cmd, openCmd, prefetchAroundCmd(novel.Name, reader.ActualChapterIndex()))
+				cmd = tea.Batch(cmd, openCmd, prefetchAroundCmd(novel.ID, reader.ActualChapterIndex()))
 			} else {
 				cmd = tea.Batch(cmd, openCmd)
 			}
@@ -112,15 +93,18 @@ func (m AppModel) handleStateLibrary(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.libraryUI.clearSearchStatus()
 		}
 
-		// Always load all chapters (local or scraped)
-		chapters := loadAllChapters(msg.Novel.Path)
-		reader := NewReaderModelFromFiles(chapters, msg.Novel.Name, source)
+		var reader ReaderModel
+		if msg.Novel.IsLocal {
+			reader = NewReaderModel(msg.Novel.Path, msg.Novel.ID, msg.Novel.Name, source)
+		} else {
+			reader = NewReaderModelFromCache(msg.Novel.ID, msg.Novel.Name)
+		}
 
 		m.readerUI = reader
 		m.state = StateReader
 		openCmd := m.syncWindowSizeCmd()
 		if source == "online" {
-			cmd = tea.Batch(cmd, openCmd, prefetchAroundCmd(msg.Novel.Name, reader.ActualChapterIndex()))
+			cmd = tea.Batch(cmd, openCmd, prefetchAroundCmd(msg.Novel.ID, reader.ActualChapterIndex()))
 		} else {
 			cmd = tea.Batch(cmd, openCmd)
 		}
@@ -135,23 +119,29 @@ type novelOpenMsg struct {
 }
 
 type chapterChangedMsg struct {
-	NovelName string
-	Source    string
-	Chapter   int
+	BookID  string
+	Source  string
+	Chapter int
 }
 
 type chapterCachedMsg struct {
-	NovelName  string
+	BookID     string
 	Chapter    int
 	Downloaded bool
 }
 
 type chapterReadyMsg struct {
-	NovelName string
-	Chapter   int
+	BookID  string
+	Chapter int
 }
 
 func (m AppModel) handleStateReader(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.readerUI.Err != nil {
+		if keyMsg, ok := msg.(tea.KeyMsg); ok {
+			return m.handleReaderRecovery(keyMsg)
+		}
+	}
+
 	var cmd tea.Cmd
 	m.readerUI, cmd = m.readerUI.Update(msg)
 
@@ -159,6 +149,7 @@ func (m AppModel) handleStateReader(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch keyMsg.String() {
 
 		case "esc":
+			m.readerUI.FlushProgress()
 			m.state = StateLibrary
 
 			if m.libraryUI.activeTab == 2 {
@@ -171,21 +162,17 @@ func (m AppModel) handleStateReader(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				for i, item := range activeList.Items() {
 					if v, ok := item.(library.Novel); ok {
-						source := "online"
-						if v.IsLocal {
-							source = "local"
-						}
-						if p, ok := utils.GetProgress(progressMap, v.Name, source); ok {
+						if p, ok := utils.GetProgress(progressMap, v.ID); ok {
 							if !p.LastRead.IsZero() {
 								v.Modified = p.LastRead
 							}
 							v.Current = p.LastChapter
 							if v.IsLocal {
-								if latest, err := library.LatestChapter(v.Path); err == nil && latest != "" {
+								if latest, err := library.LatestChapter(v.Path, v.ID); err == nil && latest != "" {
 									v.Latest = latest
 								}
 							} else {
-								if chapters, err := library.LoadChapterList(v.Name); err == nil && len(chapters) > 0 {
+								if chapters, err := library.LoadChapterList(v.ID); err == nil && len(chapters) > 0 {
 									latest := strings.TrimSpace(chapters[len(chapters)-1].Title)
 									if latest == "" {
 										latest = lang.ChapterTitle(chapters[len(chapters)-1].Index)
@@ -215,24 +202,42 @@ func (m AppModel) handleStateReader(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "tab", "t": // open TOC
 			m.tocUI = NewTOCModel(
 				m.readerUI.AllChapters,
+				m.readerUI.Volumes,
 				m.readerUI.Width,
 				m.readerUI.Height,
 				m.readerUI.ActualChapterIndex(),
 			)
+			if m.readerUI.Source == "online" {
+				m.tocUI = m.tocUI.WithRefill()
+			}
 			m.state = StateTOC
+
+		case "e": // encoding of a local book
+			if m.readerUI.Source == "local" && m.readerUI.Path != "" {
+				cmd = tea.Batch(cmd, m.nextEncoding())
+			}
+
+		case "r": // chapter rule of a local book
+			if m.readerUI.Source == "local" && m.readerUI.Path != "" {
+				m.rulesUI = NewRulesModel(m.readerUI.BookID, m.readerUI.Content, m.readerUI.Width, m.readerUI.Height)
+				m.state = StateRules
+			}
 		}
 	}
 
 	switch tm := msg.(type) {
+	case chapterErrMsg:
+		if tm.BookID == m.readerUI.BookID && m.readerUI.Loading {
+			m.readerUI = m.readerUI.WithError(tm.Err, tm.Chapter)
+		}
 	case chapterChangedMsg:
-		if tm.Source == "online" && tm.NovelName == m.readerUI.Name {
-			cmd = tea.Batch(cmd, prefetchAroundCmd(tm.NovelName, tm.Chapter))
+		if tm.Source == "online" && tm.BookID == m.readerUI.BookID {
+			cmd = tea.Batch(cmd, prefetchAroundCmd(tm.BookID, tm.Chapter))
 		}
 	case chapterCachedMsg:
-		if tm.NovelName == m.readerUI.Name && tm.Downloaded && m.readerUI.CacheDir != "" {
+		if tm.BookID == m.readerUI.BookID && tm.Downloaded && m.readerUI.CacheDir != "" {
 			prev := m.readerUI
-			chapters := loadAllChapters(prev.CacheDir)
-			reader := NewReaderModelFromFiles(chapters, prev.Name, prev.Source)
+			reader := NewReaderModelFromCache(prev.BookID, prev.Name)
 			reader.Width = prev.Width
 			reader.Height = prev.Height
 			reader.Style = prev.Style
@@ -245,17 +250,16 @@ func (m AppModel) handleStateReader(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.readerUI = reader
 		}
 	case chapterReadyMsg:
-		if tm.NovelName == m.readerUI.Name && m.readerUI.CacheDir != "" {
+		if tm.BookID == m.readerUI.BookID && m.readerUI.CacheDir != "" {
 			prev := m.readerUI
-			chapters := loadAllChapters(prev.CacheDir)
-			reader := NewReaderModelFromFiles(chapters, prev.Name, prev.Source)
+			reader := NewReaderModelFromCache(prev.BookID, prev.Name)
 			reader.Width = prev.Width
 			reader.Height = prev.Height
 			reader.Style = prev.Style
 			reader.SetCurrentByActual(tm.Chapter)
 			reader = reader.WithLoading(false, "")
 			m.readerUI = reader
-			cmd = tea.Batch(cmd, prefetchAroundCmd(prev.Name, tm.Chapter))
+			cmd = tea.Batch(cmd, prefetchAroundCmd(prev.BookID, tm.Chapter))
 		}
 	}
 
@@ -268,28 +272,60 @@ func (m AppModel) handleStateTOC(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		m.tocUI.list.SetSize(msg.Width-4, msg.Height-2)
+		m.tocUI.SetSize(msg.Width-4, msg.Height-2)
 	case TOCSelectMsg:
 		actual := int(msg)
 		m.state = StateReader
+		if m.readerUI.Source == "online" && m.readerUI.ChapterUnavailable(actual) {
+			m.readerUI = m.readerUI.WithError(library.OfflineError("chapter"), actual)
+			return m, tea.Batch(cmd, m.syncWindowSizeCmd())
+		}
 		if m.readerUI.Source == "online" {
 			title := m.readerUI.TitleForActual(actual)
 			loadingText := lang.ReaderLoadingTitle(title)
 			m.readerUI = m.readerUI.WithLoading(true, loadingText)
-			return m, tea.Batch(cmd, m.syncWindowSizeCmd(), openChapterCmd(m.readerUI.Name, actual))
+			return m, tea.Batch(cmd, m.syncWindowSizeCmd(), openChapterCmd(m.readerUI.BookID, actual))
 		}
 		m.readerUI.JumpToChapter(actual)
 		cmd = tea.Batch(cmd, m.syncWindowSizeCmd())
 	case TOCCancelMsg:
 		m.state = StateReader
+	case TOCRefillMsg:
+		m.tocUI = m.tocUI.WithNotice(lang.Active().TOC.Refilling)
+		return m, tea.Batch(cmd, refillCmd(m.readerUI.BookID))
+	case chapterListRefilledMsg:
+		if msg.BookID != m.readerUI.BookID {
+			break
+		}
+		notice := fmt.Sprintf(lang.Active().TOC.RefilledTemplate, msg.Added)
+		switch {
+		case msg.Err != nil:
+			notice = errorText(msg.Err)
+		case msg.Added == 0:
+			notice = lang.Active().TOC.RefillNone
+		default:
+			// the position was moved along with its chapter
+			prev := m.readerUI
+			reader := NewReaderModelFromCache(prev.BookID, prev.Name)
+			reader.Width = prev.Width
+			reader.Height = prev.Height
+			reader.Style = prev.Style
+			m.readerUI = reader
+			m.tocUI = NewTOCModel(reader.AllChapters, reader.Volumes, m.tocUI.width, m.tocUI.height, reader.ActualChapterIndex()).WithRefill()
+		}
+		m.tocUI = m.tocUI.WithNotice(notice)
 	}
 
 	switch tm := msg.(type) {
+	case chapterErrMsg:
+		if tm.BookID == m.readerUI.BookID && m.readerUI.Loading {
+			m.readerUI = m.readerUI.WithError(tm.Err, tm.Chapter)
+			m.state = StateReader
+		}
 	case chapterCachedMsg:
-		if tm.NovelName == m.readerUI.Name && tm.Downloaded && m.readerUI.CacheDir != "" {
+		if tm.BookID == m.readerUI.BookID && tm.Downloaded && m.readerUI.CacheDir != "" {
 			prev := m.readerUI
-			chapters := loadAllChapters(prev.CacheDir)
-			reader := NewReaderModelFromFiles(chapters, prev.Name, prev.Source)
+			reader := NewReaderModelFromCache(prev.BookID, prev.Name)
 			reader.Width = prev.Width
 			reader.Height = prev.Height
 			reader.Style = prev.Style
@@ -302,14 +338,13 @@ func (m AppModel) handleStateTOC(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.readerUI = reader
 		}
 	case chapterChangedMsg:
-		if tm.Source == "online" && tm.NovelName == m.readerUI.Name {
-			cmd = tea.Batch(cmd, prefetchAroundCmd(tm.NovelName, tm.Chapter))
+		if tm.Source == "online" && tm.BookID == m.readerUI.BookID {
+			cmd = tea.Batch(cmd, prefetchAroundCmd(tm.BookID, tm.Chapter))
 		}
 	case chapterReadyMsg:
-		if tm.NovelName == m.readerUI.Name && m.readerUI.CacheDir != "" {
+		if tm.BookID == m.readerUI.BookID && m.readerUI.CacheDir != "" {
 			prev := m.readerUI
-			chapters := loadAllChapters(prev.CacheDir)
-			reader := NewReaderModelFromFiles(chapters, prev.Name, prev.Source)
+			reader := NewReaderModelFromCache(prev.BookID, prev.Name)
 			reader.Width = prev.Width
 			reader.Height = prev.Height
 			reader.Style = prev.Style
@@ -317,13 +352,81 @@ func (m AppModel) handleStateTOC(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.readerUI = reader
 			m.state = StateReader
 			m.readerUI = m.readerUI.WithLoading(false, "")
-			cmd = tea.Batch(cmd, m.syncWindowSizeCmd(), prefetchAroundCmd(prev.Name, tm.Chapter))
+			cmd = tea.Batch(cmd, m.syncWindowSizeCmd(), prefetchAroundCmd(prev.BookID, tm.Chapter))
 		}
 	}
 
 	return m, cmd
 }
 
+func (m AppModel) handleStateRules(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.rulesUI, cmd = m.rulesUI.Update(msg)
+
+	switch msg.(type) {
+	case rulesSavedMsg:
+		// reopen the book with the new TOC, at the chapter holding the
+		// line that was on screen
+		prev := m.readerUI
+		line := 0
+		if ch := prev.currentChapter; ch >= 0 && ch < len(prev.TOC) {
+			line = prev.TOC[ch].Line
+		}
+		reader := NewReaderModel(prev.Path, prev.BookID, prev.Name, prev.Source)
+		reader.Width = prev.Width
+		reader.Height = prev.Height
+		reader.Style = prev.Style
+		reader.currentChapter, reader.Page = 0, 0
+		for i, ch := range reader.TOC {
+			if ch.Line <= line {
+				reader.currentChapter = i
+			}
+		}
+		m.readerUI = reader
+		m.state = StateReader
+		cmd = tea.Batch(cmd, m.syncWindowSizeCmd())
+	case rulesCancelMsg:
+		m.state = StateReader
+	}
+	return m, cmd
+}
+
+// handleReaderRecovery handles keys while the reader shows a failed chapter.
+func (m AppModel) handleReaderRecovery(keyMsg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := keyMsg.String()
+	if key == "esc" {
+		m.readerUI = m.readerUI.WithError(nil, 0)
+		if len(m.readerUI.Content) > 0 {
+			return m, nil
+		}
+		m.state = StateLibrary
+		return m, m.syncWindowSizeCmd()
+	}
+
+	action, ok := actionForKey(key, m.readerUI.RecoveryActions())
+	if !ok {
+		return m, nil
+	}
+	switch action {
+	case library.ActionRetry:
+		chapter := m.readerUI.ErrChapter
+		m.readerUI = m.readerUI.WithError(nil, 0)
+		m.readerUI = m.readerUI.WithLoading(true, lang.ReaderLoadingTitle(m.readerUI.TitleForActual(chapter)))
+		return m, openChapterCmd(m.readerUI.BookID, chapter)
+	case library.ActionOpenCached:
+		m.readerUI = m.readerUI.WithError(nil, 0).WithLoading(false, "")
+		return m, m.syncWindowSizeCmd()
+	case library.ActionSwitchSource:
+		title := m.readerUI.Name
+		m.readerUI = m.readerUI.WithError(nil, 0).WithLoading(false, "")
+		m.state = StateLibrary
+		return m, func() tea.Msg { return switchSourceMsg{Title: title} }
+	case library.ActionReport:
+		return m, reportErrorCmd(m.readerUI.Err)
+	}
+	return m, nil
+}
+
 func (m AppModel) syncWindowSizeCmd() tea.Cmd {
 	return func() tea.Msg {
 		return tea.WindowSizeMsg{
@@ -333,10 +436,11 @@ func (m AppModel) syncWindowSizeCmd() tea.Cmd {
 	}
 }
 
-func (m AppModel) Init() tea.Cmd { return nil }
+func (m AppModel) Init() tea.Cmd { return m.libraryUI.Init() }
 
 func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.String() == "ctrl+c" {
+		m.readerUI.FlushProgress()
 		return m, tea.Quit
 	}
 
@@ -344,6 +448,40 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.tocUI.ApplyLanguage()
 		return m, nil
 	}
+	if _, ok := msg.(offlineChangedMsg); ok {
+		m.readerUI.RefreshAvailability()
+		return m, nil
+	}
+
+	switch tm := msg.(type) {
+	case progressSaveMsg:
+		// also after the reader was left or rebuilt for the same book
+		if tm.BookID == m.readerUI.BookID {
+			m.readerUI.FlushProgress()
+		}
+		return m, nil
+	case errorReportedMsg:
+		if m.state == StateReader {
+			m.readerUI.Notice = errorReportText(tm)
+			return m, nil
+		}
+	case libraryScanMsg:
+		// scans go on while a book is open
+		var cmd tea.Cmd
+		m.libraryUI, cmd = m.libraryUI.Update(tm)
+		return m, cmd
+	case libraryChangedMsg:
+		var cmd tea.Cmd
+		m.libraryUI, cmd = m.libraryUI.Update(tm)
+		if m.state != StateLibrary && m.readerUI.Source == "local" && library.BookChanged(tm.Paths, m.readerUI.Path) {
+			m.reloadLocalReader()
+		}
+		return m, cmd
+	case switchSourceMsg:
+		m.state = StateLibrary
+		m.libraryUI.activeTab = 2
+		return m, tea.Batch(m.libraryUI.startSearch(tm.Title), m.syncWindowSizeCmd())
+	}
 
 	switch m.state {
 	case StateLibrary:
@@ -352,11 +490,96 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleStateReader(msg)
 	case StateTOC:
 		return m.handleStateTOC(msg)
+	case StateRules:
+		return m.handleStateRules(msg)
 	default:
 		return m, nil
 	}
 }
 
+// reloadLocalReader reads the open local book again after its file changed,
+// e.g. while a download is still appending to it, keeping the place.
+func (m *AppModel) reloadLocalReader() {
+	prev := m.readerUI
+	if !library.LocalBookExists(prev.Path) {
+		return // keep what was read before it went away
+	}
+	id := prev.BookID
+	if current, err := library.LocalBookID(prev.Path); err == nil {
+		id = current // a short file that grew past the fingerprint gets a new one
+	}
+	reader := NewReaderModel(prev.Path, id, prev.Name, prev.Source)
+	reader.Width = prev.Width
+	reader.Height = prev.Height
+	reader.Style = prev.Style
+	reader.SetCurrentByActual(prev.ActualChapterIndex())
+	reader.Page = prev.Page
+	m.readerUI = reader
+	if m.state == StateTOC {
+		selected := m.tocUI.SelectedActual()
+		if selected < 0 {
+			selected = reader.ActualChapterIndex()
+		}
+		m.tocUI = NewTOCModel(reader.AllChapters, reader.Volumes, m.tocUI.width, m.tocUI.height, selected)
+	}
+}
+
+// nextEncoding reads the open local book in the next likely encoding and
+// keeps it for the file. After the least likely one it goes back to
+// detection.
+func (m *AppModel) nextEncoding() tea.Cmd {
+	texts := lang.Active().Reader
+	path := m.readerUI.Path
+	guesses, err := library.EncodingGuesses(path)
+	if err != nil {
+		m.readerUI.Notice = errorText(err)
+		return nil
+	}
+	if len(guesses) == 0 {
+		m.readerUI.Notice = texts.EncodingNone
+		return nil
+	}
+	detected := guesses[0].Name
+	if guesses[0].Confidence == 0 {
+		detected = ""
+	}
+	order := []string{""}
+	for _, g := range guesses {
+		if g.Name != detected {
+			order = append(order, g.Name)
+		}
+	}
+	current := utils.FileEncoding(path)
+	next := ""
+	for i, name := range order {
+		if name == current {
+			next = order[(i+1)%len(order)]
+			break
+		}
+	}
+	if err := utils.SetFileEncoding(path, next); err != nil {
+		m.readerUI.Notice = errorText(err)
+		return nil
+	}
+	m.reloadLocalReader()
+
+	confidence := func(name string) int {
+		for _, g := range guesses {
+			if g.Name == name {
+				return int(g.Confidence*100 + 0.5)
+			}
+		}
+		return 0
+	}
+	if next == "" {
+		m.readerUI.Notice = fmt.Sprintf(texts.EncodingAutoTemplate, guesses[0].Name, confidence(guesses[0].Name))
+	} else {
+		m.readerUI.Notice = fmt.Sprintf(texts.EncodingTemplate, next, confidence(next))
+	}
+	// the library shows the book as read in its new encoding
+	return m.libraryUI.startLibraryScan()
+}
+
 func (m AppModel) View() string {
 	switch m.state {
 	case StateLibrary:
@@ -365,6 +588,8 @@ func (m AppModel) View() string {
 		return m.readerUI.View()
 	case StateTOC:
 		return m.tocUI.View()
+	case StateRules:
+		return m.rulesUI.View()
 	default:
 		return lang.Active().Common.UnknownState
 	}
@@ -389,33 +614,50 @@ func RunApp() {
 	}
 }
 
-func openChapterCmd(novelName string, actualIndex int) tea.Cmd {
+func openChapterCmd(bookID string, actualIndex int) tea.Cmd {
 	return func() tea.Msg {
-		_, _, _, err := library.EnsureChapterCached(novelName, actualIndex+1)
+		_, _, err := library.EnsureChapterCached(bookID, actualIndex+1)
 		if err != nil {
-			return errMsg{err}
+			return chapterErrMsg{BookID: bookID, Chapter: actualIndex, Err: err}
 		}
-		return chapterReadyMsg{NovelName: novelName, Chapter: actualIndex}
+		return chapterReadyMsg{BookID: bookID, Chapter: actualIndex}
+	}
+}
+
+type chapterListRefilledMsg struct {
+	BookID string
+	Added  int
+	Err    error
+}
+
+func refillCmd(bookID string) tea.Cmd {
+	return func() tea.Msg {
+		added, err := library.RefillChapterList(bookID)
+		return chapterListRefilledMsg{BookID: bookID, Added: added, Err: err}
 	}
 }
 
-func prefetchAroundCmd(novelName string, zeroIndex int) tea.Cmd {
+func prefetchAroundCmd(bookID string, zeroIndex int) tea.Cmd {
 	return func() tea.Msg {
-		chapters, err := library.LoadChapterList(novelName)
+		if library.NetworkDisabled() {
+			// nothing to fetch; uncached chapters are marked unavailable
+			return chapterCachedMsg{BookID: bookID, Chapter: zeroIndex}
+		}
+		chapters, err := library.LoadChapterList(bookID)
 		if err != nil {
 			// Ensure the current chapter is cached which will also create the chapter list
-			if _, _, _, ensureErr := library.EnsureChapterCached(novelName, zeroIndex+1); ensureErr != nil {
-				return errMsg{ensureErr}
+			if _, _, ensureErr := library.EnsureChapterCached(bookID, zeroIndex+1); ensureErr != nil {
+				return chapterErrMsg{BookID: bookID, Chapter: zeroIndex, Err: ensureErr}
 			}
-			chapters, err = library.LoadChapterList(novelName)
+			chapters, err = library.LoadChapterList(bookID)
 			if err != nil {
-				return errMsg{err}
+				return chapterErrMsg{BookID: bookID, Chapter: zeroIndex, Err: err}
 			}
 		}
 
 		total := len(chapters)
 		if total == 0 {
-			return chapterCachedMsg{NovelName: novelName, Chapter: zeroIndex}
+			return chapterCachedMsg{BookID: bookID, Chapter: zeroIndex}
 		}
 
 		current := zeroIndex + 1
@@ -429,46 +671,24 @@ func prefetchAroundCmd(novelName string, zeroIndex int) tea.Cmd {
 
 		downloaded := false
 		for _, idx := range targets {
-			_, _, didDownload, err := library.EnsureChapterCached(novelName, idx)
+			_, didDownload, err := library.EnsureChapterCached(bookID, idx)
 			if err != nil {
-				return errMsg{err}
+				return chapterErrMsg{BookID: bookID, Chapter: idx - 1, Err: err}
 			}
 			if didDownload {
 				downloaded = true
 			}
 		}
+		if downloaded {
+			// the keep window around saved progress protects what the
+			// reader is looking at
+			_, _ = library.EnforceQuota()
+		}
 
 		return chapterCachedMsg{
-			NovelName:  novelName,
+			BookID:     bookID,
 			Chapter:    zeroIndex,
 			Downloaded: downloaded,
 		}
 	}
 }
-
-func loadAllChapters(novelDir string) []string {
-	files, _ := os.ReadDir(novelDir)
-	var chapters []string
-	for _, f := range files {
-		if strings.HasSuffix(f.Name(), ".txt") {
-			chapters = append(chapters, filepath.Join(novelDir, f.Name()))
-		}
-	}
-
-	// Numeric sort: 1.txt < 2.txt < 10.txt
-	sort.Slice(chapters, func(i, j int) bool {
-		iname := strings.TrimSuffix(filepath.Base(chapters[i]), ".txt")
-		jname := strings.TrimSuffix(filepath.Base(chapters[j]), ".txt")
-
-		inum, err1 := strconv.Atoi(iname)
-		jnum, err2 := strconv.Atoi(jname)
-
-		if err1 == nil && err2 == nil {
-			return inum < jnum
-		}
-		// fallback: string compare
-		return chapters[i] < chapters[j]
-	})
-
-	return chapters
-}
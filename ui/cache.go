@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+
+	"novel_reader/lang"
+	"novel_reader/library"
+	"novel_reader/utils"
+)
+
+// cacheUsageItem is one row of the Settings cache screen.
+type cacheUsageItem struct {
+	library.NovelCacheUsage
+}
+
+func (i cacheUsageItem) Title() string {
+	return i.NovelCacheUsage.Title
+}
+
+func (i cacheUsageItem) Description() string {
+	texts := lang.Active()
+	desc := fmt.Sprintf(texts.Settings.CacheItemTemplate, i.Chapters, formatBytes(i.Bytes))
+	if i.Pinned {
+		desc += " · " + texts.Settings.CachePinned
+	}
+	return desc
+}
+
+func (i cacheUsageItem) FilterValue() string {
+	return i.NovelCacheUsage.Title
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// cacheUsageValue renders "used / quota" for the Settings entry.
+func cacheUsageValue() string {
+	_, total, err := library.CacheUsage()
+	if err != nil {
+		return ""
+	}
+	texts := lang.Active()
+	quota := texts.Settings.CacheUnlimited
+	if q := utils.CacheQuotaBytes(); q > 0 {
+		quota = formatBytes(q)
+	}
+	return fmt.Sprintf(texts.Settings.CacheUsageTemplate, formatBytes(total), quota)
+}
+
+func (m *LibraryModel) refreshCacheUsageList(preferID string) {
+	currentID := preferID
+	if currentID == "" {
+		if selected, ok := m.removeList.SelectedItem().(cacheUsageItem); ok {
+			currentID = selected.ID
+		}
+	}
+
+	usage, _, err := library.CacheUsage()
+	if err != nil {
+		m.setCacheFailed(err)
+	}
+	items := make([]list.Item, len(usage))
+	for i := range usage {
+		items[i] = cacheUsageItem{usage[i]}
+	}
+	m.removeList.SetItems(items)
+	m.updateSettingItem(SettingCacheUsage, func(s *SettingItem) {
+		s.Value = cacheUsageValue()
+	})
+	if len(items) == 0 {
+		return
+	}
+
+	target := 0
+	for i := range usage {
+		if usage[i].ID == currentID {
+			target = i
+			break
+		}
+	}
+	m.removeList.Select(target)
+}
+
+// handleCacheKey runs the trim/clear/pin actions on the selected novel of
+// the cache screen. It reports whether key was one of them.
+func (m *LibraryModel) handleCacheKey(key string) bool {
+	item, ok := m.removeList.SelectedItem().(cacheUsageItem)
+	if !ok {
+		return false
+	}
+	switch key {
+	case "t":
+		freed, err := library.TrimNovelCache(item.ID)
+		m.finishCacheAction(item.ID, freed, err)
+	case "c":
+		m.pendingCacheID = item.ID
+		m.pendingCacheTitle = item.NovelCacheUsage.Title
+		m.settingsStatusKind = settingsStatusNone
+		m.settingsStatusErr = nil
+		m.settingsState = settingsStateConfirm
+		m.updateConfirmPrompt()
+	case "p":
+		if err := utils.SetPinned(item.ID, !item.Pinned); err != nil {
+			m.setCacheFailed(err)
+		} else {
+			m.settingsStatusKind = settingsStatusNone
+			m.settingsStatusErr = nil
+		}
+		m.refreshCacheUsageList(item.ID)
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *LibraryModel) clearPendingCache() {
+	freed, err := library.ClearNovelCache(m.pendingCacheID)
+	m.finishCacheAction(m.pendingCacheID, freed, err)
+	m.pendingCacheID = ""
+	m.pendingCacheTitle = ""
+}
+
+func (m *LibraryModel) finishCacheAction(id string, freed int64, err error) {
+	if err != nil {
+		m.setCacheFailed(err)
+	} else {
+		m.setReclaimed(freed)
+	}
+	if refreshed, loadErr := library.LoadAllCachedNovels(); loadErr == nil {
+		sortOnlineNovels(refreshed)
+		m.onlineNovels = refreshed
+	}
+	m.refreshCacheUsageList(id)
+}
+
+func (m *LibraryModel) setReclaimed(freed int64) {
+	m.settingsStatusKind = settingsStatusReclaimed
+	m.settingsStatusBytes = freed
+	m.settingsStatusErr = nil
+}
+
+func (m *LibraryModel) setCacheFailed(err error) {
+	m.settingsStatusKind = settingsStatusCacheFailed
+	m.settingsStatusErr = err
+}
+
+func reclaimedText(freed int64) string {
+	return fmt.Sprintf(lang.Active().Settings.CacheReclaimedTemplate, formatBytes(freed))
+}
+
+func cacheHints() string {
+	return strings.TrimSpace(lang.Active().Settings.CacheHints)
+}
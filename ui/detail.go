@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+
+	"novel_reader/lang"
+	"novel_reader/library"
+)
+
+// detailSynopsisLines caps the synopsis so the dialog fits small terminals.
+const detailSynopsisLines = 8
+
+// openDetail shows the metadata of the selected novel, if any.
+func (m *LibraryModel) openDetail() bool {
+	if m.activeTab < 0 || m.activeTab >= len(m.lists) {
+		return false
+	}
+	novel, ok := m.lists[m.activeTab].SelectedItem().(library.Novel)
+	if !ok {
+		return false
+	}
+	m.detailNovel = &novel
+	return true
+}
+
+// detailView renders the metadata dialog of n.
+func (m LibraryModel) detailView(n library.Novel) string {
+	texts := lang.Active().Novel
+	dlgW, contentW := m.confirmDialogWidths()
+
+	var rows []string
+	field := func(label, value string) {
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		rows = append(rows, wordwrap.String(NormalDescStyle.Render(label+": ")+value, contentW))
+	}
+	field(texts.DetailAuthor, n.Author)
+	field(texts.DetailCategory, n.Category)
+	field(texts.DetailStatus, library.StatusLabel(n.Status))
+	field(texts.DetailTags, strings.Join(n.Tags, ", "))
+	if n.WordCount > 0 {
+		field(texts.DetailWords, fmt.Sprint(n.WordCount))
+	}
+	field(texts.DetailFirstUpdate, formatMetaTime(n.FirstUpdate))
+	field(texts.DetailLastUpdate, formatMetaTime(n.LastUpdate))
+	if n.IsLocal {
+		field(texts.DetailSource, n.Path)
+	} else {
+		field(texts.DetailSource, n.OnlineURL)
+	}
+
+	synopsis := strings.TrimSpace(n.Synopsis)
+	if synopsis == "" {
+		synopsis = texts.DetailNoSynopsis
+	}
+	lines := strings.Split(wordwrap.String(synopsis, contentW), "\n")
+	if len(lines) > detailSynopsisLines {
+		lines = append(lines[:detailSynopsisLines-1], "…")
+	}
+
+	hint := texts.DetailHint
+	if n.IsLocal {
+		hint += " · " + texts.DetailEdit
+	}
+	parts := []string{
+		SelectedTitleStyle.Render(n.Name),
+		strings.Join(rows, "\n"),
+		strings.Join(lines, "\n"),
+	}
+	if m.detailNotice != "" {
+		parts = append(parts, wordwrap.String(m.detailNotice, contentW))
+	}
+	parts = append(parts, StatusMutedStyle.Render(hint))
+	body := strings.Join(parts, "\n\n")
+	return gloss.Place(m.width, m.height, gloss.Center, gloss.Center, ConfirmBoxStyle.Width(dlgW).Render(body))
+}
+
+// editLocalMeta writes what is known about the local book n to its sidecar,
+// unless it has one, and says where to correct it. The library watcher
+// picks up the edits.
+func editLocalMeta(n library.Novel) string {
+	if !n.IsLocal {
+		return ""
+	}
+	meta := library.LocalMeta{Title: n.Name, Author: n.Author, BookMeta: n.BookMeta}
+	path, err := library.EnsureLocalMeta(n.Path, meta)
+	if err != nil {
+		return errorText(err)
+	}
+	return fmt.Sprintf(lang.Active().Novel.DetailSidecar, path)
+}
+
+// formatMetaTime shortens an RFC 3339 time to the date and minute.
+func formatMetaTime(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"novel_reader/lang"
+	"novel_reader/library"
+)
+
+// doctorIssueItem is one row of the Settings cache check screen.
+type doctorIssueItem struct {
+	library.Issue
+}
+
+func (i doctorIssueItem) Title() string {
+	title := i.Issue.Title
+	if title == "" {
+		title = i.BookID
+	}
+	if i.Chapter > 0 {
+		title = fmt.Sprintf("%s #%d", title, i.Chapter)
+	}
+	return title
+}
+
+func (i doctorIssueItem) Description() string {
+	return fmt.Sprintf(lang.Active().Settings.DoctorIssueTemplate, i.Kind, i.Fix)
+}
+
+func (i doctorIssueItem) FilterValue() string {
+	return i.Title() + " " + i.BookID + " " + i.Kind.String()
+}
+
+type doctorDoneMsg struct {
+	Report library.DoctorReport
+	Err    error
+}
+
+type doctorRepairedMsg struct {
+	Fixed  int
+	Errs   []error
+	Report library.DoctorReport
+	Err    error
+}
+
+func diagnoseCmd() tea.Cmd {
+	return func() tea.Msg {
+		report, err := library.Diagnose()
+		return doctorDoneMsg{Report: report, Err: err}
+	}
+}
+
+// repairCmd fixes issues off the UI goroutine, since re-fetching chapters
+// goes to the network, then checks again.
+func repairCmd(issues []library.Issue) tea.Cmd {
+	return func() tea.Msg {
+		fixed, errs := library.Repair(issues)
+		report, err := library.Diagnose()
+		return doctorRepairedMsg{Fixed: fixed, Errs: errs, Report: report, Err: err}
+	}
+}
+
+func (m *LibraryModel) startDoctor() tea.Cmd {
+	m.settingsBusy = true
+	m.settingsStatusKind = settingsStatusDoctorRunning
+	m.settingsStatusErr = nil
+	return diagnoseCmd()
+}
+
+func (m *LibraryModel) handleDoctorDone(tm doctorDoneMsg) {
+	m.settingsBusy = false
+	if tm.Err != nil {
+		m.setCacheFailed(tm.Err)
+		return
+	}
+	m.doctorReport = tm.Report
+	if len(tm.Report.Issues) == 0 {
+		m.settingsStatusKind = settingsStatusDoctorHealthy
+		m.settingsStatusErr = nil
+		return
+	}
+	m.enterRemoveMode(SettingDoctor)
+}
+
+func (m *LibraryModel) handleDoctorRepaired(tm doctorRepairedMsg) {
+	m.settingsBusy = false
+	if refreshed, err := library.LoadAllCachedNovels(); err == nil {
+		sortOnlineNovels(refreshed)
+		m.onlineNovels = refreshed
+	}
+	if tm.Err != nil {
+		m.setCacheFailed(tm.Err)
+		return
+	}
+	m.doctorReport = tm.Report
+	m.refreshDoctorList()
+	m.updateSettingItem(SettingCacheUsage, func(s *SettingItem) {
+		s.Value = cacheUsageValue()
+	})
+	if len(m.removeList.Items()) == 0 && m.settingsState == settingsStateRemoving {
+		m.exitRemoveMode()
+	}
+	m.settingsStatusKind = settingsStatusDoctorFixed
+	m.doctorFixed = tm.Fixed
+	m.doctorFailed = len(tm.Errs)
+	m.settingsStatusErr = nil
+	if len(tm.Errs) > 0 {
+		m.settingsStatusErr = tm.Errs[0]
+	}
+}
+
+func (m *LibraryModel) refreshDoctorList() {
+	index := m.removeList.Index()
+	items := make([]list.Item, len(m.doctorReport.Issues))
+	for i, issue := range m.doctorReport.Issues {
+		items[i] = doctorIssueItem{issue}
+	}
+	m.removeList.SetItems(items)
+	if len(items) == 0 {
+		return
+	}
+	if index >= len(items) {
+		index = len(items) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	m.removeList.Select(index)
+}
+
+// handleDoctorKey runs the fix actions of the cache check screen. It
+// reports whether key was one of them.
+func (m *LibraryModel) handleDoctorKey(key string) (tea.Cmd, bool) {
+	var issues []library.Issue
+	switch key {
+	case "f":
+		item, ok := m.removeList.SelectedItem().(doctorIssueItem)
+		if !ok {
+			return nil, false
+		}
+		issues = []library.Issue{item.Issue}
+	case "a":
+		issues = m.doctorReport.Fixable()
+	default:
+		return nil, false
+	}
+	if m.settingsBusy {
+		return nil, true
+	}
+	m.settingsBusy = true
+	m.settingsStatusKind = settingsStatusDoctorRunning
+	m.settingsStatusErr = nil
+	return repairCmd(issues), true
+}
+
+func (m LibraryModel) doctorStatusText() string {
+	texts := lang.Active()
+	switch m.settingsStatusKind {
+	case settingsStatusDoctorRunning:
+		return texts.Settings.DoctorRunning
+	case settingsStatusDoctorHealthy:
+		return texts.Settings.DoctorHealthy
+	case settingsStatusDoctorFixed:
+		text := fmt.Sprintf(texts.Settings.DoctorFixedTemplate, m.doctorFixed, m.doctorFailed)
+		if m.settingsStatusErr != nil {
+			text += ": " + m.settingsStatusErr.Error()
+		}
+		return text
+	}
+	return ""
+}
+
+func doctorHints(report library.DoctorReport) string {
+	texts := lang.Active()
+	summary := fmt.Sprintf(texts.Settings.DoctorSummaryTemplate, len(report.Issues), len(report.Fixable()))
+	return summary + "  " + strings.TrimSpace(texts.Settings.DoctorHints)
+}
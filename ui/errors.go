@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"novel_reader/lang"
+	"novel_reader/library"
+)
+
+// chapterErrMsg reports a failed chapter download for the open reader.
+type chapterErrMsg struct {
+	BookID  string
+	Chapter int
+	Err     error
+}
+
+// errorReportedMsg is sent after an error report has been written.
+type errorReportedMsg struct {
+	Path string
+	Err  error
+}
+
+// switchSourceMsg asks the app to search the Discovery tab for another
+// listing of the same title.
+type switchSourceMsg struct {
+	Title string
+}
+
+// recoveryKeys maps each recovery action to the key that triggers it.
+var recoveryKeys = map[library.RecoveryAction]string{
+	library.ActionRetry:        "r",
+	library.ActionSwitchSource: "s",
+	library.ActionOpenCached:   "o",
+	library.ActionReport:       "p",
+}
+
+// errorText renders a typed library error as a user-facing sentence.
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	texts := lang.Active()
+	var le *library.Error
+	if !errors.As(err, &le) {
+		return lang.ErrorUnknown(err)
+	}
+	switch le.Kind {
+	case library.KindNetwork:
+		return texts.Errors.Network
+	case library.KindHTTPStatus:
+		return lang.ErrorHTTPStatus(le.Status)
+	case library.KindRateLimited:
+		return texts.Errors.RateLimited
+	case library.KindParse:
+		return texts.Errors.Parse
+	case library.KindNotFound:
+		return texts.Errors.NotFound
+	case library.KindCacheCorrupt:
+		return texts.Errors.CacheCorrupt
+	case library.KindOffline:
+		return texts.Errors.Offline
+	default:
+		return lang.ErrorUnknown(err)
+	}
+}
+
+func actionLabel(action library.RecoveryAction) string {
+	texts := lang.Active()
+	switch action {
+	case library.ActionRetry:
+		return texts.Errors.ActionRetry
+	case library.ActionSwitchSource:
+		return texts.Errors.ActionSwitchSource
+	case library.ActionOpenCached:
+		return texts.Errors.ActionOpenCached
+	case library.ActionReport:
+		return texts.Errors.ActionReport
+	default:
+		return ""
+	}
+}
+
+// availableActions filters the actions suggested for err down to the ones
+// the caller can actually perform.
+func availableActions(err error, can func(library.RecoveryAction) bool) []library.RecoveryAction {
+	var out []library.RecoveryAction
+	for _, a := range library.ActionsFor(err) {
+		if can == nil || can(a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// actionHints renders "[r] Retry  [o] Open cached ..." for the given actions.
+func actionHints(actions []library.RecoveryAction) string {
+	parts := make([]string, 0, len(actions))
+	for _, a := range actions {
+		parts = append(parts, "["+recoveryKeys[a]+"] "+actionLabel(a))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// actionForKey returns the recovery action bound to key, if it is offered.
+func actionForKey(key string, actions []library.RecoveryAction) (library.RecoveryAction, bool) {
+	for _, a := range actions {
+		if recoveryKeys[a] == key {
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+func reportErrorCmd(err error) tea.Cmd {
+	return func() tea.Msg {
+		path, writeErr := library.WriteErrorReport(err)
+		return errorReportedMsg{Path: path, Err: writeErr}
+	}
+}
+
+func errorReportText(msg errorReportedMsg) string {
+	if msg.Err != nil {
+		return lang.ErrorReportFailed(msg.Err)
+	}
+	return lang.ErrorReportSaved(msg.Path)
+}
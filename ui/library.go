@@ -42,21 +42,35 @@ type LibraryModel struct {
 	searchStatusTitle    string
 	scrapeTitle          string
 	searchErr            error
+	searchNotice         string
+	lastSearchResult     *library.SearchResult
 	settingsStatusKind   settingsStatusKind
 	settingsStatusErr    error
+	settingsStatusBytes  int64
 	settingsBusy         bool
 	settingsState        settingsState
 	pendingRemovePath    string
 	pendingRemoveNovel   *library.Novel
+	pendingCacheID       string
+	pendingCacheTitle    string
+	doctorReport         library.DoctorReport
+	doctorFixed          int
+	doctorFailed         int
+	detailNovel          *library.Novel
+	detailNotice         string
 	removeMode           SettingKind
 	confirmPrompt        string
 	language             lang.Locale
 	localByRoot          map[string][]library.Novel
+	localNovels          []library.Novel
+	scanGen              int
+	watcher              *library.Watcher
 	onlineNovels         []library.Novel
 	bookshelfRootItems   []BookshelfItem
 	bookshelfInFolder    bool
 	bookshelfInDiscovery bool
 	bookshelfActiveRoot  string
+	bookshelfSort        library.LocalSort
 }
 
 type BookshelfItemKind int
@@ -108,6 +122,9 @@ const (
 	SettingAddLibraryFolder
 	SettingRemoveLibraryFolder
 	SettingRemoveOnlineNovel
+	SettingOffline
+	SettingCacheUsage
+	SettingDoctor
 )
 
 type settingsState int
@@ -135,6 +152,11 @@ const (
 	settingsStatusLineSpacingFailed
 	settingsStatusDialogUnavailable
 	settingsStatusSaveFailed
+	settingsStatusReclaimed
+	settingsStatusCacheFailed
+	settingsStatusDoctorRunning
+	settingsStatusDoctorHealthy
+	settingsStatusDoctorFixed
 )
 
 const maxLineSpacing = 5
@@ -152,7 +174,7 @@ func (s SettingItem) Title() string {
 	switch s.Kind {
 	case SettingLineSpacing:
 		return fmt.Sprintf("%s: %d", s.Label, s.IntValue)
-	case SettingLanguage:
+	case SettingLanguage, SettingOffline, SettingCacheUsage:
 		if strings.TrimSpace(s.Value) == "" {
 			return s.Label
 		}
@@ -225,6 +247,23 @@ func (m LibraryModel) settingsStatusText() string {
 		if m.settingsStatusErr != nil {
 			return fmt.Sprintf(lang.Active().Settings.SaveConfigFailed, m.settingsStatusErr)
 		}
+	case settingsStatusReclaimed:
+		return reclaimedText(m.settingsStatusBytes)
+	case settingsStatusCacheFailed:
+		if m.settingsStatusErr != nil {
+			return fmt.Sprintf(lang.Active().Settings.CacheFailedTemplate, m.settingsStatusErr)
+		}
+	case settingsStatusDoctorRunning, settingsStatusDoctorHealthy, settingsStatusDoctorFixed:
+		return m.doctorStatusText()
+	}
+	if utils.ReadOnly() {
+		return lang.Active().Settings.ReadOnlyNotice
+	}
+	if m.settingsState == settingsStateRemoving && m.removeMode == SettingCacheUsage {
+		return cacheHints()
+	}
+	if m.settingsState == settingsStateRemoving && m.removeMode == SettingDoctor {
+		return doctorHints(m.doctorReport)
 	}
 	return ""
 }
@@ -262,6 +301,12 @@ func (m *LibraryModel) rebuildSettingsList() {
 			Detail:   texts.Settings.LineSpacingDetail,
 			IntValue: utils.AppConfig.Reader.LineSpacing,
 		},
+		SettingItem{
+			Kind:   SettingOffline,
+			Label:  texts.Settings.OfflineLabel,
+			Detail: texts.Settings.OfflineDetail,
+			Value:  offlineValue(),
+		},
 		SettingItem{
 			Kind:     SettingAddLibraryFolder,
 			Label:    texts.Settings.AddFolderLabel,
@@ -280,6 +325,17 @@ func (m *LibraryModel) rebuildSettingsList() {
 			Detail:   texts.Settings.RemoveOnlineDetail,
 			IntValue: len(m.onlineNovels),
 		},
+		SettingItem{
+			Kind:   SettingCacheUsage,
+			Label:  texts.Settings.CacheLabel,
+			Detail: texts.Settings.CacheDetail,
+			Value:  cacheUsageValue(),
+		},
+		SettingItem{
+			Kind:   SettingDoctor,
+			Label:  texts.Settings.DoctorLabel,
+			Detail: texts.Settings.DoctorDetail,
+		},
 	}
 
 	settings.SetItems(items)
@@ -305,6 +361,45 @@ func (m *LibraryModel) rebuildSettingsList() {
 	m.lists[3] = settings
 }
 
+func offlineValue() string {
+	if utils.Offline() {
+		return lang.Active().Settings.OfflineOn
+	}
+	return lang.Active().Settings.OfflineOff
+}
+
+// toggleOffline flips offline mode and persists it. Turning it off also
+// clears a session-only --offline flag.
+func (m *LibraryModel) toggleOffline() error {
+	previous := utils.AppConfig.Network.Offline
+	previousForce := utils.ForceOffline
+	enable := !utils.Offline()
+	utils.AppConfig.Network.Offline = enable
+	if !enable {
+		utils.ForceOffline = false
+	}
+	if err := utils.SaveConfig(); err != nil {
+		utils.AppConfig.Network.Offline = previous
+		utils.ForceOffline = previousForce
+		return err
+	}
+	m.updateSettingItem(SettingOffline, func(s *SettingItem) {
+		s.Value = offlineValue()
+	})
+	return nil
+}
+
+func (m *LibraryModel) applyOfflineToggle() tea.Cmd {
+	if err := m.toggleOffline(); err != nil {
+		m.settingsStatusKind = settingsStatusSaveFailed
+		m.settingsStatusErr = err
+		return nil
+	}
+	m.settingsStatusKind = settingsStatusNone
+	m.settingsStatusErr = nil
+	return func() tea.Msg { return offlineChangedMsg{} }
+}
+
 func (m *LibraryModel) updateConfirmPrompt() {
 	if m.settingsState != settingsStateConfirm {
 		return
@@ -323,6 +418,9 @@ func (m *LibraryModel) updateConfirmPrompt() {
 		}
 		prompt = fmt.Sprintf(texts.Confirm.RemoveOnlinePromptTemplate, name)
 		label = texts.Confirm.RemoveOnlineConfirm
+	case SettingCacheUsage:
+		prompt = fmt.Sprintf(texts.Confirm.ClearCachePromptTemplate, m.pendingCacheTitle)
+		label = texts.Confirm.ClearCacheConfirm
 	default:
 		return
 	}
@@ -478,7 +576,7 @@ func (m *LibraryModel) showBookshelfFolder(path string) {
 	m.bookshelfInDiscovery = false
 	m.bookshelfActiveRoot = path
 
-	novels := append([]library.Novel(nil), m.localByRoot[path]...)
+	novels := m.bookshelfNovels(path)
 	items := make([]list.Item, len(novels))
 	for i := range novels {
 		items[i] = novels[i]
@@ -490,6 +588,26 @@ func (m *LibraryModel) showBookshelfFolder(path string) {
 	m.updateBookshelfSize()
 }
 
+// bookshelfNovels returns the books of a library folder in the order the
+// bookshelf sorts them.
+func (m *LibraryModel) bookshelfNovels(path string) []library.Novel {
+	novels := append([]library.Novel(nil), m.localByRoot[path]...)
+	library.SortLocalNovels(novels, m.bookshelfSort)
+	return novels
+}
+
+func (m LibraryModel) bookshelfSortText() string {
+	texts := lang.Active().Bookshelf
+	name := texts.SortAdded
+	switch m.bookshelfSort {
+	case library.SortByTitle:
+		name = texts.SortTitle
+	case library.SortByAuthor:
+		name = texts.SortAuthor
+	}
+	return fmt.Sprintf(texts.SortTemplate, name)
+}
+
 func (m *LibraryModel) showBookshelfDiscovery(item BookshelfItem) {
 	m.bookshelfInFolder = false
 	m.bookshelfInDiscovery = true
@@ -506,6 +624,9 @@ func (m *LibraryModel) showBookshelfDiscovery(item BookshelfItem) {
 	m.updateSettingItem(SettingRemoveOnlineNovel, func(s *SettingItem) {
 		s.IntValue = len(novels)
 	})
+	m.updateSettingItem(SettingCacheUsage, func(s *SettingItem) {
+		s.Value = cacheUsageValue()
+	})
 
 	for i := range m.bookshelfRootItems {
 		if m.bookshelfRootItems[i].Kind == BookshelfItemDiscovery {
@@ -574,11 +695,7 @@ func (m *LibraryModel) refreshHistoryList(progress map[string]utils.Progress) {
 			continue
 		}
 
-		source := "local"
-		if !n.IsLocal {
-			source = "online"
-		}
-		if p, ok := utils.GetProgress(progress, n.Name, source); ok {
+		if p, ok := utils.GetProgress(progress, n.ID); ok {
 			if !p.LastRead.IsZero() {
 				n.Modified = p.LastRead
 			}
@@ -588,11 +705,11 @@ func (m *LibraryModel) refreshHistoryList(progress map[string]utils.Progress) {
 		}
 
 		if n.IsLocal {
-			if latest, err := library.LatestChapter(n.Path); err == nil && latest != "" {
+			if latest, err := library.LatestChapter(n.Path, n.ID); err == nil && latest != "" {
 				n.Latest = latest
 			}
 		} else {
-			if chapters, err := library.LoadChapterList(n.Name); err == nil && len(chapters) > 0 {
+			if chapters, err := library.LoadChapterList(n.ID); err == nil && len(chapters) > 0 {
 				last := chapters[len(chapters)-1]
 				latestTitle := strings.TrimSpace(last.Title)
 				if latestTitle == "" {
@@ -630,13 +747,43 @@ func (m *LibraryModel) updateBookshelfSize() {
 	if availWidth < 0 {
 		availWidth = ListMaxWidth
 	}
-	availHeight := m.height - 5
+	availHeight := m.height - 5 - m.statusLines(1)
 	if availHeight < 3 {
 		availHeight = 3
 	}
 	m.lists[1].SetSize(availWidth, availHeight)
 }
 
+// oneLine cuts a status text to the one line StatusStyle gives it at width.
+func oneLine(text string, width int) string {
+	return runewidth.Truncate(text, max(width-8, 1), "…")
+}
+
+// skippedText names the books the last scan left out.
+func skippedText(skipped []library.SkippedBook) string {
+	names := make([]string, len(skipped))
+	for i, b := range skipped {
+		names[i] = filepath.Base(b.Path)
+	}
+	return fmt.Sprintf(lang.Active().Errors.SkippedTemplate, len(skipped), strings.Join(names, ", "))
+}
+
+// statusLines is how many lines the tab shows above its list. Each status
+// takes two: the padding above it and its text.
+func (m LibraryModel) statusLines(tab int) int {
+	n := 0
+	if (tab == 0 || tab == 1) && library.ProgressError() != nil {
+		n += 2
+	}
+	if (tab == 0 || tab == 1) && len(library.SkippedBooks()) > 0 {
+		n += 2
+	}
+	if tab == 1 && m.bookshelfInFolder {
+		n += 2 // the sort line
+	}
+	return n
+}
+
 func (m LibraryModel) handleBookshelfEnter() (LibraryModel, tea.Cmd) {
 	selected := m.lists[1].SelectedItem()
 	if selected == nil {
@@ -696,7 +843,7 @@ func (m *LibraryModel) resize(width, height int) {
 		availHeight = 3
 	}
 	for i := range m.lists {
-		m.lists[i].SetSize(availWidth, availHeight)
+		m.lists[i].SetSize(availWidth, max(3, availHeight-m.statusLines(i)))
 	}
 	m.removeList.SetSize(availWidth, availHeight)
 	confirmWidth := availWidth
@@ -715,7 +862,9 @@ func (m *LibraryModel) resize(width, height int) {
 }
 
 // ---------------- Update ----------------
-func (m LibraryModel) Init() tea.Cmd { return nil }
+func (m LibraryModel) Init() tea.Cmd {
+	return tea.Batch(scanLibraryCmd(m.scanGen), waitLibraryChangeCmd(m.watcher))
+}
 
 type searchMsg struct {
 	Items []list.Item
@@ -739,6 +888,10 @@ type folderSelectedMsg struct {
 	Err  error
 }
 
+// offlineChangedMsg tells the open reader to recompute which chapters are
+// unavailable.
+type offlineChangedMsg struct{}
+
 type languageChangedMsg struct {
 	Locale lang.Locale
 }
@@ -749,6 +902,7 @@ func (m LibraryModel) handleSearchMsg(tm searchMsg) (LibraryModel, tea.Cmd) {
 	}
 	if tm.Err != nil {
 		m.searchErr = tm.Err
+		m.searchNotice = ""
 		m.searchLoading = false
 		m.clearSearchStatus()
 		return m, nil
@@ -770,6 +924,7 @@ func (m LibraryModel) handleSearchMsg(tm searchMsg) (LibraryModel, tea.Cmd) {
 	m.searchLoading = false
 	m.searchQuery = tm.Query
 	m.searchErr = nil
+	m.searchNotice = ""
 	m.searchStatusKind = searchStatusFound
 	m.searchStatusCount = len(tm.Items)
 	m.searchStatusTitle = ""
@@ -785,6 +940,17 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 	case tea.KeyMsg:
 		key := tm.String()
 
+		if m.detailNovel != nil && key != "ctrl+c" {
+			switch key {
+			case "esc", "i", "q", "enter", "backspace":
+				m.detailNovel = nil
+				m.detailNotice = ""
+			case "e":
+				m.detailNotice = editLocalMeta(*m.detailNovel)
+			}
+			return m, nil
+		}
+
 		// --- Global keys ---
 		switch key {
 		case "ctrl+c":
@@ -837,7 +1003,7 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 				}
 				return m, nil
 			}
-			if m.activeTab == 2 && (m.discoveryInput.Value() != "" || m.searchQuery != "" || m.searchLoading || m.scrapeLoading) {
+			if m.activeTab == 2 && (m.discoveryInput.Value() != "" || m.searchQuery != "" || m.searchLoading || m.scrapeLoading || m.searchErr != nil) {
 				m.searchLoading = false
 				m.scrapeLoading = false
 				m.scrapeTitle = ""
@@ -846,6 +1012,8 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 				m.discoveryInput.SetValue("")
 				m.lists[2].SetItems(nil)
 				m.searchErr = nil
+				m.searchNotice = ""
+				m.lastSearchResult = nil
 				return m, nil
 			}
 			return m, nil
@@ -890,6 +1058,15 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 			if m.scrapeLoading {
 				return m, nil
 			}
+			if m.searchErr != nil && !m.searchLoading {
+				if action, ok := actionForKey(key, m.discoveryActions()); ok {
+					return m, m.runDiscoveryAction(action)
+				}
+				if len(m.lists[2].Items()) == 0 {
+					// the error screen replaces the input until esc dismisses it
+					return m, nil
+				}
+			}
 			// Navigation in results
 			if len(m.lists[2].Items()) > 0 {
 				switch key {
@@ -904,16 +1081,10 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 				case "enter":
 					selected := m.lists[2].SelectedItem()
 					if sr, ok := selected.(library.SearchResult); ok {
-						m.scrapeLoading = true
-						m.scrapeTitle = sr.Name
-						m.searchStatusKind = searchStatusLoadingTitle
-						m.searchStatusTitle = sr.Name
-						m.searchStatusCount = 0
-						m.searchErr = nil
-
 						// Add to History immediately
 						historyList := m.lists[0]
 						novel := library.Novel{
+							ID:        library.OnlineBookID(sr.URL),
 							Name:      sr.Name,
 							Author:    sr.Author,
 							OnlineURL: sr.URL,
@@ -923,11 +1094,7 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 						historyList.InsertItem(0, novel)
 						m.lists[0] = historyList
 
-						// Async scrape
-						return m, func() tea.Msg {
-							novel, _ := library.ScrapeAndSaveChapters(sr)
-							return novelOpenMsg{Novel: novel}
-						}
+						return m, m.openSearchResult(sr)
 					}
 				}
 
@@ -950,21 +1117,7 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 				if key == "enter" {
 					query := strings.TrimSpace(m.discoveryInput.Value())
 					if query != "" && !m.searchLoading {
-						m.searchQuery = query
-						m.searchLoading = true
-						m.scrapeLoading = false
-						m.scrapeTitle = ""
-						m.searchErr = nil
-						m.searchStatusKind = searchStatusSearching
-						m.searchStatusTitle = ""
-						m.searchStatusCount = 0
-						m.lists[2].SetItems(nil)
-						m.discoveryInput.SetValue("")
-
-						return m, func() tea.Msg {
-							items, err := library.SearchNovel(query)
-							return searchMsg{Items: items, Query: query, Err: err}
-						}
+						return m, m.startSearch(query)
 					}
 				}
 			}
@@ -1004,6 +1157,8 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 								return m, cmd
 							}
 							return m, nil
+						case SettingOffline:
+							return m, m.applyOfflineToggle()
 						}
 					}
 				case "l", "right":
@@ -1024,11 +1179,15 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 								return m, cmd
 							}
 							return m, nil
+						case SettingOffline:
+							return m, m.applyOfflineToggle()
 						}
 					}
 				case "enter":
 					if s, ok := selected.(SettingItem); ok {
 						switch s.Kind {
+						case SettingOffline:
+							return m, m.applyOfflineToggle()
 						case SettingAddLibraryFolder:
 							if m.settingsBusy {
 								return m, nil
@@ -1047,6 +1206,17 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 							}
 							m.enterRemoveMode(SettingRemoveOnlineNovel)
 							return m, nil
+						case SettingCacheUsage:
+							if m.settingsBusy {
+								return m, nil
+							}
+							m.enterRemoveMode(SettingCacheUsage)
+							return m, nil
+						case SettingDoctor:
+							if m.settingsBusy {
+								return m, nil
+							}
+							return m, m.startDoctor()
 						}
 					}
 					return m, nil
@@ -1057,6 +1227,16 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 				return m, c
 
 			case settingsStateRemoving:
+				if m.removeMode == SettingCacheUsage && m.removeList.FilterState() != list.Filtering {
+					if m.handleCacheKey(key) {
+						return m, nil
+					}
+				}
+				if m.removeMode == SettingDoctor && m.removeList.FilterState() != list.Filtering {
+					if cmd, ok := m.handleDoctorKey(key); ok {
+						return m, cmd
+					}
+				}
 				switch key {
 				case "j", "down":
 					newList, c := m.removeList.Update(tea.KeyMsg{Type: tea.KeyDown})
@@ -1104,18 +1284,23 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 					return m, nil
 				case "enter":
 					if ch, ok := m.confirmList.SelectedItem().(confirmChoice); ok {
+						var cmd tea.Cmd
 						if ch.Value {
 							switch m.removeMode {
 							case SettingRemoveLibraryFolder:
 								if err := m.removeLibraryPath(m.pendingRemovePath); err != nil {
 									// handle error & bounce back
+								} else {
+									cmd = m.watchLibrary()
 								}
 							case SettingRemoveOnlineNovel:
 								if m.pendingRemoveNovel != nil {
-									if err := m.removeOnlineNovel(m.pendingRemoveNovel.Name); err != nil {
-										// handle error & bounce back
+									if err := m.removeOnlineNovel(m.pendingRemoveNovel.ID); err != nil {
+										m.setCacheFailed(err)
 									}
 								}
+							case SettingCacheUsage:
+								m.clearPendingCache()
 							}
 							m.confirmPrompt = ""
 							m.pendingRemovePath = ""
@@ -1125,7 +1310,7 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 							} else {
 								m.settingsState = settingsStateRemoving
 							}
-							return m, nil
+							return m, cmd
 						}
 						// cancel
 						m.settingsState = settingsStateRemoving
@@ -1149,6 +1334,15 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 
 		// --- Other tabs (local/history) ---
 		switch key {
+		case "i":
+			if m.lists[m.activeTab].FilterState() != list.Filtering && m.openDetail() {
+				return m, nil
+			}
+		case "s":
+			if m.activeTab == 1 && m.bookshelfInFolder && m.lists[1].FilterState() != list.Filtering {
+				m.bookshelfSort = (m.bookshelfSort + 1) % (library.SortByAuthor + 1)
+				return m, setNovelItems(&m.lists[1], m.bookshelfNovels(m.bookshelfActiveRoot))
+			}
 		case "j", "down":
 			newList, c := m.lists[m.activeTab].Update(tea.KeyMsg{Type: tea.KeyDown})
 			m.lists[m.activeTab] = newList
@@ -1182,6 +1376,23 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 	case searchMsg:
 		return m.handleSearchMsg(tm)
 
+	case doctorDoneMsg:
+		m.handleDoctorDone(tm)
+		return m, nil
+
+	case doctorRepairedMsg:
+		m.handleDoctorRepaired(tm)
+		return m, nil
+
+	case libraryScanMsg:
+		return m, m.handleLibraryScan(tm)
+
+	case libraryChangedMsg:
+		if tm.watcher != m.watcher {
+			return m, nil // from before the folders changed
+		}
+		return m, tea.Batch(waitLibraryChangeCmd(m.watcher), m.startLibraryScan())
+
 	case folderSelectedMsg:
 		m.settingsBusy = false
 		if tm.Err != nil {
@@ -1211,7 +1422,7 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 
 		m.settingsStatusKind = settingsStatusNone
 		m.settingsStatusErr = nil
-		return m, nil
+		return m, tea.Batch(m.startLibraryScan(), m.watchLibrary())
 
 	case scrapeMsg:
 		m.searchLoading = false
@@ -1234,9 +1445,16 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 
 	case errMsg:
 		m.searchErr = tm.error
+		m.searchNotice = ""
 		m.searchLoading = false
+		m.scrapeLoading = false
+		m.scrapeTitle = ""
 		m.clearSearchStatus()
 		return m, nil
+
+	case errorReportedMsg:
+		m.searchNotice = errorReportText(tm)
+		return m, nil
 	}
 
 	// Default: delegate to active list
@@ -1245,6 +1463,97 @@ func (m LibraryModel) Update(msg tea.Msg) (LibraryModel, tea.Cmd) {
 	return m, cmd
 }
 
+// startSearch kicks off an async search on the Discovery tab.
+func (m *LibraryModel) startSearch(query string) tea.Cmd {
+	m.searchQuery = query
+	m.searchLoading = true
+	m.scrapeLoading = false
+	m.scrapeTitle = ""
+	m.searchErr = nil
+	m.searchNotice = ""
+	m.searchStatusKind = searchStatusSearching
+	m.searchStatusTitle = ""
+	m.searchStatusCount = 0
+	m.lists[2].SetItems(nil)
+	m.discoveryInput.SetValue("")
+
+	return func() tea.Msg {
+		items, err := library.SearchNovel(query)
+		return searchMsg{Items: items, Query: query, Err: err}
+	}
+}
+
+// openSearchResult scrapes the selected search result and opens it.
+func (m *LibraryModel) openSearchResult(sr library.SearchResult) tea.Cmd {
+	if strings.HasPrefix(sr.URL, "/") {
+		sr.URL = library.BaseURL + sr.URL
+	}
+	m.scrapeLoading = true
+	m.scrapeTitle = sr.Name
+	m.searchStatusKind = searchStatusLoadingTitle
+	m.searchStatusTitle = sr.Name
+	m.searchStatusCount = 0
+	m.searchErr = nil
+	m.searchNotice = ""
+	m.lastSearchResult = &sr
+
+	return func() tea.Msg {
+		novel, err := library.ScrapeAndSaveChapters(sr)
+		if err != nil {
+			return errMsg{err}
+		}
+		return novelOpenMsg{Novel: novel}
+	}
+}
+
+// discoveryActions lists the recovery actions available for the current
+// Discovery tab error.
+func (m LibraryModel) discoveryActions() []library.RecoveryAction {
+	return availableActions(m.searchErr, func(a library.RecoveryAction) bool {
+		switch a {
+		case library.ActionRetry:
+			return m.lastSearchResult != nil || strings.TrimSpace(m.searchQuery) != ""
+		case library.ActionSwitchSource:
+			return m.lastSearchResult != nil
+		case library.ActionOpenCached:
+			return m.lastSearchResult != nil && library.HasCachedNovel(library.OnlineBookID(m.lastSearchResult.URL))
+		}
+		return true
+	})
+}
+
+func (m *LibraryModel) runDiscoveryAction(action library.RecoveryAction) tea.Cmd {
+	switch action {
+	case library.ActionRetry:
+		if m.lastSearchResult != nil {
+			return m.openSearchResult(*m.lastSearchResult)
+		}
+		return m.startSearch(m.searchQuery)
+	case library.ActionSwitchSource:
+		if m.lastSearchResult == nil {
+			return nil
+		}
+		title := m.lastSearchResult.Name
+		m.lastSearchResult = nil
+		return m.startSearch(title)
+	case library.ActionOpenCached:
+		if m.lastSearchResult == nil {
+			return nil
+		}
+		novel, err := library.LoadCachedNovel(library.OnlineBookID(m.lastSearchResult.URL))
+		if err != nil {
+			m.searchErr = err
+			return nil
+		}
+		m.searchErr = nil
+		m.searchNotice = ""
+		return func() tea.Msg { return novelOpenMsg{Novel: novel} }
+	case library.ActionReport:
+		return reportErrorCmd(m.searchErr)
+	}
+	return nil
+}
+
 // ---------------- View ----------------
 func (m LibraryModel) View() string {
 	var renderedTabs []string
@@ -1269,7 +1578,7 @@ func (m LibraryModel) View() string {
 	underlineRow := UnderlineRow.Width(m.width).Render(strings.Repeat("─", lineWidth))
 
 	if m.activeTab == 2 {
-		showInput := len(m.lists[2].Items()) == 0 && !m.searchLoading && !m.scrapeLoading && m.searchStatusKind == searchStatusNone
+		showInput := len(m.lists[2].Items()) == 0 && !m.searchLoading && !m.scrapeLoading && m.searchStatusKind == searchStatusNone && m.searchErr == nil
 		inputView := ""
 		if showInput {
 			inputView = gloss.Place(
@@ -1292,7 +1601,13 @@ func (m LibraryModel) View() string {
 		case m.searchLoading:
 			statusText = texts.Search.Searching
 		case m.searchErr != nil:
-			statusText = lang.SearchError(m.searchErr)
+			statusText = errorText(m.searchErr)
+			if hints := actionHints(m.discoveryActions()); hints != "" {
+				statusText += "\n" + hints
+			}
+			if m.searchNotice != "" {
+				statusText += "\n" + m.searchNotice
+			}
 		case m.searchStatusKind != searchStatusNone:
 			statusText = m.searchStatusText()
 		}
@@ -1348,6 +1663,15 @@ func (m LibraryModel) View() string {
 			result += "\n" + StatusStyle.Width(m.width).Render(status)
 		}
 	}
+	if err := library.ProgressError(); err != nil && (m.activeTab == 0 || m.activeTab == 1) {
+		result += "\n" + StatusStyle.Width(m.width).Render(oneLine(lang.Active().Errors.ProgressUnreadable, m.width))
+	}
+	if skipped := library.SkippedBooks(); len(skipped) > 0 && (m.activeTab == 0 || m.activeTab == 1) {
+		result += "\n" + StatusStyle.Width(m.width).Render(oneLine(skippedText(skipped), m.width))
+	}
+	if m.activeTab == 1 && m.bookshelfInFolder {
+		result += "\n" + StatusMutedStyle.Width(m.width).Render(m.bookshelfSortText())
+	}
 	result += listView
 	if m.activeTab == 3 && m.settingsState == settingsStateConfirm {
 		dlgW, contentW := m.confirmDialogWidths()
@@ -1365,6 +1689,9 @@ func (m LibraryModel) View() string {
 		base := tabsRow + "\n" + underlineRow + listView
 		return base + "\n" + overlay // no dimming
 	}
+	if m.detailNovel != nil {
+		return tabsRow + "\n" + underlineRow + listView + "\n" + m.detailView(*m.detailNovel)
+	}
 	return result
 }
 
@@ -1394,6 +1721,12 @@ func (d *NovelDelegate) Render(w io.Writer, m list.Model, index int, item list.I
 	case confirmChoice:
 		title = v.Title()
 		desc = runewidth.Truncate(v.Description(), m.Width()-10, "…")
+	case cacheUsageItem:
+		title = v.Title()
+		desc = runewidth.Truncate(v.Description(), m.Width()-10, "…")
+	case doctorIssueItem:
+		title = v.Title()
+		desc = runewidth.Truncate(v.Description(), m.Width()-10, "…")
 	default:
 		title = lang.Active().Bookshelf.UnknownType
 		desc = ""
@@ -1459,6 +1792,16 @@ func (m *LibraryModel) enterRemoveMode(kind SettingKind) {
 			return
 		}
 		m.refreshRemoveOnlineList("")
+	case SettingCacheUsage:
+		m.refreshCacheUsageList("")
+		if len(m.removeList.Items()) == 0 {
+			return
+		}
+	case SettingDoctor:
+		m.refreshDoctorList()
+		if len(m.removeList.Items()) == 0 {
+			return
+		}
 	default:
 		return
 	}
@@ -1510,12 +1853,12 @@ func (m *LibraryModel) refreshRemoveLibraryList(preferPath string) {
 	}
 }
 
-func (m *LibraryModel) refreshRemoveOnlineList(preferName string) {
-	var currentName string
-	if preferName != "" {
-		currentName = preferName
+func (m *LibraryModel) refreshRemoveOnlineList(preferID string) {
+	var currentID string
+	if preferID != "" {
+		currentID = preferID
 	} else if selected, ok := m.removeList.SelectedItem().(library.Novel); ok {
-		currentName = selected.Name
+		currentID = selected.ID
 	}
 
 	items := make([]list.Item, len(m.onlineNovels))
@@ -1529,9 +1872,9 @@ func (m *LibraryModel) refreshRemoveOnlineList(preferName string) {
 	}
 
 	target := -1
-	if currentName != "" {
+	if currentID != "" {
 		for i, item := range items {
-			if novel, ok := item.(library.Novel); ok && novel.Name == currentName {
+			if novel, ok := item.(library.Novel); ok && novel.ID == currentID {
 				target = i
 				break
 			}
@@ -1553,6 +1896,10 @@ func (m *LibraryModel) exitRemoveMode() {
 	switch m.removeMode {
 	case SettingRemoveOnlineNovel:
 		m.selectSettingItem(SettingRemoveOnlineNovel)
+	case SettingCacheUsage:
+		m.selectSettingItem(SettingCacheUsage)
+	case SettingDoctor:
+		m.selectSettingItem(SettingDoctor)
 	default:
 		m.selectSettingItem(SettingRemoveLibraryFolder)
 	}
@@ -1725,17 +2072,17 @@ func (m *LibraryModel) removeLibraryPath(path string) error {
 	return nil
 }
 
-func (m *LibraryModel) removeOnlineNovel(name string) error {
-	if strings.TrimSpace(name) == "" {
-		return fmt.Errorf("novel name is empty")
+func (m *LibraryModel) removeOnlineNovel(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("novel id is empty")
 	}
-
-	cachePath := library.NovelCachePath(name)
-	if err := os.RemoveAll(cachePath); err != nil {
+	freed, err := library.RemoveNovelCache(id)
+	if err != nil {
 		return err
 	}
+	m.setReclaimed(freed)
 
-	if err := utils.DeleteProgress(name, "online"); err != nil && !errors.Is(err, os.ErrNotExist) {
+	if err := utils.DeleteProgress(id); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
 
@@ -1754,6 +2101,9 @@ func (m *LibraryModel) removeOnlineNovel(name string) error {
 	m.updateSettingItem(SettingRemoveOnlineNovel, func(s *SettingItem) {
 		s.IntValue = len(novels)
 	})
+	m.updateSettingItem(SettingCacheUsage, func(s *SettingItem) {
+		s.Value = cacheUsageValue()
+	})
 
 	if err := m.reloadLibraryFromConfig(); err != nil {
 		return err
@@ -1769,7 +2119,7 @@ func (m *LibraryModel) removeOnlineNovel(name string) error {
 func (m *LibraryModel) upsertOnlineNovel(novel library.Novel) {
 	updated := false
 	for i := range m.onlineNovels {
-		if m.onlineNovels[i].Name == novel.Name {
+		if m.onlineNovels[i].ID == novel.ID {
 			m.onlineNovels[i] = novel
 			updated = true
 			break
@@ -1792,7 +2142,7 @@ func (m *LibraryModel) upsertHistoryNovel(novel library.Novel) {
 		if !ok {
 			continue
 		}
-		if existing.Name == novel.Name && existing.IsLocal == novel.IsLocal {
+		if existing.ID == novel.ID {
 			history.SetItem(i, novel)
 			m.lists[0] = history
 			return
@@ -1819,12 +2169,15 @@ func (m *LibraryModel) handleNovelOpened(novel library.Novel) {
 	m.updateSettingItem(SettingRemoveOnlineNovel, func(s *SettingItem) {
 		s.IntValue = len(m.onlineNovels)
 	})
+	m.updateSettingItem(SettingCacheUsage, func(s *SettingItem) {
+		s.Value = cacheUsageValue()
+	})
 
 	if err := m.reloadLibraryFromConfig(); err != nil {
 		fmt.Println("Failed to reload library:", err)
 		m.upsertHistoryNovel(novel)
 		if m.removeMode == SettingRemoveOnlineNovel && m.settingsState == settingsStateRemoving {
-			m.refreshRemoveOnlineList(novel.Name)
+			m.refreshRemoveOnlineList(novel.ID)
 		}
 		return
 	}
@@ -1833,15 +2186,14 @@ func (m *LibraryModel) handleNovelOpened(novel library.Novel) {
 	m.refreshHistoryList(progressMap)
 
 	if m.removeMode == SettingRemoveOnlineNovel && m.settingsState == settingsStateRemoving {
-		m.refreshRemoveOnlineList(novel.Name)
+		m.refreshRemoveOnlineList(novel.ID)
 	}
 }
 
+// reloadLibraryFromConfig shows the library folders and the books the last
+// scan found in them; books in a newly added folder need a scan to show.
 func (m *LibraryModel) reloadLibraryFromConfig() error {
-	localNovels, err := library.LoadLocalNovels()
-	if err != nil {
-		return err
-	}
+	localNovels := library.IndexedLocalNovels()
 
 	newLocalByRoot := library.GroupLocalNovelsByRoot(localNovels)
 
@@ -1900,6 +2252,7 @@ func (m *LibraryModel) reloadLibraryFromConfig() error {
 	m.lists[0] = historyList
 	m.lists[1] = libraryList
 	m.localByRoot = newLocalByRoot
+	m.localNovels = localNovels
 	m.bookshelfRootItems = rootItems
 
 	return nil
@@ -1916,11 +2269,8 @@ func pathsEqual(a, b string) bool {
 
 // ---------------- Initialization ----------------
 func NewLibraryModel() LibraryModel {
-	localNovels, err := library.LoadLocalNovels()
-	if err != nil {
-		fmt.Println("Error loading library:", err)
-		os.Exit(1)
-	}
+	// the books as last scanned; Init scans the folders again
+	localNovels := library.IndexedLocalNovels()
 
 	onlineNovels, onlineErr := library.LoadAllCachedNovels()
 	if onlineErr != nil && !os.IsNotExist(onlineErr) {
@@ -1930,19 +2280,7 @@ func NewLibraryModel() LibraryModel {
 	novels := append(localNovels, onlineNovels...)
 
 	progressMap, _ := utils.Load()
-	for i := range novels {
-		source := "local"
-		if !novels[i].IsLocal {
-			source = "online"
-		}
-		if p, ok := utils.GetProgress(progressMap, novels[i].Name, source); ok {
-			if !p.LastRead.IsZero() {
-				novels[i].Modified = p.LastRead
-			}
-			novels[i].Current = p.LastChapter
-		}
-	}
-
+	applyNovelProgress(novels, progressMap)
 	sortNovelsByLastRead(novels, progressMap)
 
 	localList := make([]list.Item, len(novels))
@@ -2033,6 +2371,9 @@ func NewLibraryModel() LibraryModel {
 		discoveryInput:     ti,
 		language:           lang.CurrentLocale(),
 		localByRoot:        localByRoot,
+		localNovels:        localNovels,
+		scanGen:            1,
+		watcher:            library.WatchLibrary(utils.AppConfig.Library.Paths),
 		onlineNovels:       sortedOnline,
 		bookshelfRootItems: append([]BookshelfItem(nil), rootItems...),
 	}
@@ -2043,6 +2384,18 @@ func NewLibraryModel() LibraryModel {
 	return model
 }
 
+// applyNovelProgress sets when each novel was last read and where.
+func applyNovelProgress(novels []library.Novel, progress map[string]utils.Progress) {
+	for i := range novels {
+		if p, ok := utils.GetProgress(progress, novels[i].ID); ok {
+			if !p.LastRead.IsZero() {
+				novels[i].Modified = p.LastRead
+			}
+			novels[i].Current = p.LastChapter
+		}
+	}
+}
+
 // ---------------- Sorting utility ----------------
 func sortNovelsByLastRead(novels []library.Novel, progress map[string]utils.Progress) {
 	sort.SliceStable(novels, func(i, j int) bool {
@@ -2062,11 +2415,7 @@ func sortNovelsByLastRead(novels []library.Novel, progress map[string]utils.Prog
 }
 
 func lastReadFor(n library.Novel, progress map[string]utils.Progress) (time.Time, bool) {
-	source := "local"
-	if !n.IsLocal {
-		source = "online"
-	}
-	if p, ok := utils.GetProgress(progress, n.Name, source); ok {
+	if p, ok := utils.GetProgress(progress, n.ID); ok {
 		if !p.LastRead.IsZero() {
 			return p.LastRead, true
 		}
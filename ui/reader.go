@@ -1,9 +1,8 @@
 package ui
 
 import (
+	"fmt"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -16,34 +15,68 @@ import (
 	"novel_reader/utils"
 )
 
-var chapterPattern = regexp.MustCompile(`^第[0-9一二三四五六七八九十百千~-]+章(?:\s*：?\s*.*)?$`)
-
 type Chapter struct {
 	Title string
 	Line  int // Line number in Content where chapter starts
+	Depth int // nesting in the book's own TOC, 0 at the top
 }
 
 type TOCChapter struct {
+	Title       string
+	Index       int
+	Depth       int
+	Unavailable bool // not cached while offline
+}
+
+// TOCVolume groups the chapters of AllChapters from First up to the next
+// volume.
+type TOCVolume struct {
 	Title string
-	Index int
+	First int // position in AllChapters
 }
 
 type ReaderModel struct {
+	BookID         string // key for progress and the online cache
 	Name           string
+	Path           string // local book file; empty for online books
 	CacheDir       string
 	Content        []string
 	TOC            []Chapter
 	TotalChapters  int
 	AllChapters    []TOCChapter
+	Volumes        []TOCVolume
 	LoadedIndices  []int
 	Loading        bool
 	LoadingText    string
+	Err            error  // failed chapter load, shown with recovery actions
+	ErrChapter     int    // actual index of the chapter that failed
+	Notice         string // one-line status under the error, e.g. report saved
 	currentChapter int
 	Width          int
 	Height         int
 	Page           int
 	Style          gloss.Style
 	Source         string // <-- add this
+
+	saved       progressMark // where progress was last saved
+	savePending bool         // a progressSaveMsg is on its way
+}
+
+// Progress is saved at once when the chapter changes and otherwise at most
+// every progressSaveDelay while paging, and when the reader is left;
+// progress.json is written atomically, which is too slow for every key.
+const progressSaveDelay = 2 * time.Second
+
+// progressMark is a saved reading position.
+type progressMark struct {
+	ok      bool
+	chapter int
+	page    int
+}
+
+// progressSaveMsg asks the reader of BookID to save its progress.
+type progressSaveMsg struct {
+	BookID string
 }
 
 func (m ReaderModel) Init() tea.Cmd { return nil }
@@ -55,6 +88,9 @@ func (m ReaderModel) Update(msg tea.Msg) (ReaderModel, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.Err == nil {
+			m.Notice = "" // shown until the next key
+		}
 		switch msg.String() {
 		case "right", "l", "j":
 			_, lastIndex, _ := m.displayedContent()
@@ -96,31 +132,25 @@ func (m ReaderModel) Update(msg tea.Msg) (ReaderModel, tea.Cmd) {
 		m.Style = ReaderStyle(m.Width)
 	}
 
-	// Save progress using correct source
-	progressMap, _ := utils.Load()
-	lastChapter := ""
-	if len(m.TOC) > 0 && m.currentChapter < len(m.TOC) {
-		lastChapter = m.TOC[m.currentChapter].Title
-	}
-
 	currentActual := m.ActualChapterIndex()
-
-	utils.SetProgress(progressMap, m.Name, m.Source, utils.Progress{
-		Chapter:     currentActual,
-		Page:        m.Page,
-		LastRead:    time.Now(),
-		LastChapter: lastChapter,
-		Source:      m.Source,
-	})
-	utils.Save(progressMap)
+	switch {
+	case !m.saved.ok || currentActual != prevActual:
+		m.SaveProgress()
+	case m.progressChanged() && !m.savePending:
+		m.savePending = true
+		id := m.BookID
+		cmds = append(cmds, tea.Tick(progressSaveDelay, func(time.Time) tea.Msg {
+			return progressSaveMsg{BookID: id}
+		}))
+	}
 
 	if currentActual != prevActual && m.Source == "online" {
 		chapter := currentActual
 		cmds = append(cmds, func() tea.Msg {
 			return chapterChangedMsg{
-				NovelName: m.Name,
-				Source:    m.Source,
-				Chapter:   chapter,
+				BookID:  m.BookID,
+				Source:  m.Source,
+				Chapter: chapter,
 			}
 		})
 	}
@@ -128,7 +158,61 @@ func (m ReaderModel) Update(msg tea.Msg) (ReaderModel, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// progressChanged reports whether the position moved since it was saved.
+func (m ReaderModel) progressChanged() bool {
+	return m.saved != progressMark{ok: true, chapter: m.ActualChapterIndex(), page: m.Page}
+}
+
+// SaveProgress saves the reading position using the correct source. A
+// progress file that fails to load is left alone rather than overwritten
+// with just this book.
+func (m *ReaderModel) SaveProgress() {
+	m.savePending = false
+	if m.BookID == "" {
+		return
+	}
+	lastChapter := ""
+	if len(m.TOC) > 0 && m.currentChapter < len(m.TOC) {
+		lastChapter = m.TOC[m.currentChapter].Title
+	}
+
+	currentActual := m.ActualChapterIndex()
+
+	if progressMap, err := utils.Load(); err == nil {
+		utils.SetProgress(progressMap, m.BookID, utils.Progress{
+			Title:       m.Name,
+			Chapter:     currentActual,
+			Page:        m.Page,
+			LastRead:    time.Now(),
+			LastChapter: lastChapter,
+			Source:      m.Source,
+		})
+		_ = utils.Save(progressMap)
+	}
+	m.saved = progressMark{ok: true, chapter: currentActual, page: m.Page}
+}
+
+// FlushProgress saves the position if it moved since the last save.
+func (m *ReaderModel) FlushProgress() {
+	m.savePending = false
+	if m.progressChanged() {
+		m.SaveProgress()
+	}
+}
+
 func (m ReaderModel) View() string {
+	if m.Err != nil {
+		text := m.TitleForActual(m.ErrChapter) + "\n\n" + errorText(m.Err)
+		hints := actionHints(m.RecoveryActions())
+		if hints != "" {
+			text += "\n\n" + hints
+		}
+		text += "  [esc] " + lang.Active().Errors.ActionBack
+		if m.Notice != "" {
+			text += "\n\n" + m.Notice
+		}
+		return ReaderErrorStyle.Width(m.Width).Render(text)
+	}
 	if m.Loading {
 		text := m.LoadingText
 		if strings.TrimSpace(text) == "" {
@@ -139,24 +223,60 @@ func (m ReaderModel) View() string {
 
 	lines, _, _ := m.displayedContent()
 	visible := strings.Join(lines, strings.Repeat("\n", utils.AppConfig.Reader.LineSpacing+1))
+	header := m.Notice
+	if v, n, title := m.VolumePosition(); v > 0 && header == "" {
+		header = fmt.Sprintf(lang.Active().Reader.VolumeTemplate, v, n, title)
+	}
+	if header != "" {
+		// the volume, or a notice, goes in the padding row above the text
+		header = runewidth.Truncate(header, max(m.Width-4, 10), "…")
+		return m.Style.PaddingTop(0).Render(MutedTextStyle.Render(header) + "\n" + visible)
+	}
 	return m.Style.Render(visible)
 }
 
-func parseTOC(lines []string) []Chapter {
+// parseTOC finds the chapter headings in lines with the book's chapter
+// rules, see utils.ChapterRulesFor. Volume headings are not chapters but
+// group the chapters after them; the first of those starts at the volume
+// heading so its text is not lost. A volume with no chapters is kept as a
+// chapter.
+func parseTOC(lines []string, rules *utils.ChapterRules) ([]Chapter, []TOCVolume) {
 	var toc []Chapter
+	var volumes []TOCVolume
+	pending := -1 // line of a volume heading no chapter followed yet
+	flush := func() {
+		if pending >= 0 {
+			toc = append(toc, Chapter{Title: strings.TrimSpace(lines[pending]), Line: pending})
+			pending = -1
+		}
+	}
 	for i, line := range lines {
-		// English-style chapters
-		if strings.HasPrefix(line, "Chapter ") {
-			toc = append(toc, Chapter{Title: line, Line: i})
+		if !rules.Match(line) {
 			continue
 		}
-
-		// Chinese-style chapters like "第12章" or "第一百二十三章"
-		if chapterPattern.MatchString(line) {
-			toc = append(toc, Chapter{Title: line, Line: i})
+		volume, chapter, isVolume := rules.SplitVolume(line)
+		switch {
+		case !isVolume:
+			start := i
+			if pending >= 0 {
+				volumes = append(volumes, TOCVolume{Title: strings.TrimSpace(lines[pending]), First: len(toc)})
+				start, pending = pending, -1
+			}
+			toc = append(toc, Chapter{Title: strings.TrimSpace(line), Line: start})
+		case chapter == "":
+			flush()
+			pending = i
+		default:
+			// "第一卷 第三章": a new volume only when the prefix changes
+			flush()
+			if len(volumes) == 0 || !strings.HasPrefix(volumes[len(volumes)-1].Title, volume) {
+				volumes = append(volumes, TOCVolume{Title: volume, First: len(toc)})
+			}
+			toc = append(toc, Chapter{Title: chapter, Line: i})
 		}
 	}
-	return toc
+	flush()
+	return toc, volumes
 }
 
 // Return how many terminal rows a line takes when wrapped.
@@ -227,9 +347,31 @@ func (m ReaderModel) displayedContent() ([]string, int, int) {
 	return m.displayedContentFrom(pageStart, end)
 }
 
-func NewReaderModel(filePath, name string, source string) ReaderModel {
-	lines := utils.ExtractContent(filePath)
-	toc := parseTOC(lines)
+func NewReaderModel(filePath, id, name string, source string) ReaderModel {
+	var lines []string
+	var toc []Chapter
+	var volumes []TOCVolume
+	book, err := library.LoadLocalBook(filePath)
+	if err != nil {
+		lines = []string{errorText(err)}
+	} else {
+		lines = book.Lines
+	}
+	if _, ownRules := utils.BookChapterRules(id); book.TOC != nil && !ownRules {
+		// formats with real navigation (EPUB …) bring their own TOC, unless
+		// the book has chapter rules of its own
+		for _, ch := range book.TOC {
+			toc = append(toc, Chapter{Title: ch.Title, Line: ch.Line, Depth: ch.Depth})
+		}
+	} else {
+		toc, volumes = parseTOC(lines, utils.ChapterRulesFor(id, lines))
+	}
+	if len(toc) == 0 {
+		// no headings: split the text some other way, see utils.SplitSections
+		for _, s := range utils.SplitSections(lines) {
+			toc = append(toc, Chapter{Title: s.Title, Line: s.Line})
+		}
+	}
 	if len(toc) == 0 {
 		toc = []Chapter{{Title: "", Line: 0}}
 	}
@@ -238,7 +380,7 @@ func NewReaderModel(filePath, name string, source string) ReaderModel {
 	allChapters := make([]TOCChapter, len(toc))
 	for i, ch := range toc {
 		loadedIndices[i] = i
-		allChapters[i] = TOCChapter{Title: ch.Title, Index: i}
+		allChapters[i] = TOCChapter{Title: ch.Title, Index: i, Depth: ch.Depth}
 	}
 
 	// Default values
@@ -248,7 +390,7 @@ func NewReaderModel(filePath, name string, source string) ReaderModel {
 
 	// Load saved progress using correct source
 	progressMap, _ := utils.Load()
-	if p, ok := utils.GetProgress(progressMap, name, source); ok {
+	if p, ok := utils.GetProgress(progressMap, id); ok {
 		currentChapter = p.Chapter
 		page = p.Page
 		if currentChapter < 0 || currentChapter >= len(toc) {
@@ -262,12 +404,15 @@ func NewReaderModel(filePath, name string, source string) ReaderModel {
 	return ReaderModel{
 		Content:        lines,
 		TOC:            toc,
+		Path:           filePath,
 		CacheDir:       cacheDir,
 		TotalChapters:  len(allChapters),
 		AllChapters:    allChapters,
+		Volumes:        volumes,
 		LoadedIndices:  loadedIndices,
 		currentChapter: currentChapter,
 		Page:           page,
+		BookID:         id,
 		Name:           name,
 		Source:         source, // <-- set source here
 	}
@@ -312,91 +457,100 @@ func (m ReaderModel) totalPagesInChapter(chapIndex int) int {
 	return pages
 }
 
-func NewReaderModelFromFiles(files []string, name string, source string) ReaderModel {
-	cacheDir := ""
-	if len(files) > 0 {
-		cacheDir = filepath.Dir(files[0])
-	}
-
+// NewReaderModelFromCache opens an online novel from its packed chapter
+// store. Only cached chapters are loaded; the TOC lists the whole book.
+func NewReaderModelFromCache(id, name string) ReaderModel {
 	var allLines []string
 	var toc []Chapter
 	var loadedIndices []int
 	var allChapters []TOCChapter
-
-	if source == "online" {
-		chapterList, _ := library.LoadChapterList(name)
-		indexToTitle := make(map[int]string, len(chapterList))
-		for _, ch := range chapterList {
-			indexToTitle[ch.Index] = strings.TrimSpace(ch.Title)
-			title := strings.TrimSpace(ch.Title)
-			if title == "" {
-				title = lang.ChapterTitle(ch.Index)
-			}
-			allChapters = append(allChapters, TOCChapter{
-				Title: title,
-				Index: ch.Index - 1,
-			})
-		}
-
-		for _, f := range files {
-			lines := utils.ExtractContent(f)
-			start := len(allLines)
-			allLines = append(allLines, lines...)
-
-			title := ""
-			actualIndex := len(loadedIndices)
-			base := filepath.Base(f)
-			if num, err := strconv.Atoi(strings.TrimSuffix(base, ".txt")); err == nil {
-				actualIndex = num - 1
-				if t, ok := indexToTitle[num]; ok && t != "" {
-					title = t
-				}
-			}
-			if title == "" {
-				for _, line := range lines {
-					trimmed := strings.TrimSpace(line)
-					if trimmed != "" {
-						title = trimmed
-						break
-					}
-				}
-			}
-			if title == "" {
-				title = lang.ChapterTitle(len(toc) + 1)
-			}
-
-			loadedIndices = append(loadedIndices, actualIndex)
-			toc = append(toc, Chapter{Title: title, Line: start})
+	var volumes []TOCVolume
+
+	chapterList, _ := library.LoadChapterList(id)
+	indexToTitle := make(map[int]string, len(chapterList))
+	for _, ch := range chapterList {
+		indexToTitle[ch.Index] = strings.TrimSpace(ch.Title)
+		title := strings.TrimSpace(ch.Title)
+		if title == "" {
+			title = lang.ChapterTitle(ch.Index)
 		}
-
-		if len(toc) == 0 {
-			toc = []Chapter{{Title: "", Line: 0}}
-			if len(loadedIndices) == 0 {
-				loadedIndices = append(loadedIndices, 0)
-			}
+		if ch.Volume != "" && (len(volumes) == 0 || volumes[len(volumes)-1].Title != ch.Volume) {
+			volumes = append(volumes, TOCVolume{Title: ch.Volume, First: len(allChapters)})
 		}
-
-		if len(allChapters) == 0 {
-			for i := range toc {
-				allChapters = append(allChapters, TOCChapter{Title: toc[i].Title, Index: i})
+		allChapters = append(allChapters, TOCChapter{
+			Title: title,
+			Index: ch.Index - 1,
+		})
+	}
+	markUnavailable(id, allChapters)
+
+	chapters, _ := library.LoadCachedChapters(id)
+	for _, ch := range chapters {
+		lines := utils.ExtractText([]byte(ch.Text))
+		start := len(allLines)
+		allLines = append(allLines, lines...)
+
+		title := indexToTitle[ch.Index]
+		if title == "" {
+			for _, line := range lines {
+				trimmed := strings.TrimSpace(line)
+				if trimmed != "" {
+					title = trimmed
+					break
+				}
 			}
 		}
-	} else {
-		for _, f := range files {
-			lines := utils.ExtractContent(f)
-			allLines = append(allLines, lines...)
+		if title == "" {
+			title = lang.ChapterTitle(len(toc) + 1)
 		}
 
-		toc = parseTOC(allLines)
-		if len(toc) == 0 {
-			toc = []Chapter{{Title: "", Line: 0}}
-		}
-		for i := range toc {
-			loadedIndices = append(loadedIndices, i)
-			allChapters = append(allChapters, TOCChapter{Title: toc[i].Title, Index: i})
+		loadedIndices = append(loadedIndices, ch.Index-1)
+		toc = append(toc, Chapter{Title: title, Line: start})
+	}
+
+	if len(toc) == 0 {
+		toc = []Chapter{{Title: "", Line: 0}}
+		if len(loadedIndices) == 0 {
+			loadedIndices = append(loadedIndices, 0)
 		}
 	}
 
+	m := newReaderModel(allLines, toc, loadedIndices, allChapters, library.NovelCachePath(id), id, name, "online")
+	m.Volumes = volumes
+	return m
+}
+
+func NewReaderModelFromFiles(files []string, id, name string, source string) ReaderModel {
+	cacheDir := ""
+	if len(files) > 0 {
+		cacheDir = filepath.Dir(files[0])
+	}
+
+	var allLines []string
+	for _, f := range files {
+		lines := utils.ExtractContent(f)
+		allLines = append(allLines, lines...)
+	}
+
+	toc, volumes := parseTOC(allLines, utils.ChapterRulesFor(id, allLines))
+	if len(toc) == 0 {
+		toc = []Chapter{{Title: "", Line: 0}}
+	}
+	var loadedIndices []int
+	var allChapters []TOCChapter
+	for i := range toc {
+		loadedIndices = append(loadedIndices, i)
+		allChapters = append(allChapters, TOCChapter{Title: toc[i].Title, Index: i})
+	}
+
+	m := newReaderModel(allLines, toc, loadedIndices, allChapters, cacheDir, id, name, source)
+	m.Volumes = volumes
+	return m
+}
+
+// newReaderModel fills in missing chapter bookkeeping and restores the
+// saved position. Progress stores the actual chapter index.
+func newReaderModel(allLines []string, toc []Chapter, loadedIndices []int, allChapters []TOCChapter, cacheDir, id, name, source string) ReaderModel {
 	if len(loadedIndices) == 0 {
 		for i := range toc {
 			loadedIndices = append(loadedIndices, i)
@@ -412,7 +566,7 @@ func NewReaderModelFromFiles(files []string, name string, source string) ReaderM
 	page := 0
 
 	progressMap, _ := utils.Load()
-	if p, ok := utils.GetProgress(progressMap, name, source); ok {
+	if p, ok := utils.GetProgress(progressMap, id); ok {
 		currentChapter = p.Chapter
 		page = p.Page
 		if currentChapter < 0 {
@@ -448,6 +602,7 @@ func NewReaderModelFromFiles(files []string, name string, source string) ReaderM
 		LoadedIndices:  loadedIndices,
 		currentChapter: currentChapter,
 		Page:           page,
+		BookID:         id,
 		Name:           name,
 		Source:         source,
 	}
@@ -482,6 +637,35 @@ func (m *ReaderModel) SetCurrentByActual(actual int) {
 	}
 }
 
+// VolumePosition returns the number of the volume the current chapter is in,
+// counting from 1, how many volumes there are and the volume's title. The
+// number is 0 outside volumes.
+func (m ReaderModel) VolumePosition() (int, int, string) {
+	actual := m.ActualChapterIndex()
+	for pos, ch := range m.AllChapters {
+		if ch.Index == actual {
+			if v := volumeAt(m.Volumes, pos); v >= 0 {
+				return v + 1, len(m.Volumes), m.Volumes[v].Title
+			}
+			break
+		}
+	}
+	return 0, len(m.Volumes), ""
+}
+
+// volumeAt returns the volume holding the chapter at pos in AllChapters, or
+// -1 for chapters before the first volume.
+func volumeAt(volumes []TOCVolume, pos int) int {
+	v := -1
+	for i, vol := range volumes {
+		if vol.First > pos {
+			break
+		}
+		v = i
+	}
+	return v
+}
+
 func (m ReaderModel) TitleForActual(actual int) string {
 	for _, ch := range m.AllChapters {
 		if ch.Index == actual && strings.TrimSpace(ch.Title) != "" {
@@ -496,3 +680,58 @@ func (m ReaderModel) WithLoading(loading bool, text string) ReaderModel {
 	m.LoadingText = text
 	return m
 }
+
+// WithError shows err for the given chapter; a nil err clears it.
+func (m ReaderModel) WithError(err error, actual int) ReaderModel {
+	m.Err = err
+	m.ErrChapter = actual
+	m.Notice = ""
+	return m
+}
+
+// RecoveryActions returns the actions the reader can offer for its error.
+func (m ReaderModel) RecoveryActions() []library.RecoveryAction {
+	return availableActions(m.Err, func(a library.RecoveryAction) bool {
+		switch a {
+		case library.ActionOpenCached:
+			// fall back to the chapters that are already loaded
+			return len(m.Content) > 0
+		case library.ActionRetry, library.ActionSwitchSource:
+			return m.Source == "online"
+		}
+		return true
+	})
+}
+
+// markUnavailable marks the chapters of an online book that are not cached
+// while the network is off, and clears the marks when it is on.
+func markUnavailable(id string, chapters []TOCChapter) {
+	var cached map[int]bool
+	if library.NetworkDisabled() {
+		cached = library.CachedChapterIndices(id)
+	}
+	for i := range chapters {
+		chapters[i].Unavailable = cached != nil && !cached[chapters[i].Index+1]
+	}
+}
+
+// RefreshAvailability recomputes the unavailable marks after offline mode
+// was toggled.
+func (m *ReaderModel) RefreshAvailability() {
+	if m.Source != "online" || len(m.AllChapters) == 0 {
+		return
+	}
+	m.AllChapters = append([]TOCChapter(nil), m.AllChapters...)
+	markUnavailable(m.BookID, m.AllChapters)
+}
+
+// ChapterUnavailable reports whether the chapter cannot be opened because it
+// is not cached and the network is off.
+func (m ReaderModel) ChapterUnavailable(actual int) bool {
+	for _, ch := range m.AllChapters {
+		if ch.Index == actual {
+			return ch.Unavailable
+		}
+	}
+	return false
+}
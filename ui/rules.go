@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+
+	"novel_reader/lang"
+	"novel_reader/utils"
+)
+
+// RulesModel edits the chapter rules of one local book: regular
+// expressions, one per line, that replace the global chapter rules for it.
+// The TOC they would give is previewed while typing; nothing is saved before
+// ctrl+s.
+type RulesModel struct {
+	bookID  string
+	lines   []string
+	saved   []utils.ChapterRule // the book's rules when the editor opened
+	input   textarea.Model
+	preview []Chapter
+	err     error
+	width   int
+	height  int
+}
+
+// Messages used to hand control back to AppModel
+type rulesSavedMsg struct{}
+type rulesCancelMsg struct{}
+
+// rulesInputHeight is how many rules are in view at once.
+const rulesInputHeight = 4
+
+// NewRulesModel opens the editor on the book's current rules, if it has any.
+func NewRulesModel(bookID string, lines []string, width, height int) RulesModel {
+	ta := textarea.New()
+	ta.Prompt = lang.Active().Reader.RulesPrompt
+	ta.Placeholder = lang.Active().Reader.RulesPlaceholder
+	ta.ShowLineNumbers = false
+	ta.FocusedStyle.Prompt = PromptStyle
+	ta.FocusedStyle.Text = PromptTextStyle
+	ta.FocusedStyle.CursorLine = PromptTextStyle
+	ta.BlurredStyle = ta.FocusedStyle
+	ta.Cursor.Style = PromptCursorStyle
+	ta.Cursor.SetMode(cursor.CursorStatic)
+	ta.CharLimit = 0
+	ta.SetWidth(max(width-10, 20))
+	ta.SetHeight(rulesInputHeight)
+
+	saved, _ := utils.BookChapterRules(bookID)
+	patterns := make([]string, len(saved))
+	for i, r := range saved {
+		patterns[i] = r.Pattern
+	}
+	ta.SetValue(strings.Join(patterns, "\n"))
+	ta.Focus()
+
+	m := RulesModel{bookID: bookID, lines: lines, saved: saved, input: ta, width: width, height: height}
+	m.refresh()
+	return m
+}
+
+// rules returns what the input stands for: nil for the global rules. A rule
+// whose pattern was kept keeps its name and numbering flag.
+func (m RulesModel) rules() []utils.ChapterRule {
+	var rules []utils.ChapterRule
+	for _, line := range strings.Split(m.input.Value(), "\n") {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" {
+			continue
+		}
+		rule := utils.ChapterRule{Name: "book", Pattern: pattern}
+		for _, r := range m.saved {
+			if r.Pattern == pattern {
+				rule = r
+				break
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// refresh recomputes the preview for the current input.
+func (m *RulesModel) refresh() {
+	m.err = nil
+	rules := utils.ChapterRulesFor("", m.lines)
+	if own := m.rules(); own != nil {
+		compiled, err := utils.CompileChapterRules(own)
+		if err != nil {
+			m.err = err
+			m.preview = nil
+			return
+		}
+		rules = compiled
+	}
+	m.preview, _ = parseTOC(m.lines, rules)
+}
+
+func (m RulesModel) Init() tea.Cmd { return nil }
+
+func (m RulesModel) Update(msg tea.Msg) (RulesModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.input.SetWidth(max(m.width-10, 20))
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return rulesCancelMsg{} }
+		case "ctrl+s":
+			if m.err != nil {
+				return m, nil
+			}
+			if err := utils.SetBookChapterRules(m.bookID, m.rules()); err != nil {
+				m.err = err
+				return m, nil
+			}
+			return m, func() tea.Msg { return rulesSavedMsg{} }
+		}
+	}
+	before := m.input.Value()
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() != before {
+		m.refresh()
+	}
+	return m, cmd
+}
+
+func (m RulesModel) View() string {
+	texts := lang.Active().Reader
+	var b strings.Builder
+	b.WriteString(SelectedTitleStyle.Render(texts.RulesTitle))
+	b.WriteString("\n\n")
+	b.WriteString(m.input.View())
+	b.WriteString("\n\n")
+
+	status := fmt.Sprintf(texts.RulesCountTemplate, len(m.preview))
+	if strings.TrimSpace(m.input.Value()) == "" {
+		status += "  " + texts.RulesDefault
+	}
+	if m.err != nil {
+		status = errorText(m.err)
+	}
+	b.WriteString(StatusMutedStyle.Render(status))
+	b.WriteString("\n\n")
+
+	// header, status and hint take 7 rows besides the input
+	rows := max(m.height-9-rulesInputHeight, 1)
+	lineW := len(fmt.Sprint(len(m.lines)))
+	for i, ch := range m.preview {
+		if i == rows-1 && len(m.preview) > rows {
+			b.WriteString(NormalDescStyle.Render(fmt.Sprintf("… +%d", len(m.preview)-i)))
+			b.WriteString("\n")
+			break
+		}
+		title := runewidth.Truncate(ch.Title, max(m.width-lineW-8, 10), "…")
+		b.WriteString(NormalDescStyle.Render(fmt.Sprintf("%*d  ", lineW, ch.Line+1)))
+		b.WriteString(NormalTitleStyle.Render(title))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(StatusMutedStyle.Render(texts.RulesHint))
+	return gloss.NewStyle().PaddingTop(1).PaddingLeft(2).Render(b.String())
+}
@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"novel_reader/library"
+	"novel_reader/utils"
+)
+
+// The library starts out with the books as the last scan found them and is
+// scanned again in the background; books show up in the lists as the scan
+// reaches them, and when it ends the lists hold exactly what it found.
+
+// scanUpdateInterval is how often a running scan updates the lists.
+const scanUpdateInterval = 200 * time.Millisecond
+
+// libraryScanMsg carries the books a library scan has found so far, or all
+// of them once it is done.
+type libraryScanMsg struct {
+	gen     int // scans started before the current one are ignored
+	novels  []library.Novel
+	done    bool
+	err     error
+	updates <-chan libraryScanMsg
+}
+
+// scanLibraryCmd scans the library folders off the UI goroutine. Updates
+// that come while the UI is still busy with the last one are dropped; the
+// final message never is.
+func scanLibraryCmd(gen int) tea.Cmd {
+	updates := make(chan libraryScanMsg, 1)
+	go func() {
+		var found []library.Novel
+		last := time.Now()
+		novels, err := library.ScanLocalNovels(func(n library.Novel) {
+			found = append(found, n)
+			if time.Since(last) < scanUpdateInterval {
+				return
+			}
+			last = time.Now()
+			select {
+			case updates <- libraryScanMsg{gen: gen, novels: append([]library.Novel(nil), found...)}:
+			default:
+			}
+		})
+		updates <- libraryScanMsg{gen: gen, novels: novels, done: true, err: err}
+		close(updates)
+	}()
+	return waitLibraryScanCmd(updates)
+}
+
+func waitLibraryScanCmd(updates <-chan libraryScanMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-updates
+		if !ok {
+			return nil
+		}
+		msg.updates = updates
+		return msg
+	}
+}
+
+// startLibraryScan scans the library again, superseding a running scan.
+func (m *LibraryModel) startLibraryScan() tea.Cmd {
+	m.scanGen++
+	return scanLibraryCmd(m.scanGen)
+}
+
+func (m *LibraryModel) handleLibraryScan(tm libraryScanMsg) tea.Cmd {
+	var next tea.Cmd
+	if !tm.done {
+		next = waitLibraryScanCmd(tm.updates) // drain it even when stale
+	}
+	if tm.gen != m.scanGen {
+		return next
+	}
+	if tm.done {
+		if tm.err != nil {
+			return nil // keep what the index had
+		}
+		return m.setLocalNovels(tm.novels)
+	}
+	return tea.Batch(next, m.setLocalNovels(mergeLocalNovels(m.localNovels, tm.novels)))
+}
+
+// mergeLocalNovels returns found and the books of current it does not have
+// yet.
+func mergeLocalNovels(current, found []library.Novel) []library.Novel {
+	seen := make(map[string]bool, len(found))
+	merged := append([]library.Novel(nil), found...)
+	for _, n := range found {
+		seen[n.Path] = true
+	}
+	for _, n := range current {
+		if !seen[n.Path] {
+			merged = append(merged, n)
+		}
+	}
+	return merged
+}
+
+// setLocalNovels replaces the local books in the history list and the
+// bookshelf, keeping the selection where it was.
+func (m *LibraryModel) setLocalNovels(novels []library.Novel) tea.Cmd {
+	m.localNovels = novels
+	m.localByRoot = library.GroupLocalNovelsByRoot(novels)
+
+	combined := append(append([]library.Novel(nil), novels...), m.onlineNovels...)
+	progressMap, _ := utils.Load()
+	applyNovelProgress(combined, progressMap)
+	sortNovelsByLastRead(combined, progressMap)
+	cmd := setNovelItems(&m.lists[0], combined)
+
+	if m.bookshelfInFolder {
+		cmd = tea.Batch(cmd, setNovelItems(&m.lists[1], m.bookshelfNovels(m.bookshelfActiveRoot)))
+	}
+	m.resize(m.width, m.height) // the progress warning may have come or gone
+	return cmd
+}
+
+// setNovelItems shows novels in l, keeping the selected novel selected.
+func setNovelItems(l *list.Model, novels []library.Novel) tea.Cmd {
+	selected := ""
+	if n, ok := l.SelectedItem().(library.Novel); ok {
+		selected = n.ID
+	}
+	items := make([]list.Item, len(novels))
+	for i := range novels {
+		items[i] = novels[i]
+	}
+	cmd := l.SetItems(items)
+	if l.FilterState() != list.Unfiltered {
+		return cmd // the filter decides what is shown
+	}
+	for i, n := range novels {
+		if n.ID == selected {
+			l.Select(i)
+			break
+		}
+	}
+	return cmd
+}
+
+// libraryChangedMsg reports files that changed in the library folders, see
+// library.Watcher.
+type libraryChangedMsg struct {
+	Paths   []string
+	watcher *library.Watcher
+}
+
+func waitLibraryChangeCmd(w *library.Watcher) tea.Cmd {
+	return func() tea.Msg {
+		paths, ok := <-w.Changes
+		if !ok {
+			return nil
+		}
+		return libraryChangedMsg{Paths: paths, watcher: w}
+	}
+}
+
+// watchLibrary watches the library folders as configured now, in place of
+// the folders watched so far.
+func (m *LibraryModel) watchLibrary() tea.Cmd {
+	if m.watcher != nil {
+		m.watcher.Close()
+	}
+	m.watcher = library.WatchLibrary(utils.AppConfig.Library.Paths)
+	return waitLibraryChangeCmd(m.watcher)
+}
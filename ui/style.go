@@ -18,6 +18,11 @@ var ReaderLoadingStyle = gloss.NewStyle().
 	Padding(2).
 	Align(gloss.Center)
 
+var ReaderErrorStyle = gloss.NewStyle().
+	Foreground(gloss.Color("#f38ba8")).
+	Padding(2).
+	Align(gloss.Center)
+
 const (
 	TabSpacing    = 4
 	TabPaddingTop = 1
@@ -107,3 +112,12 @@ var (
 				PaddingTop(1).
 				Align(gloss.Center)
 )
+
+// One-line texts inside views, like hints under a list
+var (
+	MutedTextStyle = gloss.NewStyle().
+			Foreground(gloss.Color("#585b70"))
+
+	WarningTextStyle = gloss.NewStyle().
+				Foreground(gloss.Color("#f9e2af"))
+)
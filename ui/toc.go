@@ -2,40 +2,88 @@ package ui
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	gloss "github.com/charmbracelet/lipgloss"
 
 	"novel_reader/lang"
+	"novel_reader/utils"
 )
 
-// TOCModel wraps a bubbles list to display chapters.
+// TOCModel wraps a bubbles list to display chapters. Chapters in volumes
+// are listed under a row for the volume, which folds them away. Chapters
+// whose numbers are missing, repeated or out of order are marked, see
+// utils.AnalyzeChapterNumbers.
 type TOCModel struct {
 	list       list.Model
 	jumpBuffer string // accumulate number keys
+	chapters   []TOCChapter
+	volumes    []TOCVolume
+	folded     map[int]bool // by volume
+	numbering  utils.NumberingReport
+	warnings   map[int]string // by position in chapters
+	refill     bool           // missing chapters can be looked for at the source
+	notice     string
+	width      int
+	height     int
 }
 
 type TOCItem struct {
-	title string
-	index int // actual zero-based chapter index
+	title       string
+	index       int // actual zero-based chapter index; a volume's first chapter
+	depth       int
+	unavailable bool
+	volume      int  // volume the row is in, -1 for none
+	header      bool // the row of the volume itself
+	count       int  // chapters in the volume, for headers
+	folded      bool
+	warning     string
 }
 
-func (i TOCItem) Title() string       { return i.title }
+func (i TOCItem) Title() string {
+	if i.header {
+		marker := "▾ "
+		if i.folded {
+			marker = "▸ "
+		}
+		return marker + fmt.Sprintf(lang.Active().TOC.VolumeTemplate, i.title, i.count) + i.warning
+	}
+	title := strings.Repeat("  ", i.depth) + i.title
+	if i.unavailable {
+		title += lang.Active().TOC.Unavailable
+	}
+	return title + i.warning
+}
 func (i TOCItem) Description() string { return "" }
 func (i TOCItem) FilterValue() string { return i.title }
 
 // Messages used to communicate selection/cancel to the parent AppModel
 type TOCSelectMsg int
 type TOCCancelMsg struct{}
+type TOCRefillMsg struct{}
 
-// NewTOCModel builds a TOCModel from a []TOCChapter slice (actual indices).
-func NewTOCModel(toc []TOCChapter, width int, height int, selectedActual int) TOCModel {
-	items := make([]list.Item, len(toc))
-	selectedPos := 0
-	for i, ch := range toc {
-		items[i] = TOCItem{title: ch.Title, index: ch.Index}
+// NewTOCModel builds a TOCModel from a []TOCChapter slice (actual indices)
+// and the volumes grouping it. All volumes but the selected chapter's start
+// folded.
+func NewTOCModel(toc []TOCChapter, volumes []TOCVolume, width int, height int, selectedActual int) TOCModel {
+	m := TOCModel{chapters: toc, volumes: volumes, folded: make(map[int]bool), width: width, height: height}
+	m.checkNumbering()
+	current := -1
+	for pos, ch := range toc {
 		if ch.Index == selectedActual {
+			current = volumeAt(volumes, pos)
+			break
+		}
+	}
+	for v := range volumes {
+		m.folded[v] = v != current
+	}
+	items := m.items()
+	selectedPos := 0
+	for i, it := range items {
+		if it.(TOCItem).index == selectedActual && !it.(TOCItem).header {
 			selectedPos = i
 		}
 	}
@@ -50,7 +98,7 @@ func NewTOCModel(toc []TOCChapter, width int, height int, selectedActual int) TO
 
 	delegate.ShowDescription = false
 
-	l := list.New(items, delegate, width, height)
+	l := list.New(items, delegate, width, height-m.footerHeight())
 	l.SetShowHelp(false)
 	l.SetShowStatusBar(true)
 	l.Styles.StatusBar = gloss.NewStyle().
@@ -79,7 +127,178 @@ func NewTOCModel(toc []TOCChapter, width int, height int, selectedActual int) TO
 		l.Select(selectedPos)
 	}
 
-	return TOCModel{list: l}
+	m.list = l
+	return m
+}
+
+// items lists the rows: each volume's header, then its chapters unless it
+// is folded.
+func (m TOCModel) items() []list.Item {
+	var items []list.Item
+	v := -1
+	for pos, ch := range m.chapters {
+		for v+1 < len(m.volumes) && m.volumes[v+1].First <= pos {
+			v++
+			header := TOCItem{
+				title:  m.volumes[v].Title,
+				index:  ch.Index,
+				volume: v,
+				header: true,
+				count:  m.volumeSize(v),
+				folded: m.folded[v],
+			}
+			for p := m.volumes[v].First; p < m.volumes[v].First+header.count; p++ {
+				if m.warnings[p] != "" {
+					header.warning = " ⚠"
+					break
+				}
+			}
+			items = append(items, header)
+		}
+		if v >= 0 && m.folded[v] {
+			continue
+		}
+		depth := ch.Depth
+		if v >= 0 {
+			depth++
+		}
+		items = append(items, TOCItem{title: ch.Title, index: ch.Index, depth: depth, unavailable: ch.Unavailable, volume: v, warning: m.warnings[pos]})
+	}
+	return items
+}
+
+// checkNumbering marks the chapters whose numbers do not add up.
+func (m *TOCModel) checkNumbering() {
+	titles := make([]string, len(m.chapters))
+	for i, ch := range m.chapters {
+		titles[i] = ch.Title
+	}
+	starts := make([]int, len(m.volumes))
+	for i, v := range m.volumes {
+		starts[i] = v.First
+	}
+	m.numbering = utils.AnalyzeChapterNumbers(titles, starts)
+	m.warnings = make(map[int]string)
+	texts := lang.Active().TOC
+	for i, gap := range m.numbering.Missing {
+		numbers := fmt.Sprint(gap.From)
+		if gap.To > gap.From {
+			numbers += "–" + fmt.Sprint(gap.To)
+		}
+		m.warnings[m.numbering.MissingAt[i]] += fmt.Sprintf(texts.WarnMissingTemplate, numbers)
+	}
+	for _, pos := range m.numbering.Duplicates {
+		m.warnings[pos] += texts.WarnDuplicate
+	}
+	for _, pos := range m.numbering.Misordered {
+		m.warnings[pos] += texts.WarnMisordered
+	}
+}
+
+// volumeSize is how many chapters volume v holds.
+func (m TOCModel) volumeSize(v int) int {
+	end := len(m.chapters)
+	if v+1 < len(m.volumes) {
+		end = min(m.volumes[v+1].First, end)
+	}
+	return max(end-m.volumes[v].First, 0)
+}
+
+// refresh rebuilds the rows after folding and selects the first row for
+// which match is true.
+func (m *TOCModel) refresh(match func(TOCItem) bool) {
+	items := m.items()
+	m.list.SetItems(items)
+	for i, it := range items {
+		if match(it.(TOCItem)) {
+			m.list.Select(i)
+			return
+		}
+	}
+}
+
+// selectVolume folds nothing but moves to the header of volume v.
+func (m *TOCModel) selectVolume(v int) {
+	if v < 0 || v >= len(m.volumes) {
+		return
+	}
+	m.refresh(func(it TOCItem) bool { return it.header && it.volume == v })
+}
+
+// selectChapter moves to a chapter, unfolding its volume.
+func (m *TOCModel) selectChapter(actual int) {
+	for pos, ch := range m.chapters {
+		if ch.Index == actual {
+			if v := volumeAt(m.volumes, pos); v >= 0 {
+				m.folded[v] = false
+			}
+			break
+		}
+	}
+	m.refresh(func(it TOCItem) bool { return !it.header && it.index == actual })
+}
+
+// footer returns the lines under the list: the volume keys, what is wrong
+// with the numbering and the last notice.
+func (m TOCModel) footer() []string {
+	texts := lang.Active().TOC
+	var lines []string
+	if len(m.volumes) > 0 {
+		lines = append(lines, MutedTextStyle.Render(texts.VolumeHint))
+	}
+	if !m.numbering.OK() {
+		line := fmt.Sprintf(texts.NumberingTemplate, m.numbering.MissingCount(), len(m.numbering.Duplicates), len(m.numbering.Misordered))
+		if m.canRefill() {
+			line += "  " + texts.RefillHint
+		}
+		lines = append(lines, WarningTextStyle.Render(line))
+	}
+	if m.notice != "" {
+		lines = append(lines, MutedTextStyle.Render(m.notice))
+	}
+	return lines
+}
+
+// footerHeight is the rows the footer takes, with the blank row above it.
+func (m TOCModel) footerHeight() int {
+	if lines := m.footer(); len(lines) > 0 {
+		return len(lines) + 1
+	}
+	return 0
+}
+
+func (m TOCModel) canRefill() bool {
+	return m.refill && len(m.numbering.Missing) > 0
+}
+
+// WithRefill offers to look for missing chapters at the source, for online
+// books.
+func (m TOCModel) WithRefill() TOCModel {
+	m.refill = true
+	m.SetSize(m.width, m.height)
+	return m
+}
+
+// WithNotice shows a one-line notice under the list; "" clears it.
+func (m TOCModel) WithNotice(notice string) TOCModel {
+	m.notice = notice
+	m.SetSize(m.width, m.height)
+	return m
+}
+
+// SelectedActual returns the actual index of the chapter under the cursor,
+// or of the first chapter of the volume, and -1 when nothing is selected.
+func (m TOCModel) SelectedActual() int {
+	if item, ok := m.list.SelectedItem().(TOCItem); ok {
+		return item.index
+	}
+	return -1
+}
+
+// SetSize fits the list and the footer into width × height.
+func (m *TOCModel) SetSize(width, height int) {
+	m.width, m.height = width, height
+	m.list.SetSize(width, height-m.footerHeight())
 }
 
 func applyTOCStrings(l *list.Model) {
@@ -91,6 +310,12 @@ func applyTOCStrings(l *list.Model) {
 
 func (m *TOCModel) ApplyLanguage() {
 	applyTOCStrings(&m.list)
+	if m.folded == nil {
+		return // never opened
+	}
+	m.checkNumbering()
+	selected, _ := m.list.SelectedItem().(TOCItem)
+	m.refresh(func(it TOCItem) bool { return it.index == selected.index && it.header == selected.header })
 }
 
 func (m TOCModel) Init() tea.Cmd { return nil }
@@ -129,18 +354,71 @@ func (m TOCModel) Update(msg tea.Msg) (TOCModel, tea.Cmd) {
 					fmt.Sscanf(m.jumpBuffer, "%d", &idx)
 					idx--
 					if idx >= 0 {
-						for i, it := range m.list.Items() {
-							if tocItem, ok := it.(TOCItem); ok && tocItem.index == idx {
-								m.list.Select(i)
-								break
-							}
-						}
+						m.selectChapter(idx)
 					}
 					m.jumpBuffer = ""
 					return m, nil
 				}
 			}
 		}
+
+		if keyMsg.String() == "f" && m.canRefill() && m.list.FilterState() == list.Unfiltered {
+			return m, func() tea.Msg { return TOCRefillMsg{} }
+		}
+
+		if len(m.volumes) > 0 && m.list.FilterState() == list.Unfiltered {
+			item, ok := m.list.SelectedItem().(TOCItem)
+			if !ok {
+				item.volume = -1
+			}
+			switch keyMsg.String() {
+			case "v":
+				if m.jumpBuffer != "" {
+					var v int
+					fmt.Sscanf(m.jumpBuffer, "%d", &v)
+					m.jumpBuffer = ""
+					m.selectVolume(v - 1)
+				}
+				return m, nil
+			case " ":
+				if v := item.volume; v >= 0 {
+					m.folded[v] = !m.folded[v]
+					m.selectVolume(v)
+				}
+				return m, nil
+			case "c":
+				// fold all, or unfold all when everything is folded
+				all := true
+				for v := range m.volumes {
+					all = all && m.folded[v]
+				}
+				for v := range m.volumes {
+					m.folded[v] = !all
+				}
+				if item.volume >= 0 {
+					m.selectVolume(item.volume)
+				} else {
+					m.refresh(func(it TOCItem) bool { return it.index == item.index })
+				}
+				return m, nil
+			case "[":
+				v := item.volume
+				if item.header {
+					v--
+				}
+				m.selectVolume(v)
+				return m, nil
+			case "]":
+				m.selectVolume(item.volume + 1)
+				return m, nil
+			case "/":
+				// search every chapter, folded or not
+				for v := range m.volumes {
+					m.folded[v] = false
+				}
+				m.refresh(func(it TOCItem) bool { return it.index == item.index && it.header == item.header })
+			}
+		}
 	}
 
 	// If not handled, let list process the key normally
@@ -150,9 +428,13 @@ func (m TOCModel) Update(msg tea.Msg) (TOCModel, tea.Cmd) {
 }
 
 func (m TOCModel) View() string {
+	view := m.list.View()
+	if lines := m.footer(); len(lines) > 0 {
+		view += "\n\n" + strings.Join(lines, "\n")
+	}
 	// apply padding around the whole list
 	return gloss.NewStyle().
 		PaddingTop(1).
 		PaddingLeft(2).
-		Render(m.list.View())
+		Render(view)
 }
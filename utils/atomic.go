@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WriteFileAtomic writes data to a temp file next to path, syncs it and
+// renames it over path, so readers see either the old or the new content
+// and never a truncated file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// backedUp holds the files backupFile has copied this session.
+var (
+	backedUpMu sync.Mutex
+	backedUp   = make(map[string]bool)
+)
+
+// WriteFileWithBackup is WriteFileAtomic that first keeps the current file
+// as path+".bak", the last good copy ReadFileRecover falls back to. The
+// copy is made once per session, of the file as the session found it;
+// copying it on every write would double the cost of frequent saves.
+func WriteFileWithBackup(path string, data []byte, perm os.FileMode) error {
+	backedUpMu.Lock()
+	done := backedUp[path]
+	backedUpMu.Unlock()
+	if !done {
+		if _, err := os.Stat(path); err == nil {
+			if err := backupFile(path); err != nil {
+				return err
+			}
+			backedUpMu.Lock()
+			backedUp[path] = true
+			backedUpMu.Unlock()
+		}
+	}
+	return WriteFileAtomic(path, data, perm)
+}
+
+// ReadFileRecover reads path and checks it with valid. If the file exists but
+// is unreadable or invalid and path+".bak" passes valid, the backup is
+// restored in place and returned with recovered set. A missing file stays
+// missing; otherwise the original error is returned.
+func ReadFileRecover(path string, valid func([]byte) error) (data []byte, recovered bool, err error) {
+	data, err = os.ReadFile(path)
+	if err == nil {
+		if err = valid(data); err == nil {
+			return data, false, nil
+		}
+	} else if errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+
+	bak, bakErr := os.ReadFile(path + ".bak")
+	if bakErr != nil || valid(bak) != nil {
+		return data, false, err
+	}
+	if !ReadOnly() {
+		_ = WriteFileAtomic(path, bak, 0644)
+	}
+	return bak, true, nil
+}
+
+// backupFile copies path to path+".bak" atomically.
+func backupFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path+".bak", data, info.Mode().Perm())
+}
+
+// syncDir flushes a directory entry after a rename. Not every platform
+// supports it, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
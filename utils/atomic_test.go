@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFileRecover(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	valid := func(data []byte) error {
+		var v map[string]int
+		return json.Unmarshal(data, &v)
+	}
+	if err := os.WriteFile(path+".bak", []byte(`{"a":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, recovered, err := ReadFileRecover(path, valid); !errors.Is(err, os.ErrNotExist) || recovered {
+		t.Errorf("missing file: recovered %v, err %v; want os.ErrNotExist", recovered, err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file was restored from the backup")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"a":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, recovered, err := ReadFileRecover(path, valid)
+	if err != nil || !recovered || string(data) != `{"a":1}` {
+		t.Errorf("truncated file: got %q, recovered %v, err %v; want the backup", data, recovered, err)
+	}
+}
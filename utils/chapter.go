@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chapter headings in plain text are found with rules: regular expressions
+// matched against each trimmed line no longer than max_title_length. The
+// [chapters] section of config.toml replaces the built-in rules, and
+// [chapters.books] replaces them again for single books. Without either,
+// the built-in rules of the book's language apply, told by its script.
+// Headings of rules named "volume" group the chapters after them:
+//
+//	[chapters]
+//	max_title_length = 40
+//	[[chapters.rules]]
+//	name = "volume"
+//	pattern = '^第\s*[0-9]+\s*卷'
+//	[[chapters.rules]]
+//	name = "chapter"
+//	pattern = '^第\s*[0-9]+\s*章'
+//	[[chapters.books.l27c748752e542bb3]]
+//	pattern = '^\d+\.\s'
+//	[[chapters.books.l0e5a1c9f3b6d2e48]]
+//	pattern = '^Letter (\w+)'
+//	number = true
+
+// ChapterRule is one pattern heading lines may match. With Number set, the
+// first group of the pattern must also read as a chapter number, in digits,
+// Chinese, Roman or English words; see ParseChapterNumber.
+type ChapterRule struct {
+	Name    string `toml:"name,omitempty"`
+	Pattern string `toml:"pattern"`
+	Number  bool   `toml:"number,omitempty"`
+}
+
+// Chapter detection settings
+type ChapterConfig struct {
+	Rules          []ChapterRule            `toml:"rules,omitempty"`            // empty: BuiltinChapterRules
+	MaxTitleLength int                      `toml:"max_title_length,omitempty"` // in characters, 0 = default
+	SectionLength  int                      `toml:"section_length,omitempty"`   // in characters, see SplitSections
+	Books          map[string][]ChapterRule `toml:"books,omitempty"`            // by book ID
+}
+
+const defaultMaxTitleLength = 40
+
+// VolumeRule is the name of rules whose headings are volumes (卷, Part II)
+// rather than chapters.
+const VolumeRule = "volume"
+
+// chapterNumber is a chapter number in Arabic, full-width or Chinese
+// numerals, or a range like 1-2.
+const chapterNumber = `[0-9０-９零〇○一二三四五六七八九十百千万两壹贰叁肆伍陆柒捌玖拾佰仟~\-]+`
+
+// DefaultChapterRules are the built-in rules for Chinese, used when the
+// config has none and for text whose language cannot be told.
+func DefaultChapterRules() []ChapterRule {
+	return []ChapterRule{
+		{Name: VolumeRule, Pattern: `^第\s*` + chapterNumber + `\s*[卷部]`},
+		{Name: "chapter", Pattern: `^第\s*` + chapterNumber + `\s*[章节節回话話集篇幕]`},
+		{Name: "special", Pattern: `^(?:序章|序言|序幕|序|楔子|引子|引言|前言|尾声|尾聲|后记|後記|终章|終章|番外|外传|外傳|完本感言)(?:$|[\s:：·.．、\-—0-9０-９一二三四五六七八九十（(])`},
+		{Name: "english", Pattern: `^(?:Chapter|CHAPTER)\s+\S`},
+	}
+}
+
+// cjkNumber is a chapter number in Arabic, full-width or CJK numerals, as
+// Japanese and Korean novels write them.
+const cjkNumber = `[0-9０-９零〇一二三四五六七八九十百千万萬]+`
+
+// englishNumber is one word that may be a chapter number: digits, a Roman
+// numeral or number words like Twenty-One. Rules using it set Number so
+// "Chapter Meeting" is not taken for one.
+const englishNumber = `([0-9]+|[A-Za-z]+(?:-[A-Za-z]+)?)`
+
+// BuiltinChapterRules are the rules used for text in lang when the config
+// has none.
+func BuiltinChapterRules(lang TextLanguage) []ChapterRule {
+	switch lang {
+	case LanguageJapanese:
+		return []ChapterRule{
+			{Name: VolumeRule, Pattern: `^第\s*` + cjkNumber + `\s*[部巻卷]`},
+			{Name: "chapter", Pattern: `^第\s*` + cjkNumber + `\s*[話话章幕節节]`},
+			{Name: "special", Pattern: `^(?:プロローグ|エピローグ|幕間|閑話|番外編|序章|終章|間章|あとがき)(?:$|[\s:：・.．、\-—0-9０-９一二三四五六七八九十（(「])`},
+		}
+	case LanguageKorean:
+		return []ChapterRule{
+			{Name: VolumeRule, Pattern: `^제\s*[0-9]+\s*[부권]`},
+			{Name: "chapter", Pattern: `^(?:제\s*[0-9]+\s*[화장]|[0-9]+\s*화)(?:$|[\s.:\-—])`},
+			{Name: "special", Pattern: `^(?:프롤로그|에필로그|외전|막간|후기|서장|종장)(?:$|[\s:.\-—0-9(])`},
+		}
+	case LanguageEnglish:
+		return []ChapterRule{
+			{Name: VolumeRule, Pattern: `^(?i:part|book|volume|vol\.)\s+` + englishNumber + `(?:$|[\s.:\-—])`, Number: true},
+			{Name: "chapter", Pattern: `^(?i:chapter|chap\.?|ch\.)\s*` + englishNumber + `(?:$|[\s.:\-—])`, Number: true},
+			{Name: "special", Pattern: `^(?i:prologue|epilogue|interlude|afterword|foreword|preface|introduction)(?:$|[\s.:\-—])`},
+		}
+	}
+	return DefaultChapterRules()
+}
+
+// TextLanguage is the language chapter headings are looked for in.
+type TextLanguage string
+
+const (
+	LanguageChinese  TextLanguage = "zh"
+	LanguageJapanese TextLanguage = "ja"
+	LanguageKorean   TextLanguage = "ko"
+	LanguageEnglish  TextLanguage = "en"
+)
+
+// languageSample is how many letters DetectTextLanguage looks at.
+const languageSample = 20000
+
+// DetectTextLanguage tells the language of lines from the scripts of their
+// letters: kana means Japanese, since Japanese text mixes it with kanji;
+// mostly hangul means Korean; any real share of han means Chinese, and
+// latin letters alone English. Text without letters is taken for Chinese.
+func DetectTextLanguage(lines []string) TextLanguage {
+	var han, kana, hangul, latin int
+	seen := 0
+	for _, line := range lines {
+		for _, r := range line {
+			switch {
+			case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+				kana++
+			case unicode.Is(unicode.Hangul, r):
+				hangul++
+			case unicode.Is(unicode.Han, r):
+				han++
+			case r < utf8.RuneSelf && unicode.IsLetter(r):
+				latin++
+			default:
+				continue
+			}
+			seen++
+		}
+		if seen >= languageSample {
+			break
+		}
+	}
+	switch {
+	case seen == 0:
+		return LanguageChinese
+	case kana > 0 && kana*10 >= han+kana:
+		return LanguageJapanese
+	case hangul > han+kana:
+		return LanguageKorean
+	case (han+kana+hangul)*4 >= latin:
+		return LanguageChinese
+	}
+	return LanguageEnglish
+}
+
+// ChapterRules are compiled rules, ready to match lines.
+type ChapterRules struct {
+	patterns []chapterPattern
+	maxLen   int
+}
+
+type chapterPattern struct {
+	re     *regexp.Regexp
+	number bool // group 1 must be a chapter number
+	volume bool // from a rule named "volume"
+}
+
+// CompileChapterRules compiles rules, failing on the first bad pattern.
+// Empty patterns are skipped.
+func CompileChapterRules(rules []ChapterRule) (*ChapterRules, error) {
+	maxLen := AppConfig.Chapters.MaxTitleLength
+	if maxLen <= 0 {
+		maxLen = defaultMaxTitleLength
+	}
+	compiled := &ChapterRules{maxLen: maxLen}
+	for _, r := range rules {
+		if strings.TrimSpace(r.Pattern) == "" {
+			continue
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = r.Pattern
+			}
+			return nil, fmt.Errorf("chapter rule %s: %w", name, err)
+		}
+		if r.Number && re.NumSubexp() == 0 {
+			return nil, fmt.Errorf("chapter rule %s: number needs a group in the pattern", r.Name)
+		}
+		compiled.patterns = append(compiled.patterns, chapterPattern{re: re, number: r.Number, volume: r.Name == VolumeRule})
+	}
+	return compiled, nil
+}
+
+// Match reports whether line is a chapter heading.
+func (r *ChapterRules) Match(line string) bool {
+	return r.match(line) != nil
+}
+
+// IsVolume reports whether line is a heading matched by a rule named
+// VolumeRule, which groups the chapters after it.
+func (r *ChapterRules) IsVolume(line string) bool {
+	p := r.match(line)
+	return p != nil && p.volume
+}
+
+// SplitVolume splits a volume heading into the volume and the chapter
+// heading after it, as in "第一卷 第三章 风起". chapter is empty when the
+// line heads the volume alone.
+func (r *ChapterRules) SplitVolume(line string) (volume, chapter string, ok bool) {
+	line = strings.TrimSpace(line)
+	p := r.match(line)
+	if p == nil || !p.volume {
+		return "", "", false
+	}
+	end := p.re.FindStringIndex(line)[1]
+	if rest := strings.TrimSpace(line[end:]); rest != "" {
+		if q := r.match(rest); q != nil && !q.volume {
+			return strings.TrimSpace(line[:end]), rest, true
+		}
+	}
+	return line, "", true
+}
+
+// match returns the first pattern line matches, or nil.
+func (r *ChapterRules) match(line string) *chapterPattern {
+	line = strings.TrimSpace(line)
+	if line == "" || utf8.RuneCountInString(line) > r.maxLen {
+		return nil
+	}
+	for i, p := range r.patterns {
+		if !p.number {
+			if p.re.MatchString(line) {
+				return &r.patterns[i]
+			}
+			continue
+		}
+		if m := p.re.FindStringSubmatch(line); m != nil {
+			if _, ok := ParseChapterNumber(m[1]); ok {
+				return &r.patterns[i]
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	chapterRulesMu    sync.Mutex
+	chapterRulesCache = make(map[string]*ChapterRules)
+)
+
+// ChapterRulesFor returns the rules for the book with the given ID, or the
+// global ones for "". Rules from the config that do not compile are left
+// out; if none are left, the built-in rules for the language of lines
+// apply. lines may be nil, which means Chinese.
+func ChapterRulesFor(id string, lines []string) *ChapterRules {
+	rules := AppConfig.Chapters.Rules
+	if own, ok := AppConfig.Chapters.Books[id]; ok && id != "" {
+		rules = own
+	}
+	lang := LanguageChinese
+	if len(rules) == 0 && lines != nil {
+		lang = DetectTextLanguage(lines)
+	}
+	key := id + "/" + string(lang)
+
+	chapterRulesMu.Lock()
+	defer chapterRulesMu.Unlock()
+	if r, ok := chapterRulesCache[key]; ok {
+		return r
+	}
+	compiled, _ := CompileChapterRules(nil)
+	for _, rule := range rules {
+		if one, err := CompileChapterRules([]ChapterRule{rule}); err == nil {
+			compiled.patterns = append(compiled.patterns, one.patterns...)
+		}
+	}
+	if len(compiled.patterns) == 0 {
+		compiled, _ = CompileChapterRules(BuiltinChapterRules(lang))
+	}
+	chapterRulesCache[key] = compiled
+	return compiled
+}
+
+// IsChapterHeading reports whether line is a chapter heading under the
+// global rules.
+func IsChapterHeading(line string) bool {
+	return ChapterRulesFor("", nil).Match(line)
+}
+
+// ChapterRulesKey fingerprints the settings chapters of the book with the
+// given ID are found with, so what was found can be kept until they change.
+func ChapterRulesKey(id string) string {
+	c := AppConfig.Chapters
+	h := sha1.Sum([]byte(fmt.Sprintf("%v|%d|%d|%v", c.Rules, c.MaxTitleLength, c.SectionLength, c.Books[id])))
+	return hex.EncodeToString(h[:8])
+}
+
+// BookChapterRules returns the rules a book overrides the global ones with.
+func BookChapterRules(id string) ([]ChapterRule, bool) {
+	rules, ok := AppConfig.Chapters.Books[id]
+	return rules, ok
+}
+
+// SetBookChapterRules saves the rules of one book; nil goes back to the
+// global rules.
+func SetBookChapterRules(id string, rules []ChapterRule) error {
+	previous := AppConfig.Chapters.Books
+	next := make(map[string][]ChapterRule, len(previous)+1)
+	for k, v := range previous {
+		if k != id {
+			next[k] = v
+		}
+	}
+	if rules != nil {
+		next[id] = rules
+	}
+	AppConfig.Chapters.Books = next
+	resetChapterRules()
+	if err := SaveConfig(); err != nil {
+		AppConfig.Chapters.Books = previous
+		resetChapterRules()
+		return err
+	}
+	return nil
+}
+
+// resetChapterRules drops the compiled rules after the config changed.
+func resetChapterRules() {
+	chapterRulesMu.Lock()
+	chapterRulesCache = make(map[string]*ChapterRules)
+	chapterRulesMu.Unlock()
+}
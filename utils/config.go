@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -26,7 +27,8 @@ type ReaderConfig struct {
 
 // Library settings
 type LibraryConfig struct {
-	Paths []string `toml:"paths"`
+	Paths     []string          `toml:"paths"`
+	Encodings map[string]string `toml:"encodings,omitempty"` // by file path, see FileEncoding
 }
 
 // UI settings
@@ -34,19 +36,99 @@ type UIConfig struct {
 	Language string `toml:"language"`
 }
 
+// Network settings
+type NetworkConfig struct {
+	Offline bool `toml:"offline"`
+}
+
+// Cache settings for downloaded chapters
+type CacheConfig struct {
+	QuotaMB    int      `toml:"quota_mb"`    // 0 = default, negative = unlimited
+	KeepAround int      `toml:"keep_around"` // chapters kept on each side of progress
+	Pinned     []string `toml:"pinned"`      // book IDs that are never evicted
+}
+
+const (
+	defaultCacheQuotaMB    = 512
+	defaultCacheKeepAround = 10
+)
+
 // Root config
 type Config struct {
-	Reader  ReaderConfig  `toml:"reader"`
-	Library LibraryConfig `toml:"library"`
-	UI      UIConfig      `toml:"ui"`
+	Reader   ReaderConfig  `toml:"reader"`
+	Library  LibraryConfig `toml:"library"`
+	UI       UIConfig      `toml:"ui"`
+	Network  NetworkConfig `toml:"network"`
+	Cache    CacheConfig   `toml:"cache"`
+	Chapters ChapterConfig `toml:"chapters"`
 }
 
 // Global variable to hold config
 var (
 	AppConfig  Config
 	configPath string
+
+	// ForceOffline is set by the --offline flag for the current session only.
+	ForceOffline bool
 )
 
+// Offline reports whether network access is disabled, either by the config
+// toggle or by the --offline flag.
+func Offline() bool {
+	return ForceOffline || AppConfig.Network.Offline
+}
+
+// CacheQuotaBytes returns the cache size limit, or 0 for no limit.
+func CacheQuotaBytes() int64 {
+	mb := AppConfig.Cache.QuotaMB
+	switch {
+	case mb < 0:
+		return 0
+	case mb == 0:
+		mb = defaultCacheQuotaMB
+	}
+	return int64(mb) << 20
+}
+
+// CacheKeepAround returns how many chapters on each side of the reading
+// position are protected from eviction.
+func CacheKeepAround() int {
+	if AppConfig.Cache.KeepAround <= 0 {
+		return defaultCacheKeepAround
+	}
+	return AppConfig.Cache.KeepAround
+}
+
+// IsPinned reports whether the book's cache is exempt from eviction.
+func IsPinned(id string) bool {
+	for _, p := range AppConfig.Cache.Pinned {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPinned pins or unpins a book and saves the config.
+func SetPinned(id string, pinned bool) error {
+	previous := AppConfig.Cache.Pinned
+	next := make([]string, 0, len(previous)+1)
+	for _, p := range previous {
+		if p != id {
+			next = append(next, p)
+		}
+	}
+	if pinned {
+		next = append(next, id)
+	}
+	AppConfig.Cache.Pinned = next
+	if err := SaveConfig(); err != nil {
+		AppConfig.Cache.Pinned = previous
+		return err
+	}
+	return nil
+}
+
 // expandPath replaces leading "~" with user home dir
 func expandPath(path string) string {
 	if strings.HasPrefix(path, "~") {
@@ -62,7 +144,10 @@ func expandPath(path string) string {
 func LoadConfig(path string) {
 	configPath = path
 
-	data, err := os.ReadFile(path)
+	_, _, err := ReadFileRecover(path, func(data []byte) error {
+		AppConfig = Config{}
+		return toml.Unmarshal(data, &AppConfig)
+	})
 	if err != nil {
 		if os.IsNotExist(err) {
 			// create minimal default template (no paddings)
@@ -73,18 +158,16 @@ func LoadConfig(path string) {
 			}
 			// ensure dir and write file
 			_ = os.MkdirAll(filepath.Dir(path), 0o755)
-			if err := SaveConfig(); err != nil {
+			if err := SaveConfig(); err != nil && !errors.Is(err, ErrReadOnly) {
 				log.Fatalf("failed to create default config: %v", err)
 			}
 		} else {
 			log.Fatalf("failed to read config: %v", err)
 		}
-	} else {
-		if err := toml.Unmarshal(data, &AppConfig); err != nil {
-			log.Fatalf("failed to parse config: %v", err)
-		}
 	}
 
+	resetChapterRules()
+
 	// Hardcode paddings in-memory (not from file)
 	AppConfig.Reader.VerticalPadding = hardVPad
 	AppConfig.Reader.HorizontalPadding = hardHPad
@@ -127,6 +210,9 @@ func SaveConfig() error {
 	if configPath == "" {
 		return fmt.Errorf("config path not set")
 	}
+	if ReadOnly() {
+		return ErrReadOnly
+	}
 
 	cfg := AppConfig
 
@@ -147,14 +233,9 @@ func SaveConfig() error {
 		return err
 	}
 
-	tmpPath := configPath + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
-		return err
-	}
-	return os.Rename(tmpPath, configPath)
+	return WriteFileWithBackup(configPath, data, 0o644)
 }
 
 func Main() {
-	homeDir, _ := os.UserHomeDir()
-	LoadConfig(homeDir + "/.config/novel_reader/config.toml")
+	LoadConfig(filepath.Join(ConfigDir(), "config.toml"))
 }
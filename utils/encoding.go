@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Text files say nothing about their encoding, so it is guessed: the start
+// of the file is decoded with every candidate and each result is scored by
+// how much of it is made of the characters most frequent in the language
+// the encoding is for. A wrong decoding turns text into rare characters and
+// U+FFFD, so it scores low even when it decodes without errors, as almost
+// anything does in GB18030. A per-file setting overrides the guess, see
+// FileEncoding.
+
+const (
+	// encodingSample is how much of a file the candidates are scored on.
+	encodingSample = 64 << 10
+	// frequentShare is the share of frequent characters real text reaches;
+	// a decoding that reaches it, and beats the others, has full confidence.
+	frequentShare = 0.3
+	// invalidWeight is how much one U+FFFD costs against a frequent
+	// character.
+	invalidWeight = 20
+)
+
+// EncodingGuess is a candidate encoding and how confident detection is,
+// from 0 to 1.
+type EncodingGuess struct {
+	Name       string
+	Confidence float64
+}
+
+// textEncodings are the encodings files can be read in, by name. Only
+// detectedEncodings are guessed; the others can be chosen per file.
+var textEncodings = map[string]encoding.Encoding{
+	"utf-8":      unicode.UTF8,
+	"gb18030":    simplifiedchinese.GB18030,
+	"gbk":        simplifiedchinese.GBK,
+	"hz-gb-2312": simplifiedchinese.HZGB2312,
+	"big5":       traditionalchinese.Big5,
+	"shift_jis":  japanese.ShiftJIS,
+	"euc-jp":     japanese.EUCJP,
+	"euc-kr":     korean.EUCKR,
+	"utf-16le":   unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":   unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+}
+
+// detectedEncodings are guessed between, in the order ties are broken.
+var detectedEncodings = []string{"utf-8", "gb18030", "big5", "shift_jis", "euc-kr", "utf-16le", "utf-16be"}
+
+// chineseEncodings are the legacy encodings of Chinese text, which
+// ExtractText re-indents.
+var chineseEncodings = map[string]bool{"gb18030": true, "gbk": true, "hz-gb-2312": true, "big5": true}
+
+// EncodingNames lists the encodings a file can be set to, detected ones
+// first.
+func EncodingNames() []string {
+	names := append([]string(nil), detectedEncodings...)
+	var rest []string
+	for name := range textEncodings {
+		if !containsName(names, name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Characters frequent in novels, by language. Punctuation counts too: it
+// is as frequent as the commonest characters, and wrong decodings lose it.
+const (
+	frequentChinese = "，。、“”‘’！？：；…—《》「」『』（）" +
+		"的一是不了人我在有他这个们中来上大为和国地到以说时要就出会可也你对生能而子那得于着下自之年过发后作里用道行所然家种事成方多经么去法学如都同现当没动面起看定天分还进好小部其些主样理心她本前开但因只从想实日军者意无力它与长把机十民第公此已工使情明性知全三又关点正业外将两高间由问很最重并物手应战向头文体相见被利什二等产或新己制身果加西月话合回特代内信表化老给世位次度门任常先海通教儿原东声提立及比员解水名真论处走义各入几口认条平系气题活更别打女变四神总何电数安少报才结反受目太量再感建务做接必场件计管期直资命山金指许统区保至队形社便空决治展马科司五基眼书非则听白却界达光放强即像难且权思王象完设式色路记南品住告类求据程北边死张该交规万取拉格望觉术领共确传师观清今切院让识候带导争运笑飞风步改收根干造言联持组每车亲极林服快办议往元士证近失转夫令准布始怎呢存未远叫台单影具罗字爱击流备兵连调深商算质团集百需价花党华城石级整府离况亚请技际约示复病息究线似官火断精满支视消越器容照须九增研写称企八功吗包片史委乎查轻易早曾除农找装广显吧阿李标谈吃图念六引历首医局突专费号尽另周较注语仅考落青随选列武红响虽推势参希古众构房半节土投某案黑维革划敌致陈律足态护七兴派孩验责营星够章音跟志底站严巴例防族供效续施留讲型料终答紧黄绝奇察母京段依批群项故按河米围江织害斗双境客纪采举杀攻父苏密低朝友诉止细愿千值仍男钱破网热助倒育属坐帝限船脸职速刻乐否刚威毛状率甚独球般普怕弹校苦创假久错承印晚兰试股拿脑预谁益阳若哪微尼继送急血惊伤素药适波夜省初喜卫源食险待述陆习置居劳财环排福纳欢雷警获模充负云停木游龙树疑层冷洲冲射略范竟句室异激汉村哈策演简卡罪判担州静退既衣您宗积余痛检差富灵协角占配征修皮挥胜降阶审沉坚善妈刘读啊超免压银买皇养伊怀执副乱抗犯追帮宣佛岁航优怪香著田铁控税左右份穿艺背阵草脚概恶块顿敢守酒岛托央户烈洋哥索胡款靠评版宝座释景顾弟登货互付伯慢欧换闻危忙核暗姐介坏讨丽良序升监临亮露永呼味野架域沙掉括舰鱼杂误湾吉减编楚肯测败屋跑梦散温困剑渐封救贵枪缺楼县尚毫移娘朋画班智亦耳恩短掌恐遗固席松秘谢鲁遇康虑幸均销钟诗藏赶剧票损忽巨炮旧端探湖录叶春乡附吸予礼港雨呀板庭妇归睛饭额含顺输摇招婚脱补谓督毒油疗旅泽材灭逐莫笔亡鲜词圣择寻厂睡博勒烟授诺伦岸奥唐卖俄炸载洛健堂旁宫喝借君禁阴园谋宋避抓荣姑孙逃牙束跳顶玉镇雪午练迫爷篇肉嘴馆遍凡础洞卷坦牛宁纸诸训私庄祖丝翻暴森塔默握戏隐熟骨访弱蒙歌店鬼软典欲萨伙遭盘爸扩盖弄雄稳忘亿刺拥徒姆杨齐赛趣曲刀床迎冰虚玩析窗醒妻透购替塞努休虎扬途侵刑绿兄迅套贸毕唯谷轮库迹尤竞街促延震弃甲伟麻川申缓潜闪售灯针哲络抵朱埃抱鼓植纯夏忍页杰筑折郑贝尊吴秀混臣雅振染盛怒舞圆搞狂措姓残秋培迷诚宽宇猛摆梅毁伸摩盟末乃悲拍丁赵滑泪哭惜" +
+		// the traditional forms of the commonest above, where they differ
+		"這個們來為國說時會對發後裡經麼學現當沒動開無實軍長機產與從兩問間關應戰頭體見題電數報結覺術領確師觀讓識帶導爭運飛風變錢氣總處員將點過還進樣聽給東話"
+	frequentJapanese = "、。「」『』・ー…！？（）" +
+		"ぁあぃいぅうぇえぉおかがきぎくぐけげこごさざしじすずせぜそぞただちぢっつづてでとどなにぬねのはばぱひびぴふぶぷへべぺほぼぽまみむめもゃやゅゆょよらりるれろゎわをん" +
+		"ァアィイゥウェエォオカガキギクグケゲコゴサザシジスズセゼソゾタダチヂッツヅテデトドナニヌネノハバパヒビピフブプヘベペホボポマミムメモャヤュユョヨラリルレロヮワヲンヴ" +
+		"日一人大年出本中子見国言上分生手自行者二間事思時気会十家女三前的方入小地合後目長場代私下立部学物月田何来彼話体動社知理山内同心発高実作当新世今書度明五戦力名金性対意用男主通関文屋感郎業定政持道外取所"
+	frequentKorean = "“”‘’…" +
+		"이다는의고에하을가지한서로리기를도그자사나어대있아수시들부소것인해적보일상게라내정만으전면요히여무장성비문과제말경우오되주신중었년국원마화동없모와학던음미개간공세까저각더생입안실통발위물결또반선계알진연업관명방등할러며행거때했니려분같당드왜너난걸봤네야잖죠씨님"
+)
+
+var (
+	chineseSet  = runeSet(frequentChinese)
+	japaneseSet = runeSet(frequentJapanese)
+	koreanSet   = runeSet(frequentKorean)
+	anySet      = runeSet(frequentChinese + frequentJapanese + frequentKorean)
+)
+
+func runeSet(s string) map[rune]bool {
+	set := make(map[rune]bool)
+	for _, r := range s {
+		set[r] = true
+	}
+	return set
+}
+
+// frequentFor maps the detected encodings to the characters text in them
+// is made of.
+var frequentFor = map[string]map[rune]bool{
+	"gb18030":   chineseSet,
+	"big5":      chineseSet,
+	"shift_jis": japaneseSet,
+	"euc-kr":    koreanSet,
+}
+
+// DetectEncoding scores the detected encodings on data, best first. Text
+// in one CJK encoding often decodes to common characters in another too,
+// so a candidate's confidence also drops with how far it is behind the
+// best one.
+func DetectEncoding(data []byte) []EncodingGuess {
+	sample := data[:min(len(data), encodingSample)]
+	guesses := make([]EncodingGuess, len(detectedEncodings))
+	best := 0.0
+	for i, name := range detectedEncodings {
+		score := encodingScore(sample, name, len(data) > len(sample))
+		guesses[i] = EncodingGuess{Name: name, Confidence: score}
+		best = max(best, score)
+	}
+	for i := range guesses {
+		if score := guesses[i].Confidence; score > 0 {
+			guesses[i].Confidence = min(1, score/frequentShare) * score / best
+		}
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		return guesses[i].Confidence > guesses[j].Confidence
+	})
+	return guesses
+}
+
+// encodingScore scores one encoding on sample: the share of it that
+// decodes to frequent characters. cut tells that sample ends in the middle
+// of the file, possibly in the middle of a character.
+func encodingScore(sample []byte, name string, cut bool) float64 {
+	if hasUTF16BOM(sample) {
+		if name == bomEncoding(sample) {
+			return 1
+		}
+		return 0
+	}
+	nulls := bytes.Count(sample, []byte{0})
+	switch name {
+	case "utf-8":
+		body := bytes.TrimPrefix(sample, []byte{0xEF, 0xBB, 0xBF})
+		if cut {
+			body = trimPartialRune(body)
+		}
+		if nulls*100 > len(sample) || !utf8.Valid(body) {
+			return 0
+		}
+		return 1
+	case "utf-16le", "utf-16be":
+		if nulls == 0 && utf8.Valid(trimPartialRune(sample)) {
+			return 0 // no reason to think it is not UTF-8
+		}
+		decoded, _ := textEncodings[name].NewDecoder().Bytes(sample[:len(sample)&^1])
+		return frequentScore(string(decoded), anySet, true)
+	}
+	if bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}) || nulls > 0 {
+		return 0
+	}
+	decoded, err := textEncodings[name].NewDecoder().Bytes(sample)
+	if err != nil {
+		return 0
+	}
+	// the sample, or a damaged file, may end in the middle of a character
+	text := strings.TrimSuffix(string(decoded), string(utf8.RuneError))
+	return frequentScore(text, frequentFor[name], false)
+}
+
+// frequentScore is how much of s is made of the characters in set. ASCII
+// is left out unless withASCII, as every candidate decodes it the same.
+func frequentScore(s string, set map[rune]bool, withASCII bool) float64 {
+	total, hits, invalid := 0, 0, 0
+	for _, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			invalid++
+		case r < 0x80:
+			if !withASCII {
+				continue
+			}
+			if r >= 0x20 || r == '\n' || r == '\r' || r == '\t' {
+				hits++
+			}
+		case set[r]:
+			hits++
+		}
+		total++
+	}
+	if total == 0 {
+		return 0
+	}
+	return max(0, float64(hits-invalidWeight*invalid)/float64(total))
+}
+
+func hasUTF16BOM(data []byte) bool {
+	return bomEncoding(data) != ""
+}
+
+func bomEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+	return ""
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of data.
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i <= 3 && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// TextEncoding names the encoding data is read in when nothing is set for
+// its file: the best guess, or GB18030 for text no guess fits that is not
+// UTF-8, as most such files are Chinese.
+func TextEncoding(data []byte) string {
+	if len(data) == 0 {
+		return "utf-8"
+	}
+	if best := DetectEncoding(data)[0]; best.Confidence > 0 {
+		return best.Name
+	}
+	if utf8.Valid(data) {
+		return "utf-8"
+	}
+	return "gb18030"
+}
+
+// decodeText returns data as UTF-8 in the named encoding, guessing it for
+// "", and whether it was a legacy Chinese encoding.
+func decodeText(data []byte, name string) (string, bool) {
+	if name == "" {
+		name = TextEncoding(data)
+	}
+	enc, ok := textEncodings[name]
+	if !ok {
+		return string(data), false
+	}
+	switch {
+	case name == "utf-8":
+		data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	case strings.HasPrefix(name, "utf-16") && bomEncoding(data) == name:
+		data = data[2:]
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data), false
+	}
+	return string(decoded), chineseEncodings[name]
+}
+
+// DecodeText returns data as UTF-8, guessing its encoding like ExtractText.
+func DecodeText(data []byte) string {
+	decoded, _ := decodeText(data, "")
+	return decoded
+}
+
+// DecodeTextAs is DecodeText in the named encoding; "" guesses it.
+func DecodeTextAs(data []byte, name string) string {
+	decoded, _ := decodeText(data, name)
+	return decoded
+}
+
+// FileEncoding returns the encoding set for the file at path, or for the
+// folder or archive it is in, and "" when it is guessed.
+func FileEncoding(path string) string {
+	set := AppConfig.Library.Encodings
+	if len(set) == 0 {
+		return ""
+	}
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if name, ok := set[p]; ok {
+			return name
+		}
+		if parent := filepath.Dir(p); parent == p {
+			return ""
+		}
+	}
+}
+
+// SetFileEncoding saves the encoding the file at path is read in; ""
+// goes back to guessing it.
+func SetFileEncoding(path, name string) error {
+	path = filepath.Clean(path)
+	previous := AppConfig.Library.Encodings
+	next := make(map[string]string, len(previous)+1)
+	for k, v := range previous {
+		if k != path {
+			next[k] = v
+		}
+	}
+	if name != "" {
+		next[path] = name
+	}
+	AppConfig.Library.Encodings = next
+	if err := SaveConfig(); err != nil {
+		AppConfig.Library.Encodings = previous
+		return err
+	}
+	return nil
+}
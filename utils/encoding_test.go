@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFrequentTablesHaveNoDuplicates(t *testing.T) {
+	for name, table := range map[string]string{
+		"frequentChinese":  frequentChinese,
+		"frequentJapanese": frequentJapanese,
+		"frequentKorean":   frequentKorean,
+	} {
+		seen := make(map[rune]bool)
+		for _, r := range table {
+			if seen[r] {
+				t.Errorf("%s: %q is listed more than once", name, r)
+			}
+			seen[r] = true
+		}
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		text, encoding string
+	}{
+		{"　　萧炎抬起头来，看着那测验魔石碑上的字，脸上露出了苦涩的笑容。他知道，这一次，他又失败了。\n", "gb18030"},
+		{"　　蕭炎抬起頭來，看著那測驗魔石碑上的字，臉上露出了苦澀的笑容。他知道，這一次，他又失敗了。\n", "big5"},
+		{"　吾輩は猫である。名前はまだ無い。どこで生れたかとんと見当がつかぬ。\n", "shift_jis"},
+		{"그는 천천히 고개를 들어 하늘을 바라보았다. 오늘도 비가 내리고 있었다.\n", "euc-kr"},
+		{"　　萧炎抬起头来，看着那测验魔石碑上的字。\n", "utf-16le"},
+		{"　　萧炎抬起头来，看着那测验魔石碑上的字。\n", "utf-8"},
+	}
+	for _, tt := range tests {
+		data, err := textEncodings[tt.encoding].NewEncoder().Bytes([]byte(strings.Repeat(tt.text, 20)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.encoding, err)
+		}
+		if got := TextEncoding(data); got != tt.encoding {
+			t.Errorf("TextEncoding of %s text = %s, guesses %v", tt.encoding, got, DetectEncoding(data)[:3])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrReadOnly is returned by writes to shared data while another instance
+// holds the data directory lock.
+var ErrReadOnly = errors.New("data directory is in use by another instance; running read-only")
+
+var (
+	readOnly bool
+	lockFile *os.File // kept reachable so the finalizer never drops the lock
+)
+
+// ReadOnly reports whether this instance failed to take the data directory
+// lock. Progress and config are then never written, so the instance that
+// owns the lock cannot lose updates to a stale copy. Cache files are still
+// written: every write is atomic and keyed by book, so both instances can
+// share them.
+func ReadOnly() bool {
+	return readOnly
+}
+
+// LockFile blocks until it holds an exclusive advisory lock on path,
+// creating the file if needed. The returned func releases it.
+func LockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlock(f)
+		f.Close()
+	}, nil
+}
+
+// LockFileShared is LockFile for readers: any number of them may hold the
+// lock at once, but not while LockFile holds it.
+func LockFileShared(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockShared(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlock(f)
+		f.Close()
+	}, nil
+}
+
+// LockDataDir takes an advisory lock on the data directory. If another
+// instance already holds it, this instance switches to read-only instead of
+// failing. The returned func releases the lock.
+func LockDataDir() (func(), error) {
+	dir := DataDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return func() {}, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return func() {}, err
+	}
+	held, err := tryLock(f)
+	if err != nil {
+		f.Close()
+		return func() {}, err
+	}
+	if !held {
+		f.Close()
+		readOnly = true
+		return func() {}, nil
+	}
+	lockFile = f
+	return func() {
+		unlock(f)
+		f.Close()
+		lockFile = nil
+	}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package utils
+
+import "os"
+
+// Without flock every instance behaves as the owner, as before.
+func tryLock(f *os.File) (bool, error) { return true, nil }
+
+func lock(f *os.File) error { return nil }
+
+func lockShared(f *os.File) error { return nil }
+
+func unlock(f *os.File) {}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Pirated text files often skip or repeat chapters. AnalyzeChapterNumbers
+// reads the numbers in chapter titles and reports what does not add up.
+
+var (
+	numberedTitle = regexp.MustCompile(`^第\s*([0-9０-９零〇○一二三四五六七八九十百千万萬两壹贰叁肆伍陆柒捌玖拾佰仟]+)(?:\s*[-~～至到]\s*([0-9０-９零〇○一二三四五六七八九十百千万萬两壹贰叁肆伍陆柒捌玖拾佰仟]+))?\s*[章节節回话話集篇幕]`)
+	koreanTitle   = regexp.MustCompile(`^제\s*([0-9]+)\s*[화장]`)
+	englishTitle  = regexp.MustCompile(`^(?i:chapter|chap\.?|ch\.)\s*` + englishNumber)
+	leadingNumber = regexp.MustCompile(`^([0-9]+)(?:$|[\s.．、:：)）\-—])`)
+)
+
+// ChapterNumberOf reads the number of a chapter from its title, as in
+// 第一百二十三章, 第123章, Chapter XII or "12. Title". A title like
+// 第3-4章 covers first to last; for other titles they are the same.
+func ChapterNumberOf(title string) (first, last int, ok bool) {
+	title = strings.TrimSpace(title)
+	if m := numberedTitle.FindStringSubmatch(title); m != nil {
+		first, ok = ParseCNNumber(m[1])
+		last = first
+		if n, isRange := ParseCNNumber(m[2]); ok && isRange && n > first {
+			last = n
+		}
+		return first, last, ok
+	}
+	for _, re := range []*regexp.Regexp{koreanTitle, englishTitle, leadingNumber} {
+		if m := re.FindStringSubmatch(title); m != nil {
+			first, ok = ParseChapterNumber(m[1])
+			return first, first, ok
+		}
+	}
+	return 0, 0, false
+}
+
+// NumberRange is a run of chapter numbers, From to To inclusive.
+type NumberRange struct {
+	From, To int
+}
+
+// NumberingReport is what is wrong with the numbering of a book's chapters.
+// Positions are indices into the titles analyzed.
+type NumberingReport struct {
+	Numbered   int           // titles with a number
+	Missing    []NumberRange // numbers no chapter has
+	MissingAt  []int         // position of the chapter after each missing range
+	Duplicates []int         // chapters repeating an earlier number
+	Misordered []int         // chapters numbered lower than one before them
+}
+
+// MissingCount is how many chapter numbers are missing.
+func (r NumberingReport) MissingCount() int {
+	n := 0
+	for _, m := range r.Missing {
+		n += m.To - m.From + 1
+	}
+	return n
+}
+
+// OK reports whether nothing is wrong.
+func (r NumberingReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Duplicates) == 0 && len(r.Misordered) == 0
+}
+
+// minNumbered is how many numbered chapters a book needs to be analyzed.
+const minNumbered = 3
+
+// maxGap is the largest run of missing numbers reported. Larger jumps are
+// more likely a typo in a title than lost chapters.
+const maxGap = 200
+
+// AnalyzeChapterNumbers checks the numbers in titles, taken in reading
+// order. volumes are the positions in titles where a volume starts.
+// Titles without a number, like 序章, are skipped, and so are those
+// numbered 0 or less. A chapter 1 right after a volume starts, as books
+// count per volume, starts a new sequence; anywhere else it is just out of
+// order.
+func AnalyzeChapterNumbers(titles []string, volumes []int) NumberingReport {
+	var report NumberingReport
+	var chapters []numberedChapter
+	for i, t := range titles {
+		if first, last, ok := ChapterNumberOf(t); ok && first > 0 {
+			chapters = append(chapters, numberedChapter{i, first, last})
+		}
+	}
+	report.Numbered = len(chapters)
+	if len(chapters) < minNumbered {
+		return report
+	}
+
+	seen := make(map[int]bool)
+	highest, prev := -1, -1
+	for _, ch := range chapters {
+		if restarts(volumes, prev, ch) && highest > 1 {
+			seen = make(map[int]bool) // a new volume counting from 1
+			highest = -1
+		}
+		prev = ch.pos
+		switch {
+		case seen[ch.first]:
+			report.Duplicates = append(report.Duplicates, ch.pos)
+		case ch.first < highest:
+			report.Misordered = append(report.Misordered, ch.pos)
+		case highest >= 0 && ch.first > highest+1 && ch.first-highest-1 <= maxGap:
+			gap := NumberRange{From: highest + 1, To: ch.first - 1}
+			// numbers that only come later are misordered, not missing
+			for n := gap.From; n <= gap.To; n++ {
+				if !numberLater(chapters, volumes, ch.pos, n) {
+					continue
+				}
+				if n > gap.From {
+					report.addMissing(NumberRange{From: gap.From, To: n - 1}, ch.pos)
+				}
+				gap.From = n + 1
+			}
+			if gap.From <= gap.To {
+				report.addMissing(gap, ch.pos)
+			}
+		}
+		for n := ch.first; n <= ch.last; n++ {
+			seen[n] = true
+		}
+		highest = max(highest, ch.last)
+	}
+	return report
+}
+
+// numberedChapter is a chapter covering numbers first to last, at pos in
+// the titles analyzed.
+type numberedChapter struct{ pos, first, last int }
+
+func (r *NumberingReport) addMissing(gap NumberRange, pos int) {
+	r.Missing = append(r.Missing, gap)
+	r.MissingAt = append(r.MissingAt, pos)
+}
+
+// restarts reports whether ch is a chapter 1 with the start of a volume
+// between it and the numbered chapter at prev.
+func restarts(volumes []int, prev int, ch numberedChapter) bool {
+	if ch.first != 1 {
+		return false
+	}
+	for _, v := range volumes {
+		if prev < v && v <= ch.pos {
+			return true
+		}
+	}
+	return false
+}
+
+// numberLater reports whether a chapter after position pos, in the same
+// sequence, has number n.
+func numberLater(chapters []numberedChapter, volumes []int, pos, n int) bool {
+	highest, prev := -1, -1
+	for _, ch := range chapters {
+		if ch.pos <= pos {
+			highest = max(highest, ch.last)
+			prev = ch.pos
+			continue
+		}
+		if restarts(volumes, prev, ch) && highest > 1 {
+			return false // the next volume
+		}
+		if ch.first <= n && n <= ch.last {
+			return true
+		}
+		highest = max(highest, ch.last)
+		prev = ch.pos
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeChapterNumbers(t *testing.T) {
+	tests := []struct {
+		name       string
+		titles     []string
+		volumes    []int
+		missing    []NumberRange
+		duplicates []int
+		misordered []int
+	}{
+		{
+			name:    "word after chapter keyword",
+			titles:  []string{"第1章", "第2章", "第3章", "Chapter Last", "第5章"},
+			missing: []NumberRange{{4, 4}},
+		},
+		{
+			name:   "chapter zero",
+			titles: []string{"第1章", "第2章", "第3章", "第0章", "第4章"},
+		},
+		{
+			name:    "new volume",
+			titles:  []string{"第1章", "第2章", "第3章", "第1章", "第2章"},
+			volumes: []int{0, 3},
+		},
+		{
+			name:       "restart without a volume",
+			titles:     []string{"第1章", "第2章", "第3章", "第1章", "第4章"},
+			duplicates: []int{3},
+		},
+		{
+			name:    "gap in a volume",
+			titles:  []string{"第1章", "第2章", "第3章", "第1章", "第3章"},
+			volumes: []int{3},
+			missing: []NumberRange{{2, 2}},
+		},
+	}
+	for _, tt := range tests {
+		r := AnalyzeChapterNumbers(tt.titles, tt.volumes)
+		if !reflect.DeepEqual(r.Missing, tt.missing) || !reflect.DeepEqual(r.Duplicates, tt.duplicates) ||
+			!reflect.DeepEqual(r.Misordered, tt.misordered) {
+			t.Errorf("%s: missing %v, duplicates %v, misordered %v; want %v, %v, %v", tt.name,
+				r.Missing, r.Duplicates, r.Misordered, tt.missing, tt.duplicates, tt.misordered)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Chapter numbers come as Arabic digits (12, １２), Chinese numerals
+// (十二, 一百零五, 一〇二四), Roman numerals (XII) or English words
+// (Twelve, Twenty-One).
+
+// cnDigits maps the Chinese numerals for 0–9, including the financial and
+// colloquial forms, to their value.
+var cnDigits = map[rune]int{
+	'零': 0, '〇': 0, '○': 0,
+	'一': 1, '壹': 1,
+	'二': 2, '贰': 2, '貳': 2, '两': 2, '兩': 2,
+	'三': 3, '叁': 3, '參': 3,
+	'四': 4, '肆': 4,
+	'五': 5, '伍': 5,
+	'六': 6, '陆': 6, '陸': 6,
+	'七': 7, '柒': 7,
+	'八': 8, '捌': 8,
+	'九': 9, '玖': 9,
+}
+
+// cnUnits are the multipliers inside a section of up to 9999.
+var cnUnits = map[rune]int{
+	'十': 10, '拾': 10,
+	'百': 100, '佰': 100,
+	'千': 1000, '仟': 1000,
+}
+
+// cnSections are the multipliers that close a section.
+var cnSections = map[rune]int{
+	'万': 1e4, '萬': 1e4,
+	'亿': 1e8, '億': 1e8,
+}
+
+// IsCNNumeral reports whether r can be part of a Chinese number.
+func IsCNNumeral(r rune) bool {
+	_, d := cnDigits[r]
+	return d || cnUnits[r] > 0 || cnSections[r] > 0
+}
+
+// ParseCNNumber reads a Chinese numeral such as 一百二十三, 十五 or 一〇二四
+// (digit by digit), and also plain or full-width Arabic digits.
+func ParseCNNumber(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if n, ok := ParseDigits(s); ok {
+		return n, true
+	}
+	runes := []rune(s)
+	positional := true
+	for _, r := range runes {
+		if _, ok := cnDigits[r]; !ok {
+			positional = false
+			break
+		}
+	}
+	if positional && len(runes) > 1 {
+		n := 0
+		for _, r := range runes {
+			n = n*10 + cnDigits[r]
+		}
+		return n, true
+	}
+
+	total, section, digit := 0, 0, -1
+	for _, r := range runes {
+		d, isDigit := cnDigits[r]
+		switch {
+		case isDigit:
+			digit = d
+		case cnUnits[r] > 0:
+			if digit < 0 {
+				digit = 1 // 十五 is 15
+			}
+			section += digit * cnUnits[r]
+			digit = -1
+		case cnSections[r] > 0:
+			if digit > 0 {
+				section += digit
+			}
+			total += section * cnSections[r]
+			section, digit = 0, -1
+		default:
+			return 0, false
+		}
+	}
+	if digit > 0 {
+		section += digit
+	}
+	return total + section, true
+}
+
+// ParseDigits reads Arabic digits, full-width ones included.
+func ParseDigits(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= '０' && r <= '９':
+			b.WriteRune('0' + r - '０')
+		default:
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(b.String())
+	return n, err == nil
+}
+
+var romanValues = map[byte]int{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+
+// ParseRoman reads a Roman numeral in upper case. Only well-formed ones are
+// accepted, so words like DIM or MID are not numbers; words that are, like
+// Mix or mild, are not upper case.
+func ParseRoman(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		v, ok := romanValues[s[i]]
+		if !ok {
+			return 0, false
+		}
+		if i+1 < len(s) && romanValues[s[i+1]] > v {
+			n -= v
+		} else {
+			n += v
+		}
+	}
+	if n <= 0 || toRoman(n) != s {
+		return 0, false
+	}
+	return n, true
+}
+
+func toRoman(n int) string {
+	var b strings.Builder
+	for _, p := range []struct {
+		v int
+		s string
+	}{{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"}} {
+		for n >= p.v {
+			b.WriteString(p.s)
+			n -= p.v
+		}
+	}
+	return b.String()
+}
+
+var englishNumbers = map[string]int{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16,
+	"seventeen": 17, "eighteen": 18, "nineteen": 19, "twenty": 20,
+	"thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70,
+	"eighty": 80, "ninety": 90,
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6,
+	"seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+}
+
+// ParseEnglishNumber reads number words up to the thousands, like "One",
+// "twenty-one" or "one hundred and five", and ordinals up to "tenth".
+func ParseEnglishNumber(s string) (int, bool) {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '-'
+	})
+	if len(words) == 0 {
+		return 0, false
+	}
+	total, current, found := 0, 0, false
+	for _, w := range words {
+		switch w {
+		case "and":
+			continue
+		case "hundred":
+			current = max(current, 1) * 100
+			found = true
+		case "thousand":
+			total += max(current, 1) * 1000
+			current = 0
+			found = true
+		default:
+			v, ok := englishNumbers[w]
+			if !ok {
+				return 0, false
+			}
+			current += v
+			found = true
+		}
+	}
+	return total + current, found
+}
+
+// ParseChapterNumber reads a chapter number in any of the supported forms,
+// as it follows a chapter keyword. There Roman numerals may also be all
+// lower case, as in "chapter xii", but not capitalized like a word.
+func ParseChapterNumber(s string) (int, bool) {
+	s = strings.Trim(strings.TrimSpace(s), ".:：,、")
+	if n, ok := ParseCNNumber(s); ok {
+		return n, true
+	}
+	if s == strings.ToLower(s) {
+		if n, ok := ParseRoman(strings.ToUpper(s)); ok {
+			return n, true
+		}
+	} else if n, ok := ParseRoman(s); ok {
+		return n, true
+	}
+	return ParseEnglishNumber(s)
+}
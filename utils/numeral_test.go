@@ -0,0 +1,59 @@
+package utils
+
+import "testing"
+
+func TestParseRoman(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"XII", 12, true},
+		{"MCMXCIV", 1994, true},
+		{"IIII", 0, false},
+		{"xii", 0, false},
+		{"Mix", 0, false},
+		{"MIX", 1009, true},
+		{"Dim", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseRoman(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseRoman(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseChapterNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"12", 12, true},
+		{"一百零五", 105, true},
+		{"XII", 12, true},
+		{"xii", 12, true},
+		{"Mix", 0, false},
+		{"Twenty-One", 21, true},
+		{"Last", 0, false},
+		{"last", 0, false},
+		{"Meeting", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseChapterNumber(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseChapterNumber(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestChapterNumberOfWords(t *testing.T) {
+	for _, title := range []string{"Chapter Last", "Chapter Mix", "Chapter Meeting"} {
+		if n, _, ok := ChapterNumberOf(title); ok {
+			t.Errorf("ChapterNumberOf(%q) = %d, want no number", title, n)
+		}
+	}
+	if n, _, ok := ChapterNumberOf("Chapter XII"); !ok || n != 12 {
+		t.Errorf("ChapterNumberOf(%q) = %d, %v; want 12", "Chapter XII", n, ok)
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Where things live. By default the XDG base directories are used, so
+// backups of the config and data directories do not pick up gigabytes of
+// downloaded chapters:
+//
+//	config.toml            $XDG_CONFIG_HOME/novel_reader  (~/.config)
+//	cache (chapters)       $XDG_CACHE_HOME/novel_reader   (~/.cache)
+//	progress.json, reports $XDG_DATA_HOME/novel_reader    (~/.local/share)
+//
+// In portable mode all three sit under one directory, given by --data-dir or
+// $NOVEL_READER_HOME, as config/, cache/ and data/.
+const appDirName = "novel_reader"
+
+// HomeEnv names the environment variable that selects portable mode.
+const HomeEnv = "NOVEL_READER_HOME"
+
+// DataDirOverride is set by the --data-dir flag and wins over HomeEnv.
+var DataDirOverride string
+
+// PortableHome returns the portable-mode root, or "" in XDG mode.
+func PortableHome() string {
+	if DataDirOverride != "" {
+		return expandPath(DataDirOverride)
+	}
+	if env := strings.TrimSpace(os.Getenv(HomeEnv)); env != "" {
+		return expandPath(env)
+	}
+	return ""
+}
+
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appDirName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, fallback, appDirName)
+}
+
+// ConfigDir holds config.toml.
+func ConfigDir() string {
+	if home := PortableHome(); home != "" {
+		return filepath.Join(home, "config")
+	}
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// CacheDir holds downloaded chapters; it is safe to delete.
+func CacheDir() string {
+	if home := PortableHome(); home != "" {
+		return filepath.Join(home, "cache")
+	}
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// DataDir holds reading progress and error reports.
+func DataDir() string {
+	if home := PortableHome(); home != "" {
+		return filepath.Join(home, "data")
+	}
+	return xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// legacyDir is where every file lived before the XDG split.
+func legacyDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", appDirName)
+}
+
+// MigrateLegacyLayout moves the cache and progress out of the old
+// ~/.config/novel_reader into the XDG directories, and config.toml too when
+// $XDG_CONFIG_HOME points elsewhere. Anything already present at the new
+// location is left alone. Portable mode starts from its own directory and
+// is never migrated into.
+func MigrateLegacyLayout() error {
+	if PortableHome() != "" || ReadOnly() {
+		return nil
+	}
+	legacy := legacyDir()
+	if legacy == "" {
+		return nil
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+
+	moves := []struct{ from, to string }{
+		{filepath.Join(legacy, "cache"), CacheDir()},
+		{filepath.Join(legacy, "progress.json"), filepath.Join(DataDir(), "progress.json")},
+		{filepath.Join(legacy, "progress.json.bak"), filepath.Join(DataDir(), "progress.json.bak")},
+		{filepath.Join(legacy, "reports"), filepath.Join(DataDir(), "reports")},
+		{filepath.Join(legacy, "config.toml"), filepath.Join(ConfigDir(), "config.toml")},
+		{filepath.Join(legacy, "config.toml.bak"), filepath.Join(ConfigDir(), "config.toml.bak")},
+	}
+	var errs []error
+	for _, mv := range moves {
+		if samePath(mv.from, mv.to) {
+			continue
+		}
+		if err := movePath(mv.from, mv.to); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// movePath renames from to to, copying when they are on different file
+// systems. It does nothing if from is missing or to already exists.
+func movePath(from, to string) error {
+	if _, err := os.Lstat(from); err != nil {
+		return nil
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	// most likely a different file system: copy to a temp name, then swap
+	tmp := to + ".migrating"
+	os.RemoveAll(tmp)
+	if err := copyTree(from, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, to); err != nil {
+		return err
+	}
+	return os.RemoveAll(from)
+}
+
+func copyTree(from, to string) error {
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
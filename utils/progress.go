@@ -11,6 +11,7 @@ import (
 
 // Progress tracks reading progress of a novel
 type Progress struct {
+	Title       string    `json:"title,omitempty"`   // display name, for humans and migration
 	Source      string    `json:"source"`            // "local" or "online"
 	Page        int       `json:"page"`              // current page (for both local and online)
 	LastRead    time.Time `json:"last_read"`         // timestamp of last read
@@ -19,36 +20,24 @@ type Progress struct {
 }
 
 // ---------------- Paths ----------------
-func configDir() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-	return filepath.Join(home, ".config", "novel_reader"), nil
-}
-
 func progressFile() (string, error) {
-	dir, err := configDir()
-	if err != nil {
-		return "", err
-	}
+	dir := DataDir()
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", err
 	}
 	return filepath.Join(dir, "progress.json"), nil
 }
 
-// ---------------- Helper for compound keys ----------------
-func makeKey(name, source string) string {
-	return name + "|" + source
-}
+// ---------------- Legacy keys ----------------
 
-func parseKey(key string) (name, source string) {
+// LegacyKey splits a pre-ID progress key of the form "name|source".
+// Current keys are book IDs and never contain "|".
+func LegacyKey(key string) (name, source string, ok bool) {
 	parts := strings.SplitN(key, "|", 2)
 	if len(parts) == 2 {
-		return parts[0], parts[1]
+		return parts[0], parts[1], true
 	}
-	return key, "local"
+	return "", "", false
 }
 
 // ---------------- Load progress ----------------
@@ -57,7 +46,11 @@ func Load() (map[string]Progress, error) {
 	if err != nil {
 		return nil, err
 	}
-	data, err := os.ReadFile(path)
+	var raw map[string]Progress
+	_, _, err = ReadFileRecover(path, func(data []byte) error {
+		raw = nil
+		return json.Unmarshal(data, &raw)
+	})
 	if errors.Is(err, os.ErrNotExist) {
 		return make(map[string]Progress), nil
 	}
@@ -65,20 +58,20 @@ func Load() (map[string]Progress, error) {
 		return nil, err
 	}
 
-	var raw map[string]Progress
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil, err
-	}
-
-	progressMap := make(map[string]Progress)
+	progressMap := make(map[string]Progress, len(raw))
 	for k, v := range raw {
-		name, source := parseKey(k)
-		if v.Source == "" {
-			v.Source = source
+		if k == "" {
+			continue
 		}
-		if name != "" && v.Source != "" {
-			progressMap[makeKey(name, v.Source)] = v
+		if name, source, ok := LegacyKey(k); ok {
+			if v.Source == "" {
+				v.Source = source
+			}
+			if v.Title == "" {
+				v.Title = name
+			}
 		}
+		progressMap[k] = v
 	}
 
 	return progressMap, nil
@@ -86,50 +79,51 @@ func Load() (map[string]Progress, error) {
 
 // ---------------- Save progress ----------------
 func Save(m map[string]Progress) error {
+	if ReadOnly() {
+		return ErrReadOnly
+	}
 	path, err := progressFile()
 	if err != nil {
 		return err
 	}
 
-	saveMap := make(map[string]Progress)
+	saveMap := make(map[string]Progress, len(m))
 	for key, v := range m {
-		name, source := parseKey(key)
-		if name == "" || source == "" {
+		if key == "" || v.Source == "" {
 			continue // skip invalid entries
 		}
-		saveMap[makeKey(name, source)] = v
+		saveMap[key] = v
 	}
 
 	data, err := json.MarshalIndent(saveMap, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	return WriteFileWithBackup(path, data, 0644)
 }
 
 // ---------------- Convenience ----------------
 
-// GetProgress safely retrieves a progress entry by name + source
-func GetProgress(m map[string]Progress, name, source string) (Progress, bool) {
-	if name == "" || source == "" {
+// GetProgress safely retrieves a progress entry by book ID
+func GetProgress(m map[string]Progress, id string) (Progress, bool) {
+	if id == "" {
 		return Progress{}, false
 	}
-	p, ok := m[makeKey(name, source)]
+	p, ok := m[id]
 	return p, ok
 }
 
-// SetProgress safely updates a progress entry
-func SetProgress(m map[string]Progress, name, source string, p Progress) {
-	if name == "" || source == "" {
+// SetProgress safely updates a progress entry. Source must be set on p.
+func SetProgress(m map[string]Progress, id string, p Progress) {
+	if id == "" || p.Source == "" {
 		return
 	}
-	p.Source = source
-	m[makeKey(name, source)] = p
+	m[id] = p
 }
 
-// DeleteProgress removes a progress entry for the given name and source.
-func DeleteProgress(name, source string) error {
-	if name == "" || source == "" {
+// DeleteProgress removes the progress entry for the given book ID.
+func DeleteProgress(id string) error {
+	if id == "" {
 		return nil
 	}
 
@@ -138,11 +132,54 @@ func DeleteProgress(name, source string) error {
 		return err
 	}
 
-	key := makeKey(name, source)
-	if _, ok := progressMap[key]; !ok {
+	if _, ok := progressMap[id]; !ok {
 		return nil
 	}
-	delete(progressMap, key)
+	delete(progressMap, id)
 
 	return Save(progressMap)
 }
+
+// MoveProgress moves the progress entry of a book whose ID changed to its
+// new ID, unless the new ID has more recent progress of its own.
+func MoveProgress(from, to string) error {
+	if from == "" || to == "" || from == to {
+		return nil
+	}
+
+	progressMap, err := Load()
+	if err != nil {
+		return err
+	}
+
+	p, ok := progressMap[from]
+	if !ok {
+		return nil
+	}
+	if existing, ok := progressMap[to]; !ok || p.LastRead.After(existing.LastRead) {
+		progressMap[to] = p
+	}
+	delete(progressMap, from)
+
+	return Save(progressMap)
+}
+
+// SetAsideProgress renames an unreadable progress.json (and its backup) out
+// of the way so a fresh one can be started. The old files are kept with a
+// timestamp suffix in case they can be fixed by hand.
+func SetAsideProgress() error {
+	if ReadOnly() {
+		return ErrReadOnly
+	}
+	path, err := progressFile()
+	if err != nil {
+		return err
+	}
+	suffix := ".broken-" + time.Now().Format("20060102-150405")
+	for _, p := range []string{path, path + ".bak"} {
+		if err := os.Rename(p, p+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Text without chapter headings is still split into sections, so the TOC
+// and chapter-based progress work for it. SplitSections looks for, in
+// order: lines numbering the sections (1, 2、, III, 一), separator lines
+// (***, ---, long runs of blank lines) and short centered lines. When none
+// of these split the text into sections of a plausible size, it is cut
+// into sections of about section_length characters, titled by how they
+// start:
+//
+//	[chapters]
+//	section_length = 5000
+
+const defaultSectionLength = 5000
+
+// SectionLength returns the size of synthetic sections in characters.
+func SectionLength() int {
+	if AppConfig.Chapters.SectionLength <= 0 {
+		return defaultSectionLength
+	}
+	return AppConfig.Chapters.SectionLength
+}
+
+// Section is one part of a text found without headings.
+type Section struct {
+	Title string
+	Line  int
+}
+
+// sectionTitleLength is the most characters a line may have to be taken
+// for a section title, and the length of snippets used as titles.
+const sectionTitleLength = 20
+
+// SplitSections splits lines that have no chapter headings into sections.
+// Empty text has none.
+func SplitSections(lines []string) []Section {
+	total := 0
+	for _, l := range lines {
+		total += utf8.RuneCountInString(strings.TrimSpace(l))
+	}
+	if total == 0 {
+		return nil
+	}
+	for _, find := range []func([]string) []Section{numberedSections, separatedSections, centeredSections} {
+		if sections := find(lines); plausibleSections(sections, len(lines), total) {
+			return withOpening(lines, sections)
+		}
+	}
+	return fixedSections(lines, SectionLength())
+}
+
+// plausibleSections reports whether sections are few enough to be parts of
+// the story rather than lists or scene breaks inside it.
+func plausibleSections(sections []Section, lineCount, total int) bool {
+	if len(sections) < 2 {
+		return false
+	}
+	minLength := max(SectionLength()/10, 200)
+	return total/len(sections) >= minLength && lineCount/len(sections) >= 3
+}
+
+// withOpening adds a section for text before the first one found.
+func withOpening(lines []string, sections []Section) []Section {
+	for i := 0; i < sections[0].Line; i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			return append([]Section{{Title: snippet(lines, i), Line: 0}}, sections...)
+		}
+	}
+	sections[0].Line = 0
+	return sections
+}
+
+// numberedSections finds short lines starting with consecutive numbers
+// from 1, like "1", "2. The Road" or "三、", and keeps the longest run.
+func numberedSections(lines []string) []Section {
+	type numbered struct{ line, n int }
+	var found []numbered
+	for i, l := range lines {
+		if n, ok := sectionNumber(l); ok {
+			found = append(found, numbered{i, n})
+		}
+	}
+	var best []Section
+	for start, f := range found {
+		if f.n != 1 {
+			continue
+		}
+		var run []Section
+		next := 1
+		for _, g := range found[start:] {
+			if g.n == next {
+				run = append(run, Section{Title: strings.TrimSpace(lines[g.line]), Line: g.line})
+				next++
+			}
+		}
+		if len(run) > len(best) {
+			best = run
+		}
+	}
+	if len(best) < 3 {
+		return nil
+	}
+	return best
+}
+
+// sectionNumber reads the number a short line starts with. Digits and
+// Chinese numerals may be followed by a title; Roman numerals and number
+// words must stand alone, so "I said" is not section 1.
+func sectionNumber(line string) (int, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || utf8.RuneCountInString(line) > sectionTitleLength {
+		return 0, false
+	}
+	line = strings.TrimLeft(line, "(（[【")
+	end := strings.IndexFunc(line, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(".．、:：)）]】-—", r)
+	})
+	token, rest := line, ""
+	if end >= 0 {
+		token, rest = line[:end], strings.TrimSpace(strings.TrimLeft(line[end:], " .．、:：)）]】-—"))
+	}
+	if n, ok := ParseCNNumber(token); ok {
+		if end < 0 && !IsCNNumeral([]rune(token)[0]) && utf8.RuneCountInString(token) > 3 {
+			return 0, false // a bare long number is more likely a year or a sum
+		}
+		return n, true
+	}
+	if rest != "" || token != strings.ToUpper(token) {
+		return 0, false
+	}
+	return ParseRoman(token)
+}
+
+// separatedSections starts a section after each separator line and after
+// each run of blank lines clearly longer than those between paragraphs.
+func separatedSections(lines []string) []Section {
+	runs := make(map[int]int)
+	blank := 0
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			blank++
+			continue
+		}
+		if blank > 0 {
+			runs[blank]++
+		}
+		blank = 0
+	}
+	usual := 0
+	for n, count := range runs {
+		if count > runs[usual] {
+			usual = n
+		}
+	}
+	longBlank := max(usual+2, 3)
+
+	var sections []Section
+	pending, blank := false, 0
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		switch {
+		case t == "":
+			if blank++; blank == longBlank {
+				pending = true
+			}
+			continue
+		case IsSeparatorLine(t):
+			pending = true
+		case pending:
+			sections = append(sections, Section{Title: snippet(lines, i), Line: i})
+			pending = false
+		}
+		blank = 0
+	}
+	return sections
+}
+
+// IsSeparatorLine reports whether a trimmed line is only a separator like
+// ***, * * *, ----, ===, ~~~ or ☆☆☆.
+func IsSeparatorLine(line string) bool {
+	count := 0
+	for _, r := range line {
+		switch {
+		case r == ' ' || r == '　':
+		case strings.ContainsRune("*-=_~#·•—－＊※☆★◆◇○●◎", r):
+			count++
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+// centeredSections starts a section at each short line indented far more
+// than the text around it, the way plain text centers headings.
+func centeredSections(lines []string) []Section {
+	indents := make(map[int]int)
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			indents[indentWidth(l)]++
+		}
+	}
+	usual := 0
+	for n, count := range indents {
+		if count > indents[usual] {
+			usual = n
+		}
+	}
+	var sections []Section
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		if t == "" || utf8.RuneCountInString(t) > sectionTitleLength || indentWidth(l) < max(usual+6, 8) {
+			continue
+		}
+		sections = append(sections, Section{Title: t, Line: i})
+	}
+	return sections
+}
+
+// indentWidth is the width of the leading blanks of line, tabs counting 8.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch {
+		case r == '\t':
+			width += 8
+		case unicode.IsSpace(r):
+			width += runewidth.RuneWidth(r)
+		default:
+			return width
+		}
+	}
+	return 0
+}
+
+// fixedSections cuts the text into sections of about length characters,
+// between lines.
+func fixedSections(lines []string, length int) []Section {
+	var sections []Section
+	size := length
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		if size >= length && t != "" {
+			sections = append(sections, Section{Title: snippet(lines, i), Line: i})
+			size = 0
+		}
+		size += utf8.RuneCountInString(t)
+	}
+	if len(sections) > 0 {
+		sections[0].Line = 0
+	}
+	return sections
+}
+
+// snippet titles a section by its first line: whole when short, else cut.
+func snippet(lines []string, i int) string {
+	for ; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); t != "" {
+			if utf8.RuneCountInString(t) <= sectionTitleLength {
+				return t
+			}
+			return string([]rune(t)[:sectionTitleLength]) + "…"
+		}
+	}
+	return ""
+}